@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/backup"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/config"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/static"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+// runBackup dispatches the backup list, create and restore subcommands.
+func runBackup(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "list":
+			return runBackupList(args[1:])
+		case "create":
+			return runBackupCreate(args[1:])
+		case "restore":
+			return runBackupRestore(args[1:])
+		}
+	}
+
+	//nolint:wrapcheck // Creating new error, not wrapping
+	return errors.New("usage: backup list|create|restore [flags]")
+}
+
+// runBackupList prints the stored snapshots, newest first.
+func runBackupList(args []string) error {
+	flags := newFlagSet("backup list")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	cfg, err := config.LoadFlags(flags)
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	store, err := backup.New(nil, cfg.UniFi.Site, cfg.Backup.Directory, cfg.Backup.Retention)
+	if err != nil {
+		return err
+	}
+
+	snapshots, err := store.List()
+	if err != nil {
+		return err
+	}
+
+	writer := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(writer, "NAME\tTAKEN AT\tREASON")
+
+	for _, info := range snapshots {
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", info.Name, info.TakenAt.Format("2006-01-02 15:04:05Z07:00"), info.Reason)
+	}
+
+	return writer.Flush()
+}
+
+// runBackupCreate takes a snapshot now.
+func runBackupCreate(args []string) error {
+	flags := newFlagSet("backup create")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordsTimeout)
+	defer cancel()
+
+	env, err := newCommandEnv(ctx, flags)
+	if err != nil {
+		return err
+	}
+
+	store, err := newBackupStore(env.client, env.config, env.provider, env.provider)
+	if err != nil {
+		return err
+	}
+
+	info, written, err := store.Take(ctx, backup.ReasonManual)
+	if err != nil {
+		return err
+	}
+
+	if !written {
+		fmt.Fprintf(os.Stderr, "DNS records are unchanged since the newest snapshot\n")
+	}
+
+	fmt.Println(info.Name)
+
+	return nil
+}
+
+// runBackupRestore reconciles the site's DNS records with a snapshot.
+func runBackupRestore(args []string) error {
+	flags := newFlagSet("backup restore")
+	dryRun := flags.Bool("dry-run", false, "print the changes without applying them")
+	force := flags.Bool("force", false, "restore even while DNS changes are frozen by configuration or schedule")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	if flags.NArg() != 1 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("usage: backup restore [flags] NAME|latest")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordsTimeout)
+	defer cancel()
+
+	env, err := newCommandEnv(ctx, flags)
+	if err != nil {
+		return err
+	}
+
+	// The administrative freeze of a running webhook is not visible here, only configuration and schedule
+	freezeCtrl, err := newFreeze(env.provider, env.config.Freeze)
+	if err != nil {
+		return err
+	}
+
+	// Declared static records are guarded like in the running webhook
+	applier := provider.DNSProvider(env.provider)
+
+	if env.config.Static.File != "" {
+		staticRecords, err := static.New(env.provider, env.config.Static.File)
+		if err != nil {
+			return err
+		}
+
+		applier = staticRecords.Guard(env.provider, func(name string) bool {
+			return env.provider.GetDomainFilter().Match(name)
+		})
+	}
+
+	store, err := newBackupStore(env.client, env.config, env.provider, applier, backup.WithFreeze(freezeCtrl.Frozen))
+	if err != nil {
+		return err
+	}
+
+	result, restoreErr := store.Restore(ctx, flags.Arg(0), *dryRun, *force)
+	if errors.Is(restoreErr, backup.ErrFrozen) {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("DNS changes are frozen; use --dry-run to preview or --force to restore anyway")
+	}
+
+	if result != nil {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+
+		err = encoder.Encode(result)
+		if err != nil {
+			return errors.Wrap(err, "failed to print restore result")
+		}
+	}
+
+	return restoreErr
+}
+
+// newBackupStore creates the snapshot store; restores only touch records the provider
+// manages and are applied through applier.
+func newBackupStore(client unifi.NetworkAPIClient, cfg *config.Config, prov *provider.UniFiProvider,
+	applier backup.Applier, opts ...backup.Option,
+) (*backup.Store, error) {
+	store, err := backup.New(client, cfg.UniFi.Site, cfg.Backup.Directory, cfg.Backup.Retention,
+		append([]backup.Option{backup.WithScope(prov.Manages), backup.WithProvider(prov.Endpoints, applier)}, opts...)...)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create backup store")
+	}
+
+	return store, nil
+}
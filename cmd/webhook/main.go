@@ -2,48 +2,323 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"fmt"
 	"log/slog"
 	"net/http"
 	_ "net/http/pprof" // Register pprof handlers
+	"net/netip"
 	"os"
 	"os/signal"
+	"strconv"
 	"strings"
 	"syscall"
 	"time"
+	_ "time/tzdata" // Freeze time zones resolve in the scratch image, which has no zoneinfo
 
 	"github.com/cockroachdb/errors"
 	"github.com/lexfrei/external-dns-unifios-webhook/api/health"
 	"github.com/lexfrei/external-dns-unifios-webhook/api/webhook"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/autorecords"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/backup"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/config"
-	"github.com/lexfrei/external-dns-unifios-webhook/internal/healthserver"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/diagnostics"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/docker"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/drift"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/freeze"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/healthserver"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/middleware"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/observability"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/ptr"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/reconcile"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/secret"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/static"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/tlsconfig"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/unificlient"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/verify"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/webhookserver"
 	unifi "github.com/lexfrei/go-unifi/api/network"
 	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/pflag"
+	"go.yaml.in/yaml/v3"
 	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Exit codes of the check command.
+const (
+	exitCheckFailed   = 1
+	exitConfigInvalid = 2
 )
 
+// exitCode is returned by subcommands that report their outcome through the exit status.
+type exitCode int
+
+func (c exitCode) Error() string {
+	return "exit status " + strconv.Itoa(int(c))
+}
+
 func main() {
-	if err := run(); err != nil {
+	if err := dispatch(os.Args[1:]); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+
 		slog.Error("application error", "error", err)
 		os.Exit(1)
 	}
 }
 
-func run() error {
-	_, cancel := context.WithCancel(context.Background())
+// dispatch runs the subcommand named by the first argument, or the webhook server by default.
+func dispatch(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "fingerprint":
+			return runFingerprint(args[1:])
+		case "check", "--check":
+			return runCheck(args[1:])
+		case "schema":
+			return runSchema()
+		case "records":
+			return runRecords(args[1:])
+		case "backup":
+			return runBackup(args[1:])
+		case "migrate":
+			return runMigrate(args[1:])
+		}
+	}
+
+	return run(args)
+}
+
+// newFlagSet returns a flag set with a flag for every configuration setting.
+func newFlagSet(name string) *pflag.FlagSet {
+	flags := pflag.NewFlagSet(name, pflag.ContinueOnError)
+	config.RegisterFlags(flags)
+
+	return flags
+}
+
+// parseFlags parses args, returning pflag.ErrHelp unchanged so callers can exit cleanly.
+func parseFlags(flags *pflag.FlagSet, args []string) error {
+	err := flags.Parse(args)
+	if err != nil && !errors.Is(err, pflag.ErrHelp) {
+		return errors.Wrap(err, "invalid arguments")
+	}
+
+	return err
+}
+
+// ignoreHelp treats a help request as success; the flag set already printed the usage.
+func ignoreHelp(err error) error {
+	if errors.Is(err, pflag.ErrHelp) {
+		return nil
+	}
+
+	return err
+}
+
+// runSchema prints the JSON Schema of the config file.
+func runSchema() error {
+	schema, err := config.Schema()
+	if err != nil {
+		return err
+	}
+
+	_, err = os.Stdout.Write(schema)
+
+	return err
+}
+
+// runCheck validates the configuration and the controller connection and prints a report.
+// It exits with 0 when all checks pass or only warn, 1 when a check fails and
+// 2 when the configuration cannot be loaded.
+func runCheck(args []string) error {
+	flags := newFlagSet("check")
+	format := flags.String("format", "text", "report format: text or json")
+	writeProbe := flags.Bool("write-probe", true, "create and delete a probe DNS record to verify write access")
+
+	err := parseFlags(flags, args)
+	if errors.Is(err, pflag.ErrHelp) {
+		return nil
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+
+		return exitCode(exitConfigInvalid)
+	}
+
+	if *format != "text" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unknown report format %q\n", *format)
+
+		return exitCode(exitConfigInvalid)
+	}
+
+	// Client warnings go to stderr so they do not mix with the report
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn})))
+
+	code := 0
+	report := &diagnostics.Report{}
+
+	cfg, err := config.LoadFlags(flags)
+	if err != nil {
+		report.Add("config.load", diagnostics.StatusFail, "%v", err)
+		code = exitConfigInvalid
+	} else {
+		report = diagnostics.CheckConfig(cfg)
+
+		ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+
+		client, err := newUniFiClient(ctx, cfg.UniFi,
+			observability.NewSlogAdapter(slog.Default()),
+			observability.NewPrometheusRecorder(registry, "external_dns_unifi"))
+		if err != nil {
+			report.Add("controller.connect", diagnostics.StatusFail, "%v", err)
+		} else {
+			diagnostics.CheckController(ctx, report, client, cfg.UniFi.Site, *writeProbe)
+		}
+
+		if report.Failed() {
+			code = exitCheckFailed
+		}
+	}
+
+	if *format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if code != 0 {
+		return exitCode(code)
+	}
+
+	return nil
+}
+
+// runFingerprint prints the SHA-256 fingerprint of the controller certificate for trust-on-first-use.
+func runFingerprint(args []string) error {
+	flags := newFlagSet("fingerprint")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	cfg, err := config.LoadFlags(flags)
+	if err != nil {
+		return errors.Wrap(err, "failed to load config")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fingerprint, err := unificlient.FetchFingerprint(ctx, cfg.UniFi.Host)
+	if err != nil {
+		return errors.Wrap(err, "failed to fetch controller certificate")
+	}
+
+	fmt.Fprintf(os.Stderr, "Verify this fingerprint out of band, then set WEBHOOK_UNIFI_TLS_FINGERPRINT to pin it:\n")
+	fmt.Println(fingerprint)
+
+	return nil
+}
+
+// commandEnv is what offline subcommands need to work on the controller like the webhook server does.
+type commandEnv struct {
+	config   *config.Config
+	client   unifi.NetworkAPIClient
+	provider *provider.UniFiProvider
+}
+
+// newCommandEnv loads the configuration and builds the client and provider the way the webhook server does.
+func newCommandEnv(ctx context.Context, flags *pflag.FlagSet) (*commandEnv, error) {
+	cfg, err := config.LoadFlags(flags)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load config")
+	}
+
+	// Logs go to stderr so they do not mix with command output
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: parseLogLevel(cfg.Logging.Level)})))
+
+	client, err := newUniFiClient(ctx, cfg.UniFi,
+		observability.NewSlogAdapter(slog.Default()),
+		observability.NewPrometheusRecorder(prometheus.NewRegistry(), "external_dns_unifi"))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create UniFi API client")
+	}
+
+	protection, err := newProtection(cfg.Protection)
+	if err != nil {
+		return nil, err
+	}
+
+	rewrites, err := newRewrites(cfg.Rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	targets, err := newTargetRewrites(cfg.Rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := newTargetPolicy(cfg.TargetPolicy)
+	if err != nil {
+		return nil, err
+	}
+
+	pointers, err := newPointers(cfg.PTR, client, cfg.UniFi.Site)
+	if err != nil {
+		return nil, err
+	}
+
+	prov := provider.New(client, cfg.UniFi.Site, *newDomainFilter(cfg.DomainFilter),
+		provider.WithProtection(protection),
+		provider.WithRewrites(rewrites),
+		provider.WithTargetRewrites(targets),
+		provider.WithTargetPolicy(policy),
+		provider.WithVerifier(newVerifier(cfg.Verify)),
+		provider.WithFailOnVerify(cfg.Verify.FailOnError),
+		provider.WithPointers(pointers),
+		provider.WithMaxConcurrency(cfg.Limits.MaxConcurrency))
+
+	return &commandEnv{config: cfg, client: client, provider: prov}, nil
+}
+
+func run(args []string) error {
+	flags := newFlagSet("external-dns-unifios-webhook")
+	printConfig := flags.Bool("print-config", false, "Print the effective configuration with secrets redacted and exit")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Load configuration
-	cfg, err := config.Load()
+	cfg, err := config.LoadFlags(flags)
 	if err != nil {
 		return errors.Wrap(err, "failed to load config")
 	}
 
+	if *printConfig {
+		return yaml.NewEncoder(os.Stdout).Encode(cfg.Redacted())
+	}
+
 	// Setup logging
 	setupLogging(cfg.Logging)
 
@@ -64,32 +339,209 @@ func run() error {
 	metricsRecorder := observability.NewPrometheusRecorder(registry, "external_dns_unifi")
 
 	// Create domain filter
-	domainFilter := endpoint.NewDomainFilterWithExclusions(
-		cfg.DomainFilter.Filters,
-		cfg.DomainFilter.ExcludeFilters,
-	)
+	domainFilter := newDomainFilter(cfg.DomainFilter)
 
 	// Create UniFi API client
-	client, err := unifi.NewWithConfig(&unifi.ClientConfig{
-		ControllerURL:      cfg.UniFi.Host,
-		APIKey:             cfg.UniFi.APIKey,
-		InsecureSkipVerify: cfg.UniFi.SkipTLSVerify,
-		Logger:             logger,
-		Metrics:            metricsRecorder,
-	})
+	client, err := newUniFiClient(ctx, cfg.UniFi, logger, metricsRecorder)
 	if err != nil {
 		return errors.Wrap(err, "failed to create UniFi API client")
 	}
 
-	if cfg.UniFi.SkipTLSVerify {
-		slog.Warn("TLS certificate verification is disabled")
+	// Create protection rules for records the webhook must never touch
+	protection, err := newProtection(cfg.Protection)
+	if err != nil {
+		return err
+	}
+
+	// Create rewrite rules between the names and targets external-dns sees and the ones stored in UniFi
+	rewrites, err := newRewrites(cfg.Rewrite)
+	if err != nil {
+		return err
+	}
+
+	targets, err := newTargetRewrites(cfg.Rewrite)
+	if err != nil {
+		return err
+	}
+
+	// Create the policy for the addresses records may point to
+	policy, err := newTargetPolicy(cfg.TargetPolicy)
+	if err != nil {
+		return err
+	}
+
+	// Create the reverse records kept for A and AAAA records, if enabled
+	pointers, err := newPointers(cfg.PTR, client, cfg.UniFi.Site)
+	if err != nil {
+		return err
+	}
+
+	providerOpts := []provider.Option{
+		provider.WithProtection(protection),
+		provider.WithRewrites(rewrites),
+		provider.WithTargetRewrites(targets),
+		provider.WithTargetPolicy(policy),
+		provider.WithVerifier(newVerifier(cfg.Verify)),
+		provider.WithFailOnVerify(cfg.Verify.FailOnError),
+		provider.WithPointers(pointers),
+		provider.WithMaxConcurrency(cfg.Limits.MaxConcurrency),
+	}
+
+	// The backup store, drift detector and freeze controller are created after the provider,
+	// so its hooks and the backup store look them up once all exist
+	var (
+		backups    *backup.Store
+		detector   *drift.Detector
+		freezeCtrl *freeze.Controller
+	)
+
+	if cfg.Backup.Enabled {
+		providerOpts = append(providerOpts, provider.WithBeforeApply(func(ctx context.Context) {
+			backups.BeforeApply(ctx)
+		}))
+	}
+
+	if cfg.Drift.Enabled {
+		providerOpts = append(providerOpts,
+			provider.WithBeforeApply(func(ctx context.Context) {
+				detector.BeforeApply(ctx)
+			}),
+			provider.WithAfterApply(func(ctx context.Context, changes *plan.Changes, err error) {
+				detector.AfterApply(ctx, changes, err)
+			}))
 	}
 
 	// Create UniFi provider with dependency injection
-	prov := provider.New(client, cfg.UniFi.Site, *domainFilter)
+	prov := provider.New(client, cfg.UniFi.Site, *domainFilter, providerOpts...)
+
+	// Reverse records are updated with each change, so records that existed before are synced once
+	if pointers != nil {
+		err = prov.SyncPointers(ctx)
+		if err != nil {
+			slog.Error("failed to sync PTR records", "error", err)
+		}
+	}
+
+	// Records declared in the static records file are reconciled by their store,
+	// and external-dns reaches the provider through its guard
+	var staticRecords *static.Store
+
+	external := provider.DNSProvider(prov)
+
+	if cfg.Static.File != "" {
+		staticRecords, err = static.New(prov, cfg.Static.File, static.WithFreeze(func() bool {
+			return freezeCtrl.Frozen()
+		}))
+		if err != nil {
+			return err
+		}
+
+		external = staticRecords.Guard(prov, func(name string) bool {
+			return prov.GetDomainFilter().Match(name)
+		})
+	}
+
+	// Snapshot DNS records on a schedule and before every change; restores go through the guarded provider
+	if cfg.Backup.Enabled {
+		backups, err = newBackupStore(client, cfg, prov, external, backup.WithFreeze(func() bool {
+			return freezeCtrl.Frozen()
+		}))
+		if err != nil {
+			return err
+		}
+
+		go backups.Run(ctx, cfg.Backup.Interval)
+	}
+
+	// Create freeze controller for maintenance windows
+	freezeCtrl, err = newFreeze(external, cfg.Freeze)
+	if err != nil {
+		return err
+	}
+
+	go freezeCtrl.Run(ctx)
+
+	if freezeCtrl.Frozen() {
+		slog.Warn("DNS changes are frozen", "sources", freezeCtrl.Sources(), "mode", cfg.Freeze.Mode)
+	}
+
+	// Compare managed records with the last applied desired state
+	if cfg.Drift.Enabled {
+		detector, err = drift.New(prov,
+			drift.WithAutoCorrect(cfg.Drift.AutoCorrect),
+			drift.WithFreeze(freezeCtrl.Frozen),
+			drift.WithScope(func(name string) bool {
+				return prov.GetDomainFilter().Match(name)
+			}),
+			drift.WithState(cfg.Drift.StateFile))
+		if err != nil {
+			return errors.Wrap(err, "failed to create drift detector")
+		}
+
+		go detector.Run(ctx, cfg.Drift.Interval)
+	}
+
+	// Reconcile the static records file alongside external-dns
+	if staticRecords != nil {
+		go staticRecords.Run(ctx, cfg.Static.Interval)
+	}
+
+	// Apply records declared in files the way external-dns would, for hosts without it
+	if cfg.Reconcile.Source != "" {
+		reconciler := reconcile.New(external, cfg.Reconcile.Source,
+			reconcile.WithPolicy(plan.Policies[cfg.Reconcile.Policy]),
+			reconcile.WithManagedTypes(cfg.Reconcile.ManagedRecordTypes),
+			reconcile.WithScope(func(name string) bool {
+				return prov.GetDomainFilter().Match(name)
+			}),
+			reconcile.WithFreeze(freezeCtrl.Frozen))
+
+		go reconciler.Run(ctx, cfg.Reconcile.Interval)
+	}
+
+	// Publish records for connected UniFi clients
+	if cfg.Clients.Enabled {
+		if domainFilter.Match(cfg.Clients.Domain) {
+			slog.Warn("client records are inside the domain filter, external-dns may delete them unless it uses a TXT registry",
+				"domain", cfg.Clients.Domain)
+		}
+
+		go newClientSyncer(client, cfg, prov, external, freezeCtrl.Frozen).Run(ctx, cfg.Clients.Interval)
+	}
+
+	// Publish records for UniFi gateways, switches and access points
+	if cfg.Devices.Enabled {
+		if domainFilter.Match(cfg.Devices.Domain) {
+			slog.Warn("device records are inside the domain filter, external-dns may delete them unless it uses a TXT registry",
+				"domain", cfg.Devices.Domain)
+		}
+
+		devices, err := autorecords.NewDevices(client, cfg.UniFi.Site, cfg.Devices.Template)
+		if err != nil {
+			return err
+		}
+
+		syncer := autorecords.New(prov, cfg.Devices.Domain, []autorecords.Source{devices},
+			autorecords.WithTTL(cfg.Devices.TTL),
+			autorecords.WithFreeze(freezeCtrl.Frozen),
+			autorecords.WithApplier(external),
+			autorecords.WithState(cfg.Devices.StateFile))
+
+		go syncer.Run(ctx, cfg.Devices.Interval)
+	}
+
+	// Publish records for labeled Docker containers
+	if cfg.Docker.Enabled {
+		if domainFilter.Match(cfg.Docker.Domain) {
+			slog.Warn("container records are inside the domain filter, external-dns may delete them unless it uses a TXT registry",
+				"domain", cfg.Docker.Domain)
+		}
+
+		go runDockerSyncer(ctx, cfg, prov, external, freezeCtrl.Frozen)
+	}
 
 	// Create webhook server
-	webhookSrv := webhookserver.New(prov, *domainFilter)
+	webhookSrv := webhookserver.New(external, *domainFilter, webhookserver.WithFreeze(freezeCtrl))
 	webhookMux := http.NewServeMux()
 
 	// Custom error handler with detailed logging
@@ -103,12 +555,49 @@ func run() error {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 	}
 
-	webhook.HandlerWithOptions(webhookSrv, webhook.StdHTTPServerOptions{
-		BaseRouter:       webhookMux,
-		ErrorHandlerFunc: errorHandler,
-	})
+	// The reconcile loop owns the records external-dns would, so the external-dns API is not served next to it
+	if cfg.Reconcile.Source != "" {
+		slog.Info("external-dns webhook API is disabled while the reconcile loop runs", "source", cfg.Reconcile.Source)
+	} else {
+		webhook.HandlerWithOptions(webhookSrv, webhook.StdHTTPServerOptions{
+			BaseRouter:       webhookMux,
+			ErrorHandlerFunc: errorHandler,
+		})
+	}
+
+	// Administrative endpoints change DNS state, so they are only served to authenticated or local callers
+	adminEnabled := adminAllowed(cfg.Server)
+	if !adminEnabled {
+		slog.Warn("administrative endpoints are disabled: configure webhook authentication or listen on localhost only",
+			"host", cfg.Server.Host)
+	}
+
+	// Administrative freeze toggle lives next to the webhook API it gates
+	if adminEnabled {
+		webhookMux.Handle("/admin/freeze", freezeCtrl)
+	}
+
+	if backups != nil && adminEnabled {
+		backupHandler := backups.Handler()
+		webhookMux.Handle("/admin/backups", backupHandler)
+		webhookMux.Handle("/admin/backups/", backupHandler)
+	}
+
+	// Optional authentication; logging stays outermost so rejected requests are logged
+	authenticator, err := newAuthenticator(cfg.Server)
+	if err != nil {
+		return err
+	}
+
+	var webhookHandler http.Handler = webhookMux
+	if authenticator != nil {
+		webhookHandler = authenticator.Wrap(webhookHandler)
+	} else if !loopbackHost(cfg.Server.Host) && cfg.Server.TLSClientCAFile == "" {
+		slog.Warn("webhook API is reachable beyond localhost without authentication, require client certificates with WEBHOOK_SERVER_TLS_CLIENT_CA_FILE",
+			"host", cfg.Server.Host)
+	}
 
-	webhookHandler := middleware.Logging(webhookMux)
+	webhookHandler = middleware.Logging(webhookHandler)
 
 	webhookHTTPServer := &http.Server{
 		Addr:              joinHostPort(cfg.Server.Host, cfg.Server.Port),
@@ -120,10 +609,57 @@ func run() error {
 		MaxHeaderBytes:    64 << 10,          // 64 KB (headers are small, body is in request body)
 	}
 
+	webhookHTTPServer.TLSConfig, err = newServerTLSConfig(cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile, cfg.Server.TLSClientCAFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to configure webhook server TLS")
+	}
+
+	// Apply config file changes to the running components
+	go config.Watch(ctx, cfg, func(next *config.Config, _ config.Changes) error {
+		nextProtection, err := newProtection(next.Protection)
+		if err != nil {
+			return err
+		}
+
+		nextPolicy, err := newTargetPolicy(next.TargetPolicy)
+		if err != nil {
+			return err
+		}
+
+		location, err := time.LoadLocation(next.Freeze.Timezone)
+		if err != nil {
+			return errors.Wrap(err, "failed to load freeze time zone")
+		}
+
+		err = freezeCtrl.Reconfigure(freeze.Mode(next.Freeze.Mode), next.Freeze.Enabled, next.Freeze.Windows, location)
+		if err != nil {
+			return errors.Wrap(err, "failed to reconfigure change freeze")
+		}
+
+		nextFilter := newDomainFilter(next.DomainFilter)
+
+		prov.SetDomainFilter(*nextFilter)
+		prov.SetProtection(nextProtection)
+		prov.SetTargetPolicy(nextPolicy)
+		prov.SetMaxConcurrency(next.Limits.MaxConcurrency)
+		webhookSrv.SetDomainFilter(*nextFilter)
+		logLevel.Set(parseLogLevel(next.Logging.Level))
+
+		return nil
+	})
+
 	// Create health server with custom registry
-	healthSrv := healthserver.New(prov, registry)
+	healthSrv := healthserver.New(prov, registry, healthserver.WithFreeze(freezeCtrl))
 	healthMux := http.NewServeMux()
 	health.HandlerFromMux(healthSrv, healthMux)
+
+	if detector != nil {
+		healthMux.Handle("/drift", detector)
+	}
+
+	if staticRecords != nil {
+		healthMux.Handle("/static-records", staticRecords)
+	}
 	healthHandler := middleware.Logging(healthMux)
 
 	healthHTTPServer := &http.Server{
@@ -136,6 +672,11 @@ func run() error {
 		MaxHeaderBytes:    64 << 10,         // 64 KB (health checks have small headers)
 	}
 
+	healthHTTPServer.TLSConfig, err = newServerTLSConfig(cfg.Health.TLSCertFile, cfg.Health.TLSKeyFile, "")
+	if err != nil {
+		return errors.Wrap(err, "failed to configure health server TLS")
+	}
+
 	// Start pprof debug server if enabled
 	// pprofHTTPServer is declared outside the block to allow graceful shutdown
 	var pprofHTTPServer *http.Server
@@ -168,16 +709,16 @@ func run() error {
 
 	// Start webhook server
 	go func() {
-		slog.Info("starting webhook server", "address", webhookHTTPServer.Addr)
-		if err := webhookHTTPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("starting webhook server", "address", webhookHTTPServer.Addr, "tls", webhookHTTPServer.TLSConfig != nil)
+		if err := listenAndServe(webhookHTTPServer); err != nil && err != http.ErrServerClosed {
 			errChan <- errors.Wrap(err, "webhook server error")
 		}
 	}()
 
 	// Start health server
 	go func() {
-		slog.Info("starting health server", "address", healthHTTPServer.Addr)
-		if err := healthHTTPServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		slog.Info("starting health server", "address", healthHTTPServer.Addr, "tls", healthHTTPServer.TLSConfig != nil)
+		if err := listenAndServe(healthHTTPServer); err != nil && err != http.ErrServerClosed {
 			errChan <- errors.Wrap(err, "health server error")
 		}
 	}()
@@ -213,23 +754,332 @@ func run() error {
 	return nil
 }
 
-func setupLogging(cfg config.LoggingConfig) {
-	var level slog.Level
-	switch cfg.Level {
+// newUniFiClient creates the UniFi API client. Custom trust settings and session
+// authentication need a transport that go-unifi does not expose, so they use the
+// unificlient implementation instead. A secret read from a file is watched and
+// rotated without a restart.
+func newUniFiClient(ctx context.Context, cfg config.UniFiConfig, logger *observability.SlogAdapter, metrics *observability.PrometheusRecorder) (unifi.NetworkAPIClient, error) {
+	trust := unificlient.TrustConfig{
+		CAFile:      cfg.CAFile,
+		Fingerprint: cfg.TLSFingerprint,
+		SkipVerify:  cfg.SkipTLSVerify,
+	}
+
+	if trust.Insecure() {
+		slog.Warn("TLS certificate verification is disabled; set WEBHOOK_UNIFI_CA_FILE or WEBHOOK_UNIFI_TLS_FINGERPRINT to verify the controller")
+		dnsmetrics.UniFiTLSVerificationDisabled.Set(1)
+	} else {
+		dnsmetrics.UniFiTLSVerificationDisabled.Set(0)
+		slog.Info("verifying UniFi controller certificate",
+			"ca_file", cfg.CAFile,
+			"pinned_fingerprint", cfg.TLSFingerprint != "")
+	}
+
+	tlsConfig, err := unificlient.NewTLSConfig(trust)
+	if err != nil {
+		return nil, err
+	}
+
+	sessionAuth := cfg.Username != ""
+	useGoUniFi := !sessionAuth && !cfg.SelfHosted && trust.CAFile == "" && trust.Fingerprint == ""
+
+	// credential is the API key, or the password with session authentication
+	build := func(credential string) (unifi.NetworkAPIClient, error) {
+		if useGoUniFi {
+			return unifi.NewWithConfig(&unifi.ClientConfig{
+				ControllerURL:      cfg.Host,
+				APIKey:             credential,
+				InsecureSkipVerify: cfg.SkipTLSVerify,
+				Logger:             logger,
+				Metrics:            metrics,
+			})
+		}
+
+		clientConfig := &unificlient.Config{
+			ControllerURL: cfg.Host,
+			TLSConfig:     tlsConfig,
+			SelfHosted:    cfg.SelfHosted,
+			Metrics:       metrics,
+		}
+
+		if sessionAuth {
+			clientConfig.Username = cfg.Username
+			clientConfig.Password = credential
+		} else {
+			clientConfig.APIKey = credential
+		}
+
+		return unificlient.New(clientConfig)
+	}
+
+	credential, credentialFile := cfg.APIKey, cfg.APIKeyFile
+	if sessionAuth {
+		credential, credentialFile = cfg.Password, cfg.PasswordFile
+
+		slog.Info("using username/password session authentication",
+			"username", cfg.Username,
+			"self_hosted", cfg.SelfHosted)
+	}
+
+	if credentialFile == "" {
+		client, err := build(credential)
+		if err == nil {
+			dnsmetrics.UniFiCredentialsLastReload.SetToCurrentTime()
+		}
+
+		return client, err
+	}
+
+	source, err := secret.NewFile(credentialFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load UniFi credential")
+	}
+
+	rotating, err := unificlient.NewRotating(source, build)
+	if err != nil {
+		return nil, err
+	}
+
+	go rotating.Watch(ctx)
+
+	slog.Info("watching UniFi credential file for rotation", "file", credentialFile)
+
+	return rotating, nil
+}
+
+// newServerTLSConfig returns a reloading TLS configuration, or nil if no certificate is configured.
+func newServerTLSConfig(certFile, keyFile, clientCAFile string) (*tls.Config, error) {
+	if certFile == "" {
+		return nil, nil
+	}
+
+	tlsServer, err := tlsconfig.NewServer(certFile, keyFile, clientCAFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return tlsServer.Config(), nil
+}
+
+// listenAndServe serves HTTPS when the server has a TLS configuration and plain HTTP otherwise.
+func listenAndServe(srv *http.Server) error {
+	if srv.TLSConfig != nil {
+		// Certificates come from TLSConfig.GetConfigForClient
+		return srv.ListenAndServeTLS("", "")
+	}
+
+	return srv.ListenAndServe()
+}
+
+// newAuthenticator creates the webhook authenticator, or returns nil if no secrets are configured.
+func newAuthenticator(cfg config.ServerConfig) (*middleware.Authenticator, error) {
+	if cfg.AuthTokenFile == "" && cfg.AuthHMACKeyFile == "" {
+		return nil, nil
+	}
+
+	var token, hmacKey *secret.File
+
+	var err error
+
+	if cfg.AuthTokenFile != "" {
+		token, err = secret.NewFile(cfg.AuthTokenFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load webhook auth token")
+		}
+	}
+
+	if cfg.AuthHMACKeyFile != "" {
+		hmacKey, err = secret.NewFile(cfg.AuthHMACKeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to load webhook HMAC key")
+		}
+	}
+
+	slog.Info("webhook authentication enabled",
+		"bearer_token", token != nil,
+		"hmac_signature", hmacKey != nil)
+
+	return middleware.NewAuthenticator(token, hmacKey), nil
+}
+
+// adminAllowed reports whether the administrative endpoints may be served: callers
+// must authenticate with a token, an HMAC signature or a client certificate, or the
+// webhook must only listen on the loopback interface.
+func adminAllowed(cfg config.ServerConfig) bool {
+	authenticated := cfg.AuthTokenFile != "" || cfg.AuthHMACKeyFile != "" ||
+		(cfg.TLSCertFile != "" && cfg.TLSClientCAFile != "")
+
+	return authenticated || loopbackHost(cfg.Host)
+}
+
+// loopbackHost reports whether host only accepts connections from the local machine.
+func loopbackHost(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+
+	addr, err := netip.ParseAddr(host)
+
+	return err == nil && addr.IsLoopback()
+}
+
+// newFreeze creates the freeze controller; changes queued while frozen are applied through prov.
+// The settings were validated when the configuration was loaded.
+func newFreeze(prov provider.DNSProvider, cfg config.FreezeConfig) (*freeze.Controller, error) {
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to load freeze time zone")
+	}
+
+	controller, err := freeze.New(prov, freeze.Mode(cfg.Mode), cfg.Enabled, cfg.Windows, freeze.WithLocation(location))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create freeze controller")
+	}
+
+	return controller, nil
+}
+
+// newDomainFilter creates the domain filter from configuration.
+func newDomainFilter(cfg config.DomainFilterConfig) *endpoint.DomainFilter {
+	return endpoint.NewDomainFilterWithExclusions(cfg.Filters, cfg.ExcludeFilters)
+}
+
+// newClientSyncer creates the syncer publishing records for connected UniFi clients.
+// The settings were validated when the configuration was loaded.
+func newClientSyncer(client unifi.NetworkAPIClient, cfg *config.Config, prov *provider.UniFiProvider, applier provider.DNSProvider, frozen func() bool) *autorecords.Syncer {
+	filter := autorecords.ClientFilter{Include: cfg.Clients.Include, Exclude: cfg.Clients.Exclude}
+
+	for _, network := range cfg.Clients.Networks {
+		filter.Networks = append(filter.Networks, netip.MustParsePrefix(network))
+	}
+
+	sources := []autorecords.Source{autorecords.NewClients(client, cfg.UniFi.Site, filter)}
+
+	return autorecords.New(prov, cfg.Clients.Domain, sources,
+		autorecords.WithTTL(cfg.Clients.TTL),
+		autorecords.WithGracePeriod(cfg.Clients.GracePeriod),
+		autorecords.WithFreeze(frozen),
+		autorecords.WithApplier(applier),
+		autorecords.WithState(cfg.Clients.StateFile))
+}
+
+// runDockerSyncer publishes records for labeled containers, syncing on every
+// container start and stop in between full syncs.
+func runDockerSyncer(ctx context.Context, cfg *config.Config, prov *provider.UniFiProvider, applier provider.DNSProvider, frozen func() bool) {
+	var opts []docker.Option
+
+	if cfg.Docker.Target != "" {
+		opts = append(opts, docker.WithTarget(netip.MustParseAddr(cfg.Docker.Target)))
+	}
+
+	source := docker.New(cfg.Docker.Socket, cfg.Docker.Domain, opts...)
+
+	syncer := autorecords.New(prov, cfg.Docker.Domain, []autorecords.Source{source},
+		autorecords.WithTTL(cfg.Docker.TTL),
+		autorecords.WithGracePeriod(cfg.Docker.GracePeriod),
+		autorecords.WithFreeze(frozen),
+		autorecords.WithApplier(applier),
+		autorecords.WithState(cfg.Docker.StateFile))
+
+	go source.Watch(ctx, syncer.Trigger)
+
+	syncer.Run(ctx, cfg.Docker.Interval)
+}
+
+// newProtection creates the protection rules for records the webhook must never touch.
+func newProtection(cfg config.ProtectionConfig) (*provider.Protection, error) {
+	protection, err := provider.NewProtection(cfg.Names, cfg.RegexNames, cfg.RecordIDs, cfg.HideProtected)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create record protection")
+	}
+
+	return protection, nil
+}
+
+// newTargetPolicy creates the policy for the addresses records may point to.
+func newTargetPolicy(cfg config.TargetPolicyConfig) (*provider.TargetPolicy, error) {
+	policy, err := provider.NewTargetPolicy(cfg.Allow, cfg.Deny, cfg.Rules, cfg.DropViolations)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create target policy")
+	}
+
+	return policy, nil
+}
+
+// newVerifier creates the check that applied records are served, or nil when no server is configured.
+func newVerifier(cfg config.VerifyConfig) *verify.Verifier {
+	if cfg.Server == "" {
+		return nil
+	}
+
+	return verify.New(cfg.Server,
+		verify.WithTimeout(cfg.Timeout),
+		verify.WithRetries(cfg.Retries),
+		verify.WithInterval(cfg.Interval))
+}
+
+// newPointers creates the manager of reverse records for A and AAAA records, or nil when no reverse zones are configured.
+func newPointers(cfg config.PTRConfig, client unifi.NetworkAPIClient, site string) (*ptr.Manager, error) {
+	if len(cfg.Zones) == 0 {
+		return nil, nil //nolint:nilnil // No manager when PTR records are disabled
+	}
+
+	var sink ptr.Sink = ptr.NewUniFiSink(client, site)
+	if cfg.Sink == "file" {
+		sink = ptr.NewFileSink(cfg.File)
+	}
+
+	pointers, err := ptr.New(cfg.Zones, sink)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create PTR records")
+	}
+
+	return pointers, nil
+}
+
+// newRewrites creates the rewrite rules between the names external-dns sees and the names stored in UniFi.
+func newRewrites(cfg config.RewriteConfig) (*provider.Rewrites, error) {
+	rewrites, err := provider.NewRewrites(cfg.Suffixes, cfg.Regexes)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create name rewrites")
+	}
+
+	return rewrites, nil
+}
+
+// newTargetRewrites creates the rewrite rules between the targets external-dns sees and the targets stored in UniFi.
+func newTargetRewrites(cfg config.RewriteConfig) (*provider.TargetRewrites, error) {
+	targets, err := provider.NewTargetRewrites(cfg.Targets, cfg.TargetsStateFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create target rewrites")
+	}
+
+	return targets, nil
+}
+
+// logLevel is shared by all log handlers so that reloading the configuration can change it.
+var logLevel = new(slog.LevelVar)
+
+func parseLogLevel(level string) slog.Level {
+	switch level {
 	case "debug":
-		level = slog.LevelDebug
+		return slog.LevelDebug
 	case "info":
-		level = slog.LevelInfo
+		return slog.LevelInfo
 	case "warn":
-		level = slog.LevelWarn
+		return slog.LevelWarn
 	case "error":
-		level = slog.LevelError
+		return slog.LevelError
 	default:
-		level = slog.LevelInfo
+		return slog.LevelInfo
 	}
+}
+
+func setupLogging(cfg config.LoggingConfig) {
+	logLevel.Set(parseLogLevel(cfg.Level))
 
 	var handler slog.Handler
-	opts := &slog.HandlerOptions{Level: level}
+	opts := &slog.HandlerOptions{Level: logLevel}
 
 	switch cfg.Format {
 	case "json":
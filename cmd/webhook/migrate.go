@@ -0,0 +1,102 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"path/filepath"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/migrate"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+)
+
+// runMigrate creates records from Pi-hole, AdGuard Home, dnsmasq and hosts files in UniFi.
+// Records that already exist are left alone; conflicting ones are reported and not created.
+func runMigrate(args []string) error {
+	flags := newFlagSet("migrate")
+	sources := map[migrate.Source]*[]string{
+		migrate.SourcePihole:  flags.StringArray("pihole", nil, "Pi-hole custom.list or CNAME file (repeatable)"),
+		migrate.SourceAdGuard: flags.StringArray("adguard", nil, "AdGuardHome.yaml or a YAML list of rewrites (repeatable)"),
+		migrate.SourceDnsmasq: flags.StringArray("dnsmasq", nil, "dnsmasq configuration file (repeatable)"),
+		migrate.SourceHosts:   flags.StringArray("hosts", nil, "hosts file (repeatable)"),
+	}
+	dryRun := flags.Bool("dry-run", false, "print the migration plan without creating records")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	var parsed []*migrate.Parsed
+
+	for _, source := range []migrate.Source{migrate.SourcePihole, migrate.SourceAdGuard, migrate.SourceDnsmasq, migrate.SourceHosts} {
+		for _, path := range *sources[source] {
+			result, err := parseSource(source, path)
+			if err != nil {
+				return err
+			}
+
+			parsed = append(parsed, result)
+		}
+	}
+
+	if len(parsed) == 0 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("usage: migrate [--pihole FILE] [--adguard FILE] [--dnsmasq FILE] [--hosts FILE] [--dry-run]")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordsTimeout)
+	defer cancel()
+
+	env, err := newCommandEnv(ctx, flags)
+	if err != nil {
+		return err
+	}
+
+	filter := env.provider.GetDomainFilter()
+	for _, result := range parsed {
+		result.Exclude(filter.Match, "is outside the domain filters")
+	}
+
+	current, err := env.provider.Records(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read records")
+	}
+
+	migration := migrate.NewPlan(records.FromEndpoints(current), parsed...)
+
+	err = migration.Write(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun || len(migration.Create) == 0 {
+		return nil
+	}
+
+	err = refuseWhenFrozen(env.provider, env.config)
+	if err != nil {
+		return err
+	}
+
+	err = env.provider.ApplyChanges(ctx, migration.ProviderChanges())
+	if err != nil {
+		return errors.Wrap(err, "failed to create records")
+	}
+
+	slog.Info("records migrated", "created", len(migration.Create), "conflicts", len(migration.Conflicts))
+
+	return nil
+}
+
+// parseSource reads one source file.
+func parseSource(source migrate.Source, path string) (*migrate.Parsed, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open %s file", source)
+	}
+	defer file.Close()
+
+	return migrate.Parse(file, source, filepath.Base(path))
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/config"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+)
+
+// recordsTimeout bounds a records export or import.
+const recordsTimeout = 5 * time.Minute
+
+// runRecords dispatches the records export and import subcommands.
+func runRecords(args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "export":
+			return runRecordsExport(args[1:])
+		case "import":
+			return runRecordsImport(args[1:])
+		}
+	}
+
+	//nolint:wrapcheck // Creating new error, not wrapping
+	return errors.New("usage: records export|import [flags]")
+}
+
+// runRecordsExport writes the records the webhook manages, after domain filters, to a file or stdout.
+func runRecordsExport(args []string) error {
+	flags := newFlagSet("records export")
+	format := flags.String("format", "", "output format: json, yaml or zone (default: from --output extension, else yaml)")
+	output := flags.StringP("output", "o", "", "file to write, stdout when empty")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	recordFormat, err := recordsFormat(*format, *output, records.FormatYAML)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordsTimeout)
+	defer cancel()
+
+	env, err := newCommandEnv(ctx, flags)
+	if err != nil {
+		return err
+	}
+
+	endpoints, err := env.provider.Records(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read records")
+	}
+
+	var writer io.Writer = os.Stdout
+
+	if *output != "" {
+		file, err := os.Create(*output)
+		if err != nil {
+			return errors.Wrap(err, "failed to create output file")
+		}
+		defer file.Close()
+
+		writer = file
+	}
+
+	exported := records.FromEndpoints(endpoints)
+
+	err = records.Encode(writer, recordFormat, exported)
+	if err != nil {
+		return err
+	}
+
+	slog.Info("records exported", "records", len(exported), "format", recordFormat)
+
+	return nil
+}
+
+// runRecordsImport applies records from a file through the provider after printing a diff preview.
+func runRecordsImport(args []string) error {
+	flags := newFlagSet("records import")
+	format := flags.String("format", "", "input format: json, yaml or zone (default: from the file extension)")
+	dryRun := flags.Bool("dry-run", false, "print the changes without applying them")
+	prune := flags.Bool("prune", false, "delete records within the domain filters that are missing from the file")
+
+	err := parseFlags(flags, args)
+	if err != nil {
+		return ignoreHelp(err)
+	}
+
+	if flags.NArg() != 1 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("usage: records import [flags] FILE")
+	}
+
+	path := flags.Arg(0)
+
+	recordFormat, err := recordsFormat(*format, path, "")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open records file")
+	}
+	defer file.Close()
+
+	desired, err := records.Decode(file, recordFormat)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), recordsTimeout)
+	defer cancel()
+
+	env, err := newCommandEnv(ctx, flags)
+	if err != nil {
+		return err
+	}
+
+	prov, cfg := env.provider, env.config
+
+	desired, skipped := filterDesired(prov, desired)
+	for _, record := range skipped {
+		slog.Warn("record is outside the domain filters, skipping", "name", record.Name, "type", record.Type)
+	}
+
+	current, err := prov.Records(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read records")
+	}
+
+	changes := records.Diff(records.FromEndpoints(current), desired, *prune)
+
+	err = changes.Write(os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	if *dryRun || changes.Empty() {
+		return nil
+	}
+
+	err = refuseWhenFrozen(prov, cfg)
+	if err != nil {
+		return err
+	}
+
+	err = prov.ApplyChanges(ctx, changes.ProviderChanges())
+	if err != nil {
+		return errors.Wrap(err, "failed to apply records")
+	}
+
+	slog.Info("records imported", "changes", len(changes.Changes))
+
+	return nil
+}
+
+// refuseWhenFrozen fails while the configured freeze or a freeze window is active,
+// so offline commands honor maintenance windows like the webhook server does.
+func refuseWhenFrozen(prov *provider.UniFiProvider, cfg *config.Config) error {
+	freezeCtrl, err := newFreeze(prov, cfg.Freeze)
+	if err != nil {
+		return err
+	}
+
+	if freezeCtrl.Frozen() {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.Newf("DNS changes are frozen by %v, not applying", freezeCtrl.Sources())
+	}
+
+	return nil
+}
+
+// recordsFormat picks the format from the flag, then the file extension, then the fallback.
+func recordsFormat(name, path string, fallback records.Format) (records.Format, error) {
+	switch {
+	case name != "":
+		return records.ParseFormat(name)
+	case path == "" && fallback != "":
+		return fallback, nil
+	default:
+		return records.FormatFromPath(path)
+	}
+}
+
+// filterDesired drops records outside the provider's domain filters, which the
+// provider neither reports nor should change.
+func filterDesired(prov *provider.UniFiProvider, desired []records.Record) ([]records.Record, []records.Record) {
+	filter := prov.GetDomainFilter()
+	kept := make([]records.Record, 0, len(desired))
+
+	var skipped []records.Record
+
+	for _, record := range desired {
+		if filter.Match(record.Name) {
+			kept = append(kept, record)
+		} else {
+			skipped = append(skipped, record)
+		}
+	}
+
+	return kept, skipped
+}
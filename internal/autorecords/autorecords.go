@@ -0,0 +1,562 @@
+// Package autorecords publishes DNS records for hosts the UniFi controller knows
+// about, such as network clients and infrastructure devices, without external-dns.
+//
+// A Syncer creates records for the hosts its sources list one label below its
+// domain and deletes the records it created once their host is gone. It only
+// touches the records it created, which it remembers in a state file if
+// configured; records created by hand are left alone, and hosts whose names they
+// use are not published. Changes go through the provider, so protection rules,
+// static records, backups and drift detection apply to them like to changes from
+// external-dns.
+package autorecords
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// DefaultTTL is the TTL of published records unless configured otherwise.
+const DefaultTTL = 300
+
+// maxLabelLength is the longest DNS label allowed by RFC 1035.
+const maxLabelLength = 63
+
+// Provider reads and changes DNS records; *provider.UniFiProvider implements it.
+type Provider interface {
+	RecordsIn(ctx context.Context, domainFilter endpoint.DomainFilter) ([]*endpoint.Endpoint, error)
+	Applier
+}
+
+// Applier applies DNS record changes, such as the provider guarded by static records.
+type Applier interface {
+	ApplyChanges(ctx context.Context, changes *plan.Changes) error
+}
+
+// Host is a name and address to publish.
+type Host struct {
+	// Name is a single DNS label, see Label.
+	Name    string
+	Address netip.Addr
+}
+
+// Source lists hosts to publish.
+type Source interface {
+	// Name identifies the source in logs and metrics.
+	Name() string
+	// Hosts returns the hosts to publish, preferred hosts first when names collide.
+	Hosts(ctx context.Context) ([]Host, error)
+}
+
+// Syncer keeps the records below a domain in line with the hosts its sources list.
+type Syncer struct {
+	provider Provider
+	applier  Applier
+	domain   string
+	sources  []Source
+	ttl      int64
+	grace    time.Duration
+	frozen   func() bool
+	now      func() time.Time
+	state    string
+	trigger  chan struct{}
+
+	mu sync.Mutex
+	// seen holds every record published within the grace period, keyed by name and type.
+	seen map[string]published
+	// owned holds the name and type of every record the syncer created; nil until loaded.
+	owned map[string]bool
+	// conflicts holds the names of listed hosts already used by other records, to log them once.
+	conflicts map[string]bool
+	dirty     bool
+}
+
+// ownedState is the set of created records as written to the state file.
+type ownedState struct {
+	Records []ownedRecord `json:"records"`
+}
+
+// ownedRecord identifies a created record.
+type ownedRecord struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+}
+
+// published is a record and when its host was last listed.
+type published struct {
+	record   records.Record
+	lastSeen time.Time
+}
+
+// Option configures optional Syncer behavior.
+type Option func(*Syncer)
+
+// WithTTL sets the TTL of published records.
+func WithTTL(ttl int) Option {
+	return func(s *Syncer) {
+		s.ttl = int64(ttl)
+	}
+}
+
+// WithGracePeriod keeps a record that long after its host was last listed,
+// so names survive short disconnects.
+func WithGracePeriod(grace time.Duration) Option {
+	return func(s *Syncer) {
+		s.grace = grace
+	}
+}
+
+// WithFreeze suspends changes while frozen reports true.
+func WithFreeze(frozen func() bool) Option {
+	return func(s *Syncer) {
+		s.frozen = frozen
+	}
+}
+
+// WithApplier applies changes through applier instead of the provider, such as
+// the provider guarded by static records. Records are still read from the provider.
+func WithApplier(applier Applier) Option {
+	return func(s *Syncer) {
+		s.applier = applier
+	}
+}
+
+// WithState remembers the records the syncer created in the file at path, so they
+// are updated and deleted after a restart. Without it, created records are only
+// recognized after a restart while their host is listed with the same address.
+func WithState(path string) Option {
+	return func(s *Syncer) {
+		s.state = path
+	}
+}
+
+// New creates a syncer publishing the hosts of sources below domain through prov.
+func New(prov Provider, domain string, sources []Source, opts ...Option) *Syncer {
+	syncer := &Syncer{
+		provider:  prov,
+		applier:   prov,
+		domain:    strings.ToLower(strings.TrimSuffix(domain, ".")),
+		sources:   sources,
+		ttl:       DefaultTTL,
+		frozen:    func() bool { return false },
+		now:       time.Now,
+		trigger:   make(chan struct{}, 1),
+		conflicts: make(map[string]bool),
+	}
+
+	for _, opt := range opts {
+		opt(syncer)
+	}
+
+	return syncer
+}
+
+// Trigger makes Run sync right away instead of waiting for the next interval,
+// for sources that learn about changes as they happen.
+func (s *Syncer) Trigger() {
+	select {
+	case s.trigger <- struct{}{}:
+	default:
+		// A sync is already pending
+	}
+}
+
+// Run reconciles every interval, and whenever Trigger is called, until ctx is done.
+func (s *Syncer) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.Sync(ctx)
+		if err != nil {
+			slog.WarnContext(ctx, "failed to sync automatic DNS records", "domain", s.domain, "error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.trigger:
+		}
+	}
+}
+
+// Sync lists the hosts once and applies the changes needed to publish them.
+// If any source fails, nothing is changed, so an unreachable controller never
+// deletes records.
+func (s *Syncer) Sync(ctx context.Context) error {
+	err := s.sync(ctx)
+
+	switch {
+	case errors.Is(err, errFrozen):
+		dnsmetrics.AutoRecordSyncs.WithLabelValues("frozen").Inc()
+
+		return nil
+	case err != nil:
+		dnsmetrics.AutoRecordSyncs.WithLabelValues("error").Inc()
+
+		return err
+	}
+
+	dnsmetrics.AutoRecordSyncs.WithLabelValues("success").Inc()
+
+	return nil
+}
+
+// errFrozen reports a sync skipped because DNS changes are frozen.
+var errFrozen = errors.New("DNS changes are frozen")
+
+func (s *Syncer) sync(ctx context.Context) error {
+	listed := make(map[string]records.Record)
+
+	for _, source := range s.sources {
+		hosts, err := source.Hosts(ctx)
+		if err != nil {
+			return errors.Wrapf(err, "failed to list %s", source.Name())
+		}
+
+		added := s.add(ctx, listed, hosts)
+		dnsmetrics.AutoRecords.WithLabelValues(source.Name()).Set(float64(added))
+	}
+
+	endpoints, err := s.provider.RecordsIn(ctx, *endpoint.NewDomainFilter([]string{s.domain}))
+	if err != nil {
+		return errors.Wrap(err, "failed to read records")
+	}
+
+	err = s.load()
+	if err != nil {
+		return err
+	}
+
+	current, foreign := s.split(ctx, records.FromEndpoints(endpoints), listed)
+	desired := s.remember(current, listed, foreign)
+
+	changes := records.Diff(current, desired, true)
+	if changes.Empty() {
+		s.own(ctx, desired, foreign, nil)
+
+		return nil
+	}
+
+	if s.frozen() {
+		slog.InfoContext(ctx, "not syncing automatic DNS records while DNS changes are frozen",
+			"domain", s.domain, "changes", len(changes.Changes))
+
+		// Records taken over are owned even though nothing was applied
+		s.save(ctx)
+
+		return errFrozen
+	}
+
+	slog.InfoContext(ctx, "syncing automatic DNS records", "domain", s.domain, "changes", len(changes.Changes))
+
+	err = s.applier.ApplyChanges(ctx, changes.ProviderChanges())
+	s.own(ctx, desired, foreign, err)
+
+	return errors.Wrap(err, "failed to apply changes")
+}
+
+// add puts the records for hosts into listed and returns how many hosts were added.
+// A name and type already listed keeps its first address.
+func (s *Syncer) add(ctx context.Context, listed map[string]records.Record, hosts []Host) int {
+	added := 0
+
+	for _, host := range hosts {
+		recordType := endpoint.RecordTypeA
+		if host.Address.Is6() && !host.Address.Is4In6() {
+			recordType = endpoint.RecordTypeAAAA
+		}
+
+		record := records.Record{
+			Name:    host.Name + "." + s.domain,
+			Type:    recordType,
+			TTL:     s.ttl,
+			Targets: []string{host.Address.Unmap().String()},
+		}
+
+		if existing, ok := listed[key(record)]; ok {
+			slog.DebugContext(ctx, "skipping host with a name already in use",
+				"name", record.Name, "address", record.Targets[0], "published", existing.Targets[0])
+
+			continue
+		}
+
+		listed[key(record)] = record
+		added++
+	}
+
+	return added
+}
+
+// split returns the current records the syncer owns and the names it must not touch.
+// Since the provider replaces all records of a name, a name is only owned if all its
+// records are. Records that are exactly what the syncer would publish for a listed
+// host are taken over, so its records are recognized without a state file.
+func (s *Syncer) split(ctx context.Context, current []records.Record, listed map[string]records.Record) ([]records.Record, map[string]bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	byName := make(map[string][]records.Record)
+	for _, record := range current {
+		byName[record.Name] = append(byName[record.Name], record)
+	}
+
+	var owned []records.Record
+
+	foreign := make(map[string]bool)
+
+	for name, named := range byName {
+		if !slices.ContainsFunc(named, func(record records.Record) bool {
+			listedRecord, ok := listed[key(record)]
+
+			return !s.owned[key(record)] && (!ok || !sameTargets(record, listedRecord))
+		}) {
+			for _, record := range named {
+				if !s.owned[key(record)] {
+					s.owned[key(record)] = true
+					s.dirty = true
+				}
+			}
+
+			owned = append(owned, named...)
+
+			continue
+		}
+
+		foreign[name] = true
+	}
+
+	// Hosts using the names of other records are not published
+	conflicts := make(map[string]bool)
+
+	for _, record := range listed {
+		if !foreign[record.Name] {
+			continue
+		}
+
+		if !s.conflicts[record.Name] {
+			slog.WarnContext(ctx, "not publishing host whose name is used by other DNS records",
+				"name", record.Name, "address", record.Targets[0])
+		}
+
+		conflicts[record.Name] = true
+	}
+
+	s.conflicts = conflicts
+
+	return owned, foreign
+}
+
+// sameTargets reports whether two records point to the same addresses.
+func sameTargets(left, right records.Record) bool {
+	return slices.Equal(slices.Sorted(slices.Values(left.Targets)), slices.Sorted(slices.Values(right.Targets)))
+}
+
+// remember updates when each record was last listed and returns the records to publish:
+// those listed now and those listed within the grace period, except for foreign names.
+func (s *Syncer) remember(current []records.Record, listed map[string]records.Record, foreign map[string]bool) []records.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+
+	// After a restart, owned records already in UniFi get a full grace period
+	if s.seen == nil {
+		s.seen = make(map[string]published, len(current))
+
+		for _, record := range current {
+			s.seen[key(record)] = published{record: record, lastSeen: now}
+		}
+	}
+
+	for recordKey, record := range listed {
+		if !foreign[record.Name] {
+			s.seen[recordKey] = published{record: record, lastSeen: now}
+		}
+	}
+
+	desired := make([]records.Record, 0, len(s.seen))
+
+	for recordKey, entry := range s.seen {
+		if now.Sub(entry.lastSeen) > s.grace || foreign[entry.record.Name] {
+			delete(s.seen, recordKey)
+
+			continue
+		}
+
+		desired = append(desired, entry.record)
+	}
+
+	return desired
+}
+
+// own updates the owned records after applying changes towards desired and saves them.
+// Owned records of foreign names are kept, and after a failure so are all previously
+// owned ones, since some of their deletes may not have been applied.
+func (s *Syncer) own(ctx context.Context, desired []records.Record, foreign map[string]bool, err error) {
+	s.mu.Lock()
+
+	next := make(map[string]bool, len(desired))
+
+	for _, record := range desired {
+		next[key(record)] = true
+	}
+
+	for recordKey := range s.owned {
+		name, _, _ := strings.Cut(recordKey, "/")
+		if err != nil || foreign[name] {
+			next[recordKey] = true
+		}
+	}
+
+	if !maps.Equal(next, s.owned) {
+		s.owned = next
+		s.dirty = true
+	}
+
+	s.mu.Unlock()
+
+	s.save(ctx)
+}
+
+// load reads the owned records from the state file the first time it is called.
+func (s *Syncer) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.owned != nil {
+		return nil
+	}
+
+	owned := make(map[string]bool)
+
+	if s.state != "" {
+		content, err := os.ReadFile(s.state)
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return errors.Wrap(err, "failed to read automatic records state")
+		}
+
+		if err == nil {
+			var state ownedState
+
+			err = json.Unmarshal(content, &state)
+			if err != nil {
+				return errors.Wrapf(err, "failed to decode automatic records state %s", s.state)
+			}
+
+			for _, record := range state.Records {
+				owned[record.Name+"/"+record.Type] = true
+			}
+		}
+	}
+
+	s.owned = owned
+
+	return nil
+}
+
+// save writes the owned records to the state file if they changed, logging failures,
+// which must not fail changes that were already applied.
+func (s *Syncer) save(ctx context.Context) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.state == "" || !s.dirty {
+		return
+	}
+
+	state := ownedState{Records: make([]ownedRecord, 0, len(s.owned))}
+
+	for _, recordKey := range slices.Sorted(maps.Keys(s.owned)) {
+		name, recordType, _ := strings.Cut(recordKey, "/")
+		state.Records = append(state.Records, ownedRecord{Name: name, Type: recordType})
+	}
+
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode automatic records state", "error", err)
+
+		return
+	}
+
+	// Written to a temporary file and renamed into place, so a crash never leaves a truncated file
+	temp, err := os.CreateTemp(filepath.Dir(s.state), ".autorecords-*.tmp")
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create automatic records state", "error", err)
+
+		return
+	}
+
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Close()
+	} else {
+		_ = temp.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), s.state)
+	}
+
+	if err != nil {
+		_ = os.Remove(temp.Name())
+
+		slog.ErrorContext(ctx, "failed to write automatic records state", "error", err)
+
+		return
+	}
+
+	s.dirty = false
+}
+
+// Label turns a host name into a DNS label: lowercase letters, digits and
+// hyphens, at most 63 characters. It returns "" if nothing usable remains.
+func Label(name string) string {
+	var builder strings.Builder
+
+	hyphen := false
+
+	for _, char := range strings.ToLower(name) {
+		if (char >= 'a' && char <= 'z') || (char >= '0' && char <= '9') {
+			builder.WriteRune(char)
+
+			hyphen = false
+
+			continue
+		}
+
+		// Any run of other characters becomes a single hyphen
+		if !hyphen && builder.Len() > 0 {
+			builder.WriteByte('-')
+
+			hyphen = true
+		}
+	}
+
+	label := builder.String()
+	if len(label) > maxLabelLength {
+		label = label[:maxLabelLength]
+	}
+
+	return strings.TrimRight(label, "-")
+}
+
+// key identifies a record by name and type.
+func key(record records.Record) string {
+	return record.Name + "/" + record.Type
+}
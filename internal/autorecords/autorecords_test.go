@@ -0,0 +1,352 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package autorecords
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeProvider keeps endpoints in memory.
+type fakeProvider struct {
+	mu        sync.Mutex
+	endpoints []*endpoint.Endpoint
+	applied   int
+}
+
+func (f *fakeProvider) RecordsIn(_ context.Context, domainFilter endpoint.DomainFilter) ([]*endpoint.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*endpoint.Endpoint
+
+	for _, item := range f.endpoints {
+		if domainFilter.Match(item.DNSName) {
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
+
+func (f *fakeProvider) ApplyChanges(_ context.Context, changes *plan.Changes) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.applied++
+
+	for _, removed := range slices.Concat(changes.Delete, changes.UpdateOld) {
+		f.endpoints = slices.DeleteFunc(f.endpoints, func(item *endpoint.Endpoint) bool {
+			return item.DNSName == removed.DNSName
+		})
+	}
+
+	f.endpoints = append(f.endpoints, slices.Concat(changes.Create, changes.UpdateNew)...)
+
+	return nil
+}
+
+// names returns the name, type and targets of every endpoint, sorted.
+func (f *fakeProvider) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := make([]string, 0, len(f.endpoints))
+	for _, item := range f.endpoints {
+		result = append(result, item.DNSName+" "+item.RecordType+" "+item.Targets.String())
+	}
+
+	slices.Sort(result)
+
+	return result
+}
+
+// fakeClient serves one site and its clients; other API methods are not used.
+type fakeClient struct {
+	unifi.NetworkAPIClient
+
+	siteID  unifi.SiteId
+	clients []unifi.ClientListItem
+	devices []unifi.DeviceListItem
+	err     error
+}
+
+func (f *fakeClient) ListSites(context.Context, *unifi.ListSitesParams) (*unifi.SitesResponse, error) {
+	return &unifi.SitesResponse{
+		Data:       []unifi.SiteListItem{{Id: f.siteID, InternalReference: "default", Name: "Default"}},
+		TotalCount: 1,
+	}, nil
+}
+
+func (f *fakeClient) ListSiteClients(_ context.Context, siteID unifi.SiteId, params *unifi.ListSiteClientsParams) (*unifi.ClientsResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+
+	if siteID != f.siteID {
+		return nil, errors.New("unknown site")
+	}
+
+	// Serve one client per page to exercise pagination
+	offset := *params.Offset
+	if offset >= len(f.clients) {
+		return &unifi.ClientsResponse{TotalCount: len(f.clients)}, nil
+	}
+
+	return &unifi.ClientsResponse{Data: f.clients[offset : offset+1], TotalCount: len(f.clients)}, nil
+}
+
+func (f *fakeClient) ListSiteDevices(context.Context, unifi.SiteId, *unifi.ListSiteDevicesParams) (*unifi.DevicesResponse, error) {
+	return &unifi.DevicesResponse{Data: f.devices, TotalCount: len(f.devices)}, nil
+}
+
+func TestLabel(t *testing.T) {
+	t.Parallel()
+
+	for name, expected := range map[string]string{
+		"Kitchen iPad":                 "kitchen-ipad",
+		"printer_01":                   "printer-01",
+		"  --Living Room TV-- ":        "living-room-tv",
+		"ESP_3A4B5C.local":             "esp-3a4b5c-local",
+		"Jörg's Phone":                 "j-rg-s-phone",
+		"???":                          "",
+		"":                             "",
+		strings.Repeat("a", 62) + "-b": strings.Repeat("a", 62),
+	} {
+		assert.Equal(t, expected, Label(name), name)
+	}
+}
+
+func TestSync_PublishesClients(t *testing.T) {
+	t.Parallel()
+
+	connected := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	client := &fakeClient{
+		siteID: unifi.SiteId{1},
+		clients: []unifi.ClientListItem{
+			{Name: "Kitchen iPad", IpAddress: "192.168.1.21", ConnectedAt: connected.Add(-time.Hour)},
+			{Name: "kitchen-ipad", IpAddress: "192.168.1.20", ConnectedAt: connected},
+			{Name: "Printer", IpAddress: "fd00::5", ConnectedAt: connected},
+			{Name: "Blocked", IpAddress: "192.168.1.30", Access: unifi.ClientAccess{Type: unifi.BLOCKED}},
+			{Name: "Guest Phone", IpAddress: "192.168.50.3"},
+			{Name: "camera-1", IpAddress: "192.168.1.40"},
+			{Name: "No Address"},
+		},
+	}
+
+	prov := &fakeProvider{endpoints: []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("old.clients.home.lan", endpoint.RecordTypeA, 300, "192.168.1.99"),
+		endpoint.NewEndpoint("alias.clients.home.lan", endpoint.RecordTypeCNAME, "nas.home.lan"),
+		endpoint.NewEndpoint("host.lab.clients.home.lan", endpoint.RecordTypeA, "10.0.0.1"),
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeA, "192.168.1.10"),
+	}}
+
+	source := NewClients(client, "default", ClientFilter{
+		Networks: []netip.Prefix{netip.MustParsePrefix("192.168.1.0/24"), netip.MustParsePrefix("fd00::/64")},
+		Exclude:  []string{"camera-*"},
+	})
+
+	now := connected
+	syncer := New(prov, "clients.home.lan.", []Source{source}, WithGracePeriod(time.Hour))
+	syncer.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, syncer.Sync(ctx))
+
+	assert.Equal(t, []string{
+		"alias.clients.home.lan CNAME nas.home.lan",
+		"host.lab.clients.home.lan A 10.0.0.1",
+		"kitchen-ipad.clients.home.lan A 192.168.1.20",
+		"nas.home.lan A 192.168.1.10",
+		"old.clients.home.lan A 192.168.1.99",
+		"printer.clients.home.lan AAAA fd00::5",
+	}, prov.names(), "the most recent client wins a name, records created by hand are kept")
+
+	// Past the grace period, records of clients that are gone are deleted
+	now = now.Add(2 * time.Hour)
+	client.clients = client.clients[1:2]
+
+	require.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, []string{
+		"alias.clients.home.lan CNAME nas.home.lan",
+		"host.lab.clients.home.lan A 10.0.0.1",
+		"kitchen-ipad.clients.home.lan A 192.168.1.20",
+		"nas.home.lan A 192.168.1.10",
+		"old.clients.home.lan A 192.168.1.99",
+	}, prov.names())
+
+	applied := prov.applied
+	require.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, applied, prov.applied, "nothing is applied when records are in sync")
+}
+
+func TestSync_KeepsRecordsOnErrorsAndFreeze(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{siteID: unifi.SiteId{1}, clients: []unifi.ClientListItem{{Name: "Laptop", IpAddress: "192.168.1.20"}}}
+	prov := &fakeProvider{}
+
+	frozen := false
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	syncer := New(prov, "clients.home.lan", []Source{NewClients(client, "default", ClientFilter{})},
+		WithFreeze(func() bool { return frozen }))
+	syncer.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, []string{"laptop.clients.home.lan A 192.168.1.20"}, prov.names())
+
+	client.clients = nil
+	client.err = errors.New("controller unreachable")
+	now = now.Add(time.Minute)
+
+	require.Error(t, syncer.Sync(ctx))
+	assert.Len(t, prov.names(), 1, "a failed listing changes nothing")
+
+	client.err = nil
+	frozen = true
+
+	require.NoError(t, syncer.Sync(ctx), "a frozen sync is not an error")
+	assert.Equal(t, 1, prov.applied)
+
+	frozen = false
+
+	require.NoError(t, syncer.Sync(ctx))
+	assert.Empty(t, prov.names(), "the record of the gone client is deleted once changes are allowed")
+
+	missing := NewClients(client, "other", ClientFilter{})
+	_, err := missing.Hosts(ctx)
+	require.ErrorIs(t, err, ErrSiteNotFound)
+}
+
+func TestSync_PublishesDevices(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{
+		siteID: unifi.SiteId{1},
+		devices: []unifi.DeviceListItem{
+			{Name: "Lobby", IpAddress: "10.0.0.5", Features: []unifi.DeviceListItemFeatures{unifi.AccessPoint, unifi.Switching}},
+			{Name: "Core Switch", IpAddress: "10.0.0.2", Features: []unifi.DeviceListItemFeatures{unifi.Switching}},
+			{Name: "UDM Pro", IpAddress: "10.0.0.1", Features: []unifi.DeviceListItemFeatures{unifi.Gateway, unifi.Switching}},
+			{Name: "Lobby", IpAddress: "10.0.0.9", Features: []unifi.DeviceListItemFeatures{unifi.AccessPoint}, State: unifi.DeviceListItemStateOFFLINE},
+			{Name: "Adopting", Features: []unifi.DeviceListItemFeatures{unifi.AccessPoint}},
+		},
+	}
+
+	_, err := NewDevices(client, "default", "{{.Hostname}}")
+	require.Error(t, err, "unknown template fields are rejected up front")
+
+	source, err := NewDevices(client, "default", "{{.Role}}-{{.Name}}")
+	require.NoError(t, err)
+
+	prov := &fakeProvider{}
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	syncer := New(prov, "net.example.com", []Source{source})
+	syncer.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	require.NoError(t, syncer.Sync(ctx))
+
+	assert.Equal(t, []string{
+		"ap-lobby.net.example.com A 10.0.0.5",
+		"gateway-udm-pro.net.example.com A 10.0.0.1",
+		"switch-core-switch.net.example.com A 10.0.0.2",
+	}, prov.names(), "online devices win names over offline ones")
+
+	// Removed devices lose their records
+	client.devices = client.devices[:1]
+	now = now.Add(time.Minute)
+
+	require.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, []string{"ap-lobby.net.example.com A 10.0.0.5"}, prov.names())
+}
+
+// countingApplier counts change sets before passing them on, like the static records guard.
+type countingApplier struct {
+	next    Applier
+	applied int
+}
+
+func (c *countingApplier) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	c.applied++
+
+	return c.next.ApplyChanges(ctx, changes)
+}
+
+func TestSync_OnlyChangesOwnRecords(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{
+		siteID: unifi.SiteId{1},
+		clients: []unifi.ClientListItem{
+			{Name: "Laptop", IpAddress: "192.168.1.20"},
+			{Name: "NAS", IpAddress: "192.168.1.10"},
+			{Name: "Phone", IpAddress: "192.168.1.30"},
+		},
+	}
+
+	prov := &fakeProvider{endpoints: []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("laptop.clients.home.lan", endpoint.RecordTypeA, 300, "192.168.1.20"),
+		endpoint.NewEndpoint("nas.clients.home.lan", endpoint.RecordTypeA, "192.168.1.99"),
+		endpoint.NewEndpoint("phone.clients.home.lan", endpoint.RecordTypeTXT, "owner=alice"),
+		endpoint.NewEndpoint("printer.clients.home.lan", endpoint.RecordTypeA, "192.168.1.40"),
+	}}
+	handMade := []string{
+		"nas.clients.home.lan A 192.168.1.99",
+		"phone.clients.home.lan TXT owner=alice",
+		"printer.clients.home.lan A 192.168.1.40",
+	}
+
+	state := filepath.Join(t.TempDir(), "clients.json")
+	source := NewClients(client, "default", ClientFilter{})
+	applier := &countingApplier{next: prov}
+
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	syncer := New(prov, "clients.home.lan", []Source{source}, WithState(state), WithApplier(applier))
+	syncer.now = func() time.Time { return now }
+
+	// A record matching a listed client is taken over; names used by other records are left alone
+	ctx := context.Background()
+	require.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, slices.Concat([]string{"laptop.clients.home.lan A 192.168.1.20"}, handMade), prov.names())
+	assert.Zero(t, applier.applied)
+
+	client.clients = append(client.clients, unifi.ClientListItem{Name: "Camera", IpAddress: "192.168.1.50"})
+
+	require.NoError(t, syncer.Sync(ctx))
+	assert.Equal(t, 1, applier.applied, "changes go through the applier")
+	assert.Contains(t, prov.names(), "camera.clients.home.lan A 192.168.1.50")
+
+	content, err := os.ReadFile(state)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"records": [
+		{"name": "camera.clients.home.lan", "type": "A"},
+		{"name": "laptop.clients.home.lan", "type": "A"}
+	]}`, string(content))
+
+	// After a restart, the records in the state file are deleted once their clients are gone
+	client.clients = nil
+	restarted := New(prov, "clients.home.lan", []Source{source}, WithState(state))
+	restarted.now = func() time.Time { return now }
+
+	require.NoError(t, restarted.Sync(ctx))
+	assert.Len(t, prov.names(), 5, "owned records get a grace period after a restart")
+
+	now = now.Add(time.Minute)
+
+	require.NoError(t, restarted.Sync(ctx))
+	assert.Equal(t, handMade, prov.names())
+}
@@ -0,0 +1,118 @@
+package autorecords
+
+import (
+	"context"
+	"net/netip"
+	"path"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+// ClientFilter selects the clients to publish. Empty fields select everything.
+type ClientFilter struct {
+	// Networks are the subnets of the networks to publish. The integration API
+	// does not report a client's network or VLAN, so clients are matched by address.
+	Networks []netip.Prefix
+	// Include and Exclude are globs matched against the DNS label of the client.
+	Include []string
+	Exclude []string
+}
+
+// Clients lists the connected clients of a site.
+type Clients struct {
+	client unifi.NetworkAPIClient
+	site   *site
+	filter ClientFilter
+}
+
+// NewClients creates a source for the clients of site, the name used by the DNS API.
+func NewClients(client unifi.NetworkAPIClient, site string, filter ClientFilter) *Clients {
+	return &Clients{client: client, site: newSite(client, site), filter: filter}
+}
+
+// Name implements Source.
+func (c *Clients) Name() string {
+	return "clients"
+}
+
+// Hosts implements Source. Clients without an address, blocked clients and names
+// that are not valid DNS labels are skipped; the most recently connected client
+// comes first.
+func (c *Clients) Hosts(ctx context.Context) ([]Host, error) {
+	siteID, err := c.site.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var clients []unifi.ClientListItem
+
+	for offset := 0; ; {
+		page, err := c.client.ListSiteClients(ctx, siteID, &unifi.ListSiteClientsParams{
+			Offset: new(offset),
+			Limit:  new(pageSize),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list clients")
+		}
+
+		clients = append(clients, page.Data...)
+		offset += len(page.Data)
+
+		if len(page.Data) == 0 || offset >= page.TotalCount {
+			break
+		}
+	}
+
+	slices.SortStableFunc(clients, func(left, right unifi.ClientListItem) int {
+		return right.ConnectedAt.Compare(left.ConnectedAt)
+	})
+
+	hosts := make([]Host, 0, len(clients))
+
+	for _, item := range clients {
+		if item.Access.Type == unifi.BLOCKED {
+			continue
+		}
+
+		address, err := netip.ParseAddr(item.IpAddress)
+		if err != nil {
+			continue
+		}
+
+		label := Label(item.Name)
+		if label == "" || !c.filter.match(label, address) {
+			continue
+		}
+
+		hosts = append(hosts, Host{Name: label, Address: address})
+	}
+
+	return hosts, nil
+}
+
+// match reports whether a client with the label and address is selected.
+func (f ClientFilter) match(label string, address netip.Addr) bool {
+	if len(f.Networks) > 0 && !slices.ContainsFunc(f.Networks, func(network netip.Prefix) bool {
+		return network.Contains(address.Unmap())
+	}) {
+		return false
+	}
+
+	if len(f.Include) > 0 && !matchAny(f.Include, label) {
+		return false
+	}
+
+	return !matchAny(f.Exclude, label)
+}
+
+// matchAny reports whether name matches any of the globs. Invalid globs match nothing;
+// the configuration is validated before they get here.
+func matchAny(globs []string, name string) bool {
+	return slices.ContainsFunc(globs, func(glob string) bool {
+		matched, err := path.Match(glob, name)
+
+		return err == nil && matched
+	})
+}
@@ -0,0 +1,148 @@
+package autorecords
+
+import (
+	"cmp"
+	"context"
+	"net/netip"
+	"slices"
+	"strings"
+	"text/template"
+
+	"github.com/cockroachdb/errors"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+// Device roles available to naming templates.
+const (
+	RoleGateway = "gateway"
+	RoleSwitch  = "switch"
+	RoleAP      = "ap"
+	RoleDevice  = "device"
+)
+
+// Device is the data a naming template is executed with.
+type Device struct {
+	// Name is the device name shown in UniFi.
+	Name string
+	// Model is the model identifier, e.g. U6LR.
+	Model string
+	// MAC is the MAC address without separators, lowercase.
+	MAC string
+	// Role is gateway, switch, ap or device, from the device features.
+	Role string
+}
+
+// Devices lists the UniFi devices of a site, such as gateways, switches and access points.
+type Devices struct {
+	client   unifi.NetworkAPIClient
+	site     *site
+	template *template.Template
+}
+
+// NewDevices creates a source for the devices of site, the name used by the DNS API.
+// nameTemplate is a text/template executed with a Device; its output is turned into
+// a DNS label with Label.
+func NewDevices(client unifi.NetworkAPIClient, site, nameTemplate string) (*Devices, error) {
+	parsed, err := template.New("device").Option("missingkey=error").Parse(nameTemplate)
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid device name template")
+	}
+
+	// Unknown fields only fail when the template is executed
+	err = parsed.Execute(&strings.Builder{}, Device{})
+	if err != nil {
+		return nil, errors.Wrap(err, "invalid device name template")
+	}
+
+	return &Devices{client: client, site: newSite(client, site), template: parsed}, nil
+}
+
+// Name implements Source.
+func (d *Devices) Name() string {
+	return "devices"
+}
+
+// Hosts implements Source. Devices are published with their management address,
+// whether or not they are online; online devices come first.
+func (d *Devices) Hosts(ctx context.Context) ([]Host, error) {
+	siteID, err := d.site.resolve(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []unifi.DeviceListItem
+
+	for offset := 0; ; {
+		page, err := d.client.ListSiteDevices(ctx, siteID, &unifi.ListSiteDevicesParams{
+			Offset: new(offset),
+			Limit:  new(pageSize),
+		})
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to list devices")
+		}
+
+		devices = append(devices, page.Data...)
+		offset += len(page.Data)
+
+		if len(page.Data) == 0 || offset >= page.TotalCount {
+			break
+		}
+	}
+
+	slices.SortStableFunc(devices, func(left, right unifi.DeviceListItem) int {
+		return cmp.Compare(stateOrder(left.State), stateOrder(right.State))
+	})
+
+	hosts := make([]Host, 0, len(devices))
+
+	for _, item := range devices {
+		address, err := netip.ParseAddr(item.IpAddress)
+		if err != nil {
+			continue
+		}
+
+		var name strings.Builder
+
+		err = d.template.Execute(&name, Device{
+			Name:  item.Name,
+			Model: item.Model,
+			MAC:   strings.ToLower(strings.NewReplacer(":", "", "-", "").Replace(item.MacAddress)),
+			Role:  role(item.Features),
+		})
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to name device %s", item.MacAddress)
+		}
+
+		label := Label(name.String())
+		if label == "" {
+			continue
+		}
+
+		hosts = append(hosts, Host{Name: label, Address: address})
+	}
+
+	return hosts, nil
+}
+
+// role returns the main role of a device from its features.
+func role(features []unifi.DeviceListItemFeatures) string {
+	switch {
+	case slices.Contains(features, unifi.Gateway):
+		return RoleGateway
+	case slices.Contains(features, unifi.AccessPoint):
+		return RoleAP
+	case slices.Contains(features, unifi.Switching):
+		return RoleSwitch
+	default:
+		return RoleDevice
+	}
+}
+
+// stateOrder sorts online devices before the others.
+func stateOrder(state unifi.DeviceListItemState) int {
+	if state == unifi.DeviceListItemStateONLINE {
+		return 0
+	}
+
+	return 1
+}
@@ -0,0 +1,60 @@
+package autorecords
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+// pageSize is the largest page the UniFi integration API returns.
+const pageSize = 100
+
+// ErrSiteNotFound is returned when the configured site is not on the controller.
+var ErrSiteNotFound = errors.New("site not found")
+
+// site resolves a site name to its ID once, since the integration API
+// addresses sites by ID while the DNS API uses the site name.
+type site struct {
+	client unifi.NetworkAPIClient
+	name   string
+
+	mu sync.Mutex
+	id *unifi.SiteId
+}
+
+func newSite(client unifi.NetworkAPIClient, name string) *site {
+	return &site{client: client, name: name}
+}
+
+// resolve returns the ID of the site, looking it up on first use.
+func (s *site) resolve(ctx context.Context) (unifi.SiteId, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.id != nil {
+		return *s.id, nil
+	}
+
+	for offset := 0; ; {
+		page, err := s.client.ListSites(ctx, &unifi.ListSitesParams{Offset: new(offset), Limit: new(pageSize)})
+		if err != nil {
+			return unifi.SiteId{}, errors.Wrap(err, "failed to list sites")
+		}
+
+		for _, item := range page.Data {
+			if item.InternalReference == s.name || item.Name == s.name || item.Id.String() == s.name {
+				s.id = &item.Id
+
+				return item.Id, nil
+			}
+		}
+
+		offset += len(page.Data)
+
+		if len(page.Data) == 0 || offset >= page.TotalCount {
+			return unifi.SiteId{}, errors.Wrapf(ErrSiteNotFound, "site %q", s.name)
+		}
+	}
+}
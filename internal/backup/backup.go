@@ -0,0 +1,408 @@
+// Package backup writes snapshots of the site's UniFi DNS records to disk and restores them.
+package backup
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Snapshot reasons.
+const (
+	ReasonStartup   = "startup"
+	ReasonScheduled = "scheduled"
+	ReasonApply     = "apply"
+	ReasonManual    = "manual"
+	ReasonRestore   = "restore"
+)
+
+// Latest names the newest snapshot when loading or restoring.
+const Latest = "latest"
+
+// timeLayout is the snapshot time in file names; it sorts chronologically.
+const timeLayout = "20060102T150405.000Z"
+
+// snapshotName matches snapshot file names written by Take.
+var snapshotName = regexp.MustCompile(`^snapshot-(\d{8}T\d{6}\.\d{3}Z)-([a-z]+)\.json$`)
+
+// ErrNotFound is returned when a snapshot does not exist.
+var ErrNotFound = errors.New("snapshot not found")
+
+// ErrWrongSite is returned when restoring a snapshot of another site.
+var ErrWrongSite = errors.New("snapshot belongs to another site")
+
+// ErrFrozen is returned when restoring a snapshot while DNS changes are frozen, unless forced.
+var ErrFrozen = errors.New("DNS changes are frozen")
+
+// Snapshot is every DNS record of a site at one point in time.
+type Snapshot struct {
+	Site    string            `json:"site"`
+	TakenAt time.Time         `json:"taken_at"`
+	Reason  string            `json:"reason"`
+	Records []unifi.DNSRecord `json:"records"`
+}
+
+// Info describes a stored snapshot.
+type Info struct {
+	Name    string    `json:"name"`
+	TakenAt time.Time `json:"taken_at"`
+	Reason  string    `json:"reason"`
+}
+
+// Scope reports whether a record may be changed by a restore.
+type Scope func(record *unifi.DNSRecord) bool
+
+// Endpoints converts UniFi records to endpoints with the names and targets external-dns sees.
+type Endpoints func(records []unifi.DNSRecord) []*endpoint.Endpoint
+
+// Applier applies the changes of a restore, usually the provider external-dns talks to.
+type Applier interface {
+	ApplyChanges(ctx context.Context, changes *plan.Changes) error
+}
+
+// Store writes snapshots of a site's DNS records to a directory and keeps the newest ones.
+type Store struct {
+	client    unifi.NetworkAPIClient
+	site      string
+	dir       string
+	retention int
+	scope     Scope
+	endpoints Endpoints
+	applier   Applier
+	frozen    func() bool
+	now       func() time.Time
+
+	// mu serializes snapshots so retention and deduplication see a consistent directory
+	mu sync.Mutex
+	// restoring serializes restores
+	restoring sync.Mutex
+}
+
+// Option configures optional Store behavior.
+type Option func(*Store)
+
+// WithScope limits restores to records the scope accepts; others are neither created nor deleted.
+func WithScope(scope Scope) Option {
+	return func(s *Store) {
+		s.scope = scope
+	}
+}
+
+// WithProvider applies restores through applier, with records converted by endpoints.
+// Restores then pass protection, the static records guard, rewrites and the
+// apply hooks like changes from external-dns. Without it restores are refused.
+func WithProvider(endpoints Endpoints, applier Applier) Option {
+	return func(s *Store) {
+		s.endpoints = endpoints
+		s.applier = applier
+	}
+}
+
+// WithFreeze refuses restores while frozen reports true, unless they are forced.
+func WithFreeze(frozen func() bool) Option {
+	return func(s *Store) {
+		s.frozen = frozen
+	}
+}
+
+// New creates a store that keeps the newest retention snapshots in dir, creating it if needed.
+func New(client unifi.NetworkAPIClient, site, dir string, retention int, opts ...Option) (*Store, error) {
+	if dir == "" {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return nil, errors.New("backup directory is not configured")
+	}
+
+	err := os.MkdirAll(dir, 0o700)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create backup directory")
+	}
+
+	store := &Store{
+		client:    client,
+		site:      site,
+		dir:       dir,
+		retention: retention,
+		scope:     func(*unifi.DNSRecord) bool { return true },
+		frozen:    func() bool { return false },
+		now:       time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	return store, nil
+}
+
+// Take writes a snapshot of the site's DNS records. When the records are
+// identical to the newest snapshot nothing is written, written is false
+// and the newest snapshot is returned.
+func (s *Store) Take(ctx context.Context, reason string) (Info, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	info, written, err := s.take(ctx, reason)
+
+	switch {
+	case err != nil:
+		dnsmetrics.BackupSnapshots.WithLabelValues(reason, "error").Inc()
+	case written:
+		dnsmetrics.BackupSnapshots.WithLabelValues(reason, "written").Inc()
+		dnsmetrics.BackupLastSnapshot.Set(float64(info.TakenAt.Unix()))
+	default:
+		dnsmetrics.BackupSnapshots.WithLabelValues(reason, "unchanged").Inc()
+	}
+
+	return info, written, err
+}
+
+// take writes a snapshot. The caller must hold s.mu.
+func (s *Store) take(ctx context.Context, reason string) (Info, bool, error) {
+	records, err := s.client.ListDNSRecords(ctx, s.site)
+	if err != nil {
+		return Info{}, false, errors.Wrap(err, "failed to list DNS records")
+	}
+
+	if records == nil {
+		records = []unifi.DNSRecord{}
+	}
+
+	sortRecords(records)
+
+	snapshots, err := s.list()
+	if err != nil {
+		return Info{}, false, err
+	}
+
+	if len(snapshots) > 0 {
+		latest, err := s.load(snapshots[0].Name)
+		if err == nil && latest.Site == s.site && reflect.DeepEqual(latest.Records, records) {
+			return snapshots[0], false, nil
+		}
+	}
+
+	// Names must stay unique and ordered even for snapshots within the same millisecond
+	takenAt := s.now().UTC().Truncate(time.Millisecond)
+	if len(snapshots) > 0 && !takenAt.After(snapshots[0].TakenAt) {
+		takenAt = snapshots[0].TakenAt.Add(time.Millisecond)
+	}
+	info := Info{
+		Name:    "snapshot-" + takenAt.Format(timeLayout) + "-" + reason + ".json",
+		TakenAt: takenAt,
+		Reason:  reason,
+	}
+
+	content, err := json.MarshalIndent(Snapshot{Site: s.site, TakenAt: takenAt, Reason: reason, Records: records}, "", "  ")
+	if err != nil {
+		return Info{}, false, errors.Wrap(err, "failed to encode snapshot")
+	}
+
+	err = writeFile(filepath.Join(s.dir, info.Name), content)
+	if err != nil {
+		return Info{}, false, err
+	}
+
+	s.prune(append([]Info{info}, snapshots...))
+
+	return info, true, nil
+}
+
+// prune deletes snapshots beyond the retention count, given newest first.
+func (s *Store) prune(snapshots []Info) {
+	if s.retention <= 0 || len(snapshots) <= s.retention {
+		return
+	}
+
+	for _, info := range snapshots[s.retention:] {
+		err := os.Remove(filepath.Join(s.dir, info.Name))
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			slog.Warn("failed to delete old snapshot", "name", info.Name, "error", err)
+		}
+	}
+}
+
+// List returns the stored snapshots, newest first.
+func (s *Store) List() ([]Info, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.list()
+}
+
+// list reads the snapshot directory. The caller must hold s.mu.
+func (s *Store) list() ([]Info, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read backup directory")
+	}
+
+	snapshots := make([]Info, 0, len(entries))
+
+	for _, entry := range entries {
+		info, ok := parseName(entry.Name())
+		if ok && entry.Type().IsRegular() {
+			snapshots = append(snapshots, info)
+		}
+	}
+
+	slices.SortFunc(snapshots, func(left, right Info) int {
+		return strings.Compare(right.Name, left.Name)
+	})
+
+	return snapshots, nil
+}
+
+// Load reads a snapshot by file name, or the newest one for Latest.
+func (s *Store) Load(name string) (*Snapshot, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.load(name)
+}
+
+// load reads a snapshot. The caller must hold s.mu.
+func (s *Store) load(name string) (*Snapshot, error) {
+	if name == Latest {
+		snapshots, err := s.list()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(snapshots) == 0 {
+			return nil, ErrNotFound
+		}
+
+		name = snapshots[0].Name
+	}
+
+	// Only names written by Take are accepted, so a name cannot escape the directory
+	if _, ok := parseName(name); !ok {
+		return nil, errors.Wrapf(ErrNotFound, "invalid snapshot name %q", name)
+	}
+
+	content, err := os.ReadFile(filepath.Join(s.dir, name))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, errors.Wrapf(ErrNotFound, "%s", name)
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+
+	var snapshot Snapshot
+
+	err = json.Unmarshal(content, &snapshot)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode snapshot %s", name)
+	}
+
+	return &snapshot, nil
+}
+
+// BeforeApply snapshots the records before external-dns changes them.
+// Failures are logged and do not block the change.
+func (s *Store) BeforeApply(ctx context.Context) {
+	_, _, err := s.Take(ctx, ReasonApply)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to snapshot DNS records before applying changes", "error", err)
+	}
+}
+
+// Run takes a snapshot at startup and then every interval until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	s.scheduled(ctx, ReasonStartup)
+
+	if interval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.scheduled(ctx, ReasonScheduled)
+		}
+	}
+}
+
+// scheduled takes a snapshot and logs the outcome.
+func (s *Store) scheduled(ctx context.Context, reason string) {
+	info, written, err := s.Take(ctx, reason)
+
+	switch {
+	case err != nil:
+		slog.WarnContext(ctx, "failed to snapshot DNS records", "reason", reason, "error", err)
+	case written:
+		slog.InfoContext(ctx, "DNS records snapshot written", "name", info.Name)
+	default:
+		slog.DebugContext(ctx, "DNS records unchanged since last snapshot", "name", info.Name)
+	}
+}
+
+// parseName extracts the time and reason from a snapshot file name.
+func parseName(name string) (Info, bool) {
+	match := snapshotName.FindStringSubmatch(name)
+	if match == nil {
+		return Info{}, false
+	}
+
+	takenAt, err := time.Parse(timeLayout, match[1])
+	if err != nil {
+		return Info{}, false
+	}
+
+	return Info{Name: name, TakenAt: takenAt, Reason: match[2]}, true
+}
+
+// sortRecords orders records by ID so identical record sets compare equal.
+func sortRecords(records []unifi.DNSRecord) {
+	slices.SortFunc(records, func(left, right unifi.DNSRecord) int {
+		return strings.Compare(left.UnderscoreId, right.UnderscoreId)
+	})
+}
+
+// writeFile writes content to a temporary file and renames it into place,
+// so a crash never leaves a truncated snapshot behind.
+func writeFile(path string, content []byte) error {
+	temp, err := os.CreateTemp(filepath.Dir(path), ".snapshot-*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create snapshot file")
+	}
+
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Close()
+	} else {
+		_ = temp.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), path)
+	}
+
+	if err != nil {
+		_ = os.Remove(temp.Name())
+
+		return errors.Wrap(err, "failed to write snapshot file")
+	}
+
+	return nil
+}
@@ -0,0 +1,345 @@
+package backup_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/backup"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeClient keeps DNS records in memory. Methods other than the DNS record ones are not used.
+type fakeClient struct {
+	unifi.NetworkAPIClient
+
+	mu      sync.Mutex
+	records []unifi.DNSRecord
+	nextID  int
+}
+
+func (f *fakeClient) ListDNSRecords(context.Context, unifi.Site) ([]unifi.DNSRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return append([]unifi.DNSRecord(nil), f.records...), nil
+}
+
+func (f *fakeClient) CreateDNSRecord(_ context.Context, _ unifi.Site, input *unifi.DNSRecordInput) (*unifi.DNSRecord, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.nextID++
+	record := unifi.DNSRecord{
+		UnderscoreId: "new-" + strconv.Itoa(f.nextID),
+		Enabled:      *input.Enabled,
+		Key:          input.Key,
+		RecordType:   unifi.DNSRecordRecordType(input.RecordType),
+		Ttl:          input.Ttl,
+		Value:        input.Value,
+	}
+	f.records = append(f.records, record)
+
+	return &record, nil
+}
+
+func (f *fakeClient) DeleteDNSRecord(_ context.Context, _ unifi.Site, recordID unifi.RecordId) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for idx, record := range f.records {
+		if record.UnderscoreId == recordID {
+			f.records = append(f.records[:idx], f.records[idx+1:]...)
+
+			return nil
+		}
+	}
+
+	return os.ErrNotExist
+}
+
+func (f *fakeClient) set(records ...unifi.DNSRecord) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.records = records
+}
+
+func record(id, key, value string) unifi.DNSRecord {
+	return unifi.DNSRecord{UnderscoreId: id, Enabled: true, Key: key, RecordType: unifi.DNSRecordRecordTypeA, Value: value}
+}
+
+// newStore returns a store over the fake client in a temporary directory,
+// restoring through a provider with the given options.
+func newStore(t *testing.T, client *fakeClient, retention int, opts ...backup.Option) *backup.Store {
+	t.Helper()
+
+	return newStoreWith(t, client, retention, provider.New(client, "default", endpoint.DomainFilter{}), opts...)
+}
+
+// newStoreWith returns a store over the fake client restoring the records prov manages through prov.
+func newStoreWith(t *testing.T, client *fakeClient, retention int, prov *provider.UniFiProvider, opts ...backup.Option) *backup.Store {
+	t.Helper()
+
+	opts = append([]backup.Option{backup.WithScope(prov.Manages), backup.WithProvider(prov.Endpoints, prov)}, opts...)
+
+	store, err := backup.New(client, "default", t.TempDir(), retention, opts...)
+	require.NoError(t, err)
+
+	return store
+}
+
+func TestTake_SkipsUnchangedAndPrunes(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{}
+	client.set(record("1", "app.home.lan", "192.168.1.10"))
+
+	store := newStore(t, client, 2)
+	ctx := context.Background()
+
+	first, written, err := store.Take(ctx, backup.ReasonScheduled)
+	require.NoError(t, err)
+	assert.True(t, written)
+
+	again, written, err := store.Take(ctx, backup.ReasonApply)
+	require.NoError(t, err)
+	assert.False(t, written, "identical records are not written twice")
+	assert.Equal(t, first.Name, again.Name)
+
+	for idx := range 3 {
+		client.set(record("1", "app.home.lan", "192.168.1."+strconv.Itoa(20+idx)))
+
+		_, written, err = store.Take(ctx, backup.ReasonApply)
+		require.NoError(t, err)
+		assert.True(t, written)
+	}
+
+	snapshots, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+
+	latest, err := store.Load(backup.Latest)
+	require.NoError(t, err)
+	assert.Equal(t, "192.168.1.22", latest.Records[0].Value)
+	assert.Equal(t, backup.ReasonApply, latest.Reason)
+}
+
+func TestLoad_RejectsForeignNames(t *testing.T) {
+	t.Parallel()
+
+	store := newStore(t, &fakeClient{}, 5)
+
+	for _, name := range []string{backup.Latest, "../etc/passwd", "snapshot-20260101T000000.000Z-apply.json"} {
+		_, err := store.Load(name)
+		require.ErrorIs(t, err, backup.ErrNotFound, name)
+	}
+}
+
+func TestRestore(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{}
+	client.set(
+		record("1", "app.home.lan", "192.168.1.10"),
+		record("2", "db.home.lan", "192.168.1.11"),
+		record("3", "router.home.lan", "192.168.1.1"),
+	)
+
+	// Records outside the scope are neither deleted nor recreated
+	store := newStore(t, client, 10, backup.WithScope(func(record *unifi.DNSRecord) bool {
+		return record.Key != "router.home.lan"
+	}))
+	ctx := context.Background()
+
+	snapshot, _, err := store.Take(ctx, backup.ReasonManual)
+	require.NoError(t, err)
+
+	client.set(
+		record("1", "app.home.lan", "192.168.1.10"),
+		record("4", "new.home.lan", "192.168.1.12"),
+	)
+
+	preview, err := store.Restore(ctx, snapshot.Name, true, false)
+	require.NoError(t, err)
+	assert.True(t, preview.DryRun)
+	require.Len(t, preview.Created, 1)
+	assert.Equal(t, "db.home.lan", preview.Created[0].Name)
+	require.Len(t, preview.Deleted, 1)
+	assert.Equal(t, "new.home.lan", preview.Deleted[0].Name)
+
+	current, _ := client.ListDNSRecords(ctx, "default")
+	assert.Len(t, current, 2, "a dry run changes nothing")
+
+	result, err := store.Restore(ctx, backup.Latest, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, snapshot.Name, result.Snapshot)
+	assert.Empty(t, result.Errors)
+
+	current, _ = client.ListDNSRecords(ctx, "default")
+
+	keys := make([]string, 0, len(current))
+	for _, item := range current {
+		keys = append(keys, item.Key)
+	}
+
+	assert.ElementsMatch(t, []string{"app.home.lan", "db.home.lan"}, keys)
+
+	// The state before the restore was snapshotted so the restore can be undone
+	snapshots, err := store.List()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, backup.ReasonRestore, snapshots[0].Reason)
+}
+
+func TestRestore_ThroughProvider(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{}
+	client.set(
+		record("1", "app.home.lan", "192.168.1.10"),
+		record("2", "nas.home.lan", "192.168.1.20"),
+	)
+
+	protection, err := provider.NewProtection([]string{"nas.home.lan"}, nil, nil, false)
+	require.NoError(t, err)
+
+	var applied []*plan.Changes
+
+	prov := provider.New(client, "default", endpoint.DomainFilter{},
+		provider.WithProtection(protection),
+		provider.WithAfterApply(func(_ context.Context, changes *plan.Changes, _ error) {
+			applied = append(applied, changes)
+		}))
+
+	store := newStoreWith(t, client, 10, prov)
+	ctx := context.Background()
+
+	_, _, err = store.Take(ctx, backup.ReasonManual)
+	require.NoError(t, err)
+
+	client.set(record("3", "app.home.lan", "192.168.1.11"))
+
+	// Protected records are not recreated, and the apply hooks see the restored records
+	result, err := store.Restore(ctx, backup.Latest, false, false)
+	require.NoError(t, err)
+	assert.Equal(t, []records.Record{{Name: "app.home.lan", Type: "A", TTL: 300, Targets: []string{"192.168.1.10"}}}, result.Created)
+
+	current, _ := client.ListDNSRecords(ctx, "default")
+	require.Len(t, current, 1)
+	assert.Equal(t, "192.168.1.10", current[0].Value)
+
+	require.Len(t, applied, 1)
+	require.Len(t, applied[0].UpdateNew, 1)
+	assert.Equal(t, "app.home.lan", applied[0].UpdateNew[0].DNSName)
+}
+
+func TestRestore_Frozen(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{}
+	client.set(record("1", "app.home.lan", "192.168.1.10"))
+
+	store := newStore(t, client, 10, backup.WithFreeze(func() bool { return true }))
+	ctx := context.Background()
+
+	_, _, err := store.Take(ctx, backup.ReasonManual)
+	require.NoError(t, err)
+
+	client.set()
+
+	_, err = store.Restore(ctx, backup.Latest, false, false)
+	require.ErrorIs(t, err, backup.ErrFrozen)
+
+	current, _ := client.ListDNSRecords(ctx, "default")
+	assert.Empty(t, current, "nothing is restored while frozen")
+
+	// Previews are allowed, and a forced restore goes through
+	preview, err := store.Restore(ctx, backup.Latest, true, false)
+	require.NoError(t, err)
+	assert.Len(t, preview.Created, 1)
+
+	_, err = store.Restore(ctx, backup.Latest, false, true)
+	require.NoError(t, err)
+
+	current, _ = client.ListDNSRecords(ctx, "default")
+	assert.Len(t, current, 1)
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{}
+	client.set(record("1", "app.home.lan", "192.168.1.10"))
+
+	handler := newStore(t, client, 5).Handler()
+
+	serve := func(method, target string) *httptest.ResponseRecorder {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(method, target, nil))
+
+		return recorder
+	}
+
+	response := serve(http.MethodPost, "/admin/backups")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	var created backup.Info
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &created))
+	assert.Equal(t, backup.ReasonManual, created.Reason)
+
+	response = serve(http.MethodGet, "/admin/backups")
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), created.Name)
+
+	client.set()
+
+	response = serve(http.MethodPost, "/admin/backups/latest/restore?dry_run=true")
+	require.Equal(t, http.StatusOK, response.Code, response.Body.String())
+
+	var result backup.RestoreResult
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+	assert.True(t, result.DryRun)
+	assert.Len(t, result.Created, 1)
+
+	assert.Equal(t, http.StatusNotFound, serve(http.MethodPost, "/admin/backups/missing.json/restore").Code)
+	assert.Equal(t, http.StatusBadRequest, serve(http.MethodPost, "/admin/backups/latest/restore?dry_run=maybe").Code)
+	assert.True(t, strings.HasPrefix(serve(http.MethodGet, "/admin/backups/latest/restore").Header().Get("Allow"), "POST"))
+}
+
+func TestHandler_Frozen(t *testing.T) {
+	t.Parallel()
+
+	client := &fakeClient{}
+	client.set(record("1", "app.home.lan", "192.168.1.10"))
+
+	store := newStore(t, client, 5, backup.WithFreeze(func() bool { return true }))
+	_, _, err := store.Take(context.Background(), backup.ReasonManual)
+	require.NoError(t, err)
+
+	client.set()
+
+	serve := func(target string) int {
+		recorder := httptest.NewRecorder()
+		store.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, target, nil))
+
+		return recorder.Code
+	}
+
+	assert.Equal(t, http.StatusLocked, serve("/admin/backups/latest/restore"))
+	assert.Equal(t, http.StatusOK, serve("/admin/backups/latest/restore?dry_run=true"))
+	assert.Equal(t, http.StatusBadRequest, serve("/admin/backups/latest/restore?force=maybe"))
+	assert.Equal(t, http.StatusOK, serve("/admin/backups/latest/restore?force=true"))
+}
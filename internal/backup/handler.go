@@ -0,0 +1,88 @@
+package backup
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Handler returns the administrative backup API, mounted at /admin/backups:
+//
+//	GET  /admin/backups                  lists snapshots, newest first
+//	POST /admin/backups                  takes a snapshot now
+//	POST /admin/backups/{name}/restore   restores a snapshot; ?dry_run=true only reports the changes
+//
+// {name} may be "latest". While DNS changes are frozen restores are refused with
+// 423 Locked, unless ?force=true is set.
+func (s *Store) Handler() http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("GET /admin/backups", func(w http.ResponseWriter, _ *http.Request) {
+		snapshots, err := s.List()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, snapshots)
+	})
+
+	mux.HandleFunc("POST /admin/backups", func(w http.ResponseWriter, r *http.Request) {
+		info, _, err := s.Take(r.Context(), ReasonManual)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+
+			return
+		}
+
+		writeJSON(w, http.StatusOK, info)
+	})
+
+	mux.HandleFunc("POST /admin/backups/{name}/restore", func(w http.ResponseWriter, r *http.Request) {
+		dryRun, err := strconv.ParseBool(r.URL.Query().Get("dry_run"))
+		if err != nil && r.URL.Query().Has("dry_run") {
+			http.Error(w, "dry_run must be true or false", http.StatusBadRequest)
+
+			return
+		}
+
+		force, err := strconv.ParseBool(r.URL.Query().Get("force"))
+		if err != nil && r.URL.Query().Has("force") {
+			http.Error(w, "force must be true or false", http.StatusBadRequest)
+
+			return
+		}
+
+		result, err := s.Restore(r.Context(), r.PathValue("name"), dryRun, force)
+
+		switch {
+		case errors.Is(err, ErrFrozen):
+			http.Error(w, err.Error(), http.StatusLocked)
+		case errors.Is(err, ErrNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrWrongSite):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case result != nil:
+			status := http.StatusOK
+			if err != nil {
+				status = http.StatusBadGateway
+			}
+
+			writeJSON(w, status, result)
+		case err != nil:
+			http.Error(w, err.Error(), http.StatusBadGateway)
+		}
+	})
+
+	return mux
+}
+
+// writeJSON writes value as a JSON response.
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
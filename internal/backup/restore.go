@@ -0,0 +1,139 @@
+package backup
+
+import (
+	"context"
+	"log/slog"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+// RestoreResult lists the record changes a restore made, or would make on a dry run.
+// Records are given with the names and targets external-dns sees; an updated
+// record appears in Deleted with its current and in Created with its restored targets.
+type RestoreResult struct {
+	Snapshot string           `json:"snapshot"`
+	DryRun   bool             `json:"dry_run"`
+	Created  []records.Record `json:"created"`
+	Deleted  []records.Record `json:"deleted"`
+	Errors   []string         `json:"errors,omitempty"`
+}
+
+// Restore reconciles the site's DNS records with a snapshot: records in
+// scope that are missing from the snapshot are deleted and snapshot records
+// that are missing from the site are created. The changes are applied
+// through the provider, so they pass the same checks as changes from
+// external-dns and update its state.
+// Unless dryRun is set, the current records are snapshotted first so a
+// restore can itself be undone. While DNS changes are frozen only dry runs
+// are allowed, unless force is set.
+func (s *Store) Restore(ctx context.Context, name string, dryRun, force bool) (*RestoreResult, error) {
+	if s.applier == nil {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return nil, errors.New("restores need a provider to apply changes through")
+	}
+
+	if !dryRun && s.frozen() {
+		if !force {
+			return nil, ErrFrozen
+		}
+
+		slog.WarnContext(ctx, "restoring snapshot while DNS changes are frozen", "snapshot", name)
+	}
+
+	// Snapshots and the provider's before-apply hook take s.mu, so restores are serialized separately
+	s.restoring.Lock()
+	defer s.restoring.Unlock()
+
+	result, err := s.restore(ctx, name, dryRun)
+	if !dryRun {
+		if err != nil {
+			dnsmetrics.BackupRestores.WithLabelValues("error").Inc()
+		} else {
+			dnsmetrics.BackupRestores.WithLabelValues("success").Inc()
+		}
+	}
+
+	return result, err
+}
+
+// restore implements Restore. The caller must hold s.restoring.
+func (s *Store) restore(ctx context.Context, name string, dryRun bool) (*RestoreResult, error) {
+	snapshot, err := s.Load(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if snapshot.Site != s.site {
+		return nil, errors.Wrapf(ErrWrongSite, "snapshot was taken of site %q, not %q", snapshot.Site, s.site)
+	}
+
+	if name == Latest {
+		name = "snapshot-" + snapshot.TakenAt.UTC().Format(timeLayout) + "-" + snapshot.Reason + ".json"
+	}
+
+	if !dryRun {
+		_, _, err = s.Take(ctx, ReasonRestore)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to snapshot DNS records before restoring")
+		}
+	}
+
+	current, err := s.client.ListDNSRecords(ctx, s.site)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list DNS records")
+	}
+
+	changes := records.Diff(s.managed(current), s.managed(snapshot.Records), true)
+
+	result := &RestoreResult{Snapshot: name, DryRun: dryRun, Created: []records.Record{}, Deleted: []records.Record{}}
+
+	for _, change := range changes.Changes {
+		switch change.Action {
+		case records.ActionCreate:
+			result.Created = append(result.Created, *change.Desired)
+		case records.ActionDelete:
+			result.Deleted = append(result.Deleted, *change.Current)
+		case records.ActionUpdate:
+			result.Deleted = append(result.Deleted, *change.Current)
+			result.Created = append(result.Created, *change.Desired)
+		case records.ActionRecreate:
+		}
+	}
+
+	if dryRun || changes.Empty() {
+		return result, nil
+	}
+
+	err = s.applier.ApplyChanges(ctx, changes.ProviderChanges())
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+	}
+
+	slog.InfoContext(ctx, "restored DNS records from snapshot",
+		"snapshot", name,
+		"created", len(result.Created),
+		"deleted", len(result.Deleted),
+		"errors", len(result.Errors))
+
+	if err != nil {
+		return result, errors.Wrap(err, "failed to restore DNS records")
+	}
+
+	return result, nil
+}
+
+// managed returns the records in scope as external-dns sees them.
+func (s *Store) managed(stored []unifi.DNSRecord) []records.Record {
+	inScope := make([]unifi.DNSRecord, 0, len(stored))
+
+	for idx := range stored {
+		if s.scope(&stored[idx]) {
+			inScope = append(inScope, stored[idx])
+		}
+	}
+
+	return records.FromEndpoints(s.endpoints(inScope))
+}
@@ -1,31 +1,46 @@
 package config
 
 import (
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cockroachdb/errors"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
 )
 
 // UniFiConfig contains UniFi controller connection settings.
 type UniFiConfig struct {
-	Host          string `mapstructure:"host"`
-	APIKey        string `json:"-"                       mapstructure:"api_key"`
-	Site          string `mapstructure:"site"`
-	SkipTLSVerify bool   `mapstructure:"skip_tls_verify"`
+	Host           string `mapstructure:"host"`
+	APIKey         string `json:"-"                       mapstructure:"api_key"`
+	APIKeyFile     string `mapstructure:"api_key_file"`
+	Username       string `mapstructure:"username"`
+	Password       string `json:"-"                       mapstructure:"password"`
+	PasswordFile   string `mapstructure:"password_file"`
+	SelfHosted     bool   `mapstructure:"self_hosted"`
+	Site           string `mapstructure:"site"`
+	SkipTLSVerify  bool   `mapstructure:"skip_tls_verify"`
+	CAFile         string `mapstructure:"ca_file"`
+	TLSFingerprint string `mapstructure:"tls_fingerprint"`
 }
 
 // ServerConfig contains webhook server settings.
 type ServerConfig struct {
-	Host string `mapstructure:"host"`
-	Port string `mapstructure:"port"`
+	Host            string `mapstructure:"host"`
+	Port            string `mapstructure:"port"`
+	AuthTokenFile   string `mapstructure:"auth_token_file"`
+	AuthHMACKeyFile string `mapstructure:"auth_hmac_key_file"`
+	TLSCertFile     string `mapstructure:"tls_cert_file"`
+	TLSKeyFile      string `mapstructure:"tls_key_file"`
+	TLSClientCAFile string `mapstructure:"tls_client_ca_file"`
 }
 
 // HealthConfig contains health check server settings.
 type HealthConfig struct {
-	Host string `mapstructure:"host"`
-	Port string `mapstructure:"port"`
+	Host        string `mapstructure:"host"`
+	Port        string `mapstructure:"port"`
+	TLSCertFile string `mapstructure:"tls_cert_file"`
+	TLSKeyFile  string `mapstructure:"tls_key_file"`
 }
 
 // DomainFilterConfig contains domain filtering settings.
@@ -48,18 +63,163 @@ type DebugConfig struct {
 	PprofPort    string `mapstructure:"pprof_port"`
 }
 
+// ProtectionConfig contains settings for records the webhook must never modify.
+type ProtectionConfig struct {
+	Names         []string `mapstructure:"names"`
+	RegexNames    []string `mapstructure:"regex_names"`
+	RecordIDs     []string `mapstructure:"record_ids"`
+	HideProtected bool     `mapstructure:"hide_protected"`
+}
+
+// RewriteConfig contains rules that map names and targets from external-dns to the ones stored in UniFi.
+type RewriteConfig struct {
+	Suffixes         []string `mapstructure:"suffixes"`
+	Regexes          []string `mapstructure:"regexes"`
+	Targets          []string `mapstructure:"targets"`
+	TargetsStateFile string   `mapstructure:"targets_state_file"`
+}
+
+// TargetPolicyConfig contains the addresses A and AAAA records may point to.
+type TargetPolicyConfig struct {
+	Allow          []string `mapstructure:"allow"`
+	Deny           []string `mapstructure:"deny"`
+	Rules          []string `mapstructure:"rules"`
+	DropViolations bool     `mapstructure:"drop_violations"`
+}
+
+// VerifyConfig contains settings for checking that applied records are served by a DNS server.
+type VerifyConfig struct {
+	Server      string        `mapstructure:"server"`
+	Timeout     time.Duration `mapstructure:"timeout"`
+	Retries     int           `mapstructure:"retries"`
+	Interval    time.Duration `mapstructure:"interval"`
+	FailOnError bool          `mapstructure:"fail_on_error"`
+}
+
+// PTRConfig contains settings for maintaining reverse records of A and AAAA records.
+type PTRConfig struct {
+	Zones []string `mapstructure:"zones"`
+	Sink  string   `mapstructure:"sink"`
+	File  string   `mapstructure:"file"`
+}
+
+// FreezeConfig contains change freeze (maintenance window) settings.
+type FreezeConfig struct {
+	Enabled  bool     `mapstructure:"enabled"`
+	Mode     string   `mapstructure:"mode"`
+	Windows  []string `mapstructure:"windows"`
+	Timezone string   `mapstructure:"timezone"`
+}
+
+// LimitsConfig contains limits for operations against the UniFi API.
+type LimitsConfig struct {
+	MaxConcurrency int `mapstructure:"max_concurrency"`
+}
+
+// BackupConfig contains settings for snapshots of the site's DNS records.
+type BackupConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Directory string        `mapstructure:"directory"`
+	Interval  time.Duration `mapstructure:"interval"`
+	Retention int           `mapstructure:"retention"`
+}
+
+// DriftConfig contains settings for detecting changes to managed records made outside the webhook.
+type DriftConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Interval    time.Duration `mapstructure:"interval"`
+	AutoCorrect bool          `mapstructure:"auto_correct"`
+	StateFile   string        `mapstructure:"state_file"`
+}
+
+// ClientsConfig contains settings for publishing DNS records for UniFi network clients.
+type ClientsConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Domain      string        `mapstructure:"domain"`
+	Interval    time.Duration `mapstructure:"interval"`
+	TTL         int           `mapstructure:"ttl"`
+	Networks    []string      `mapstructure:"networks"`
+	Include     []string      `mapstructure:"include"`
+	Exclude     []string      `mapstructure:"exclude"`
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+	StateFile   string        `mapstructure:"state_file"`
+}
+
+// DevicesConfig contains settings for publishing DNS records for UniFi infrastructure devices.
+type DevicesConfig struct {
+	Enabled   bool          `mapstructure:"enabled"`
+	Domain    string        `mapstructure:"domain"`
+	Interval  time.Duration `mapstructure:"interval"`
+	TTL       int           `mapstructure:"ttl"`
+	Template  string        `mapstructure:"template"`
+	StateFile string        `mapstructure:"state_file"`
+}
+
+// DockerConfig contains settings for publishing DNS records for labeled Docker containers.
+type DockerConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`
+	Socket      string        `mapstructure:"socket"`
+	Domain      string        `mapstructure:"domain"`
+	Interval    time.Duration `mapstructure:"interval"`
+	TTL         int           `mapstructure:"ttl"`
+	Target      string        `mapstructure:"target"`
+	GracePeriod time.Duration `mapstructure:"grace_period"`
+	StateFile   string        `mapstructure:"state_file"`
+}
+
+// StaticRecordsConfig contains settings for records declared in a file alongside external-dns.
+type StaticRecordsConfig struct {
+	File     string        `mapstructure:"file"`
+	Interval time.Duration `mapstructure:"interval"`
+}
+
+// ReconcileConfig contains settings for the standalone reconcile loop, which applies
+// records declared in files without external-dns.
+type ReconcileConfig struct {
+	Source             string        `mapstructure:"source"`
+	Interval           time.Duration `mapstructure:"interval"`
+	Policy             string        `mapstructure:"policy"`
+	ManagedRecordTypes []string      `mapstructure:"managed_record_types"`
+}
+
 // Config represents the complete application configuration.
 type Config struct {
-	UniFi        UniFiConfig        `mapstructure:"unifi"`
-	Server       ServerConfig       `mapstructure:"server"`
-	Health       HealthConfig       `mapstructure:"health"`
-	DomainFilter DomainFilterConfig `mapstructure:"domain_filter"`
-	Logging      LoggingConfig      `mapstructure:"logging"`
-	Debug        DebugConfig        `mapstructure:"debug"`
+	UniFi        UniFiConfig         `mapstructure:"unifi"`
+	Server       ServerConfig        `mapstructure:"server"`
+	Health       HealthConfig        `mapstructure:"health"`
+	DomainFilter DomainFilterConfig  `mapstructure:"domain_filter"`
+	Protection   ProtectionConfig    `mapstructure:"protection"`
+	Rewrite      RewriteConfig       `mapstructure:"rewrite"`
+	TargetPolicy TargetPolicyConfig  `mapstructure:"target_policy"`
+	Verify       VerifyConfig        `mapstructure:"verify"`
+	PTR          PTRConfig           `mapstructure:"ptr"`
+	Freeze       FreezeConfig        `mapstructure:"freeze"`
+	Limits       LimitsConfig        `mapstructure:"limits"`
+	Backup       BackupConfig        `mapstructure:"backup"`
+	Drift        DriftConfig         `mapstructure:"drift"`
+	Clients      ClientsConfig       `mapstructure:"clients"`
+	Devices      DevicesConfig       `mapstructure:"devices"`
+	Docker       DockerConfig        `mapstructure:"docker"`
+	Static       StaticRecordsConfig `mapstructure:"static_records"`
+	Reconcile    ReconcileConfig     `mapstructure:"reconcile"`
+	Logging      LoggingConfig       `mapstructure:"logging"`
+	Debug        DebugConfig         `mapstructure:"debug"`
+
+	// File is the config file that was read, empty when configured by environment only.
+	File string `mapstructure:"-"`
+
+	// flags are the command-line flags the configuration was loaded with, kept for reloads.
+	flags *pflag.FlagSet
 }
 
 // Load loads configuration from environment variables and config files.
 func Load() (*Config, error) {
+	return LoadFlags(nil)
+}
+
+// LoadFlags loads configuration like Load, with flags registered by RegisterFlags
+// taking precedence. Precedence is flag > environment > config file > default.
+func LoadFlags(flags *pflag.FlagSet) (*Config, error) {
 	viperConfig := viper.New()
 
 	viperConfig.SetEnvKeyReplacer(strings.NewReplacer(".", "_"))
@@ -71,24 +231,115 @@ func Load() (*Config, error) {
 	// Explicitly bind environment variables with hardcoded WEBHOOK_ prefix
 	// AutomaticEnv() doesn't automatically bind nested keys
 	_ = viperConfig.BindEnv("unifi.api_key", "WEBHOOK_UNIFI_API_KEY")
+	_ = viperConfig.BindEnv("unifi.api_key_file", "WEBHOOK_UNIFI_API_KEY_FILE")
+	_ = viperConfig.BindEnv("unifi.username", "WEBHOOK_UNIFI_USERNAME")
+	_ = viperConfig.BindEnv("unifi.password", "WEBHOOK_UNIFI_PASSWORD")
+	_ = viperConfig.BindEnv("unifi.password_file", "WEBHOOK_UNIFI_PASSWORD_FILE")
+	_ = viperConfig.BindEnv("unifi.self_hosted", "WEBHOOK_UNIFI_SELF_HOSTED")
 	_ = viperConfig.BindEnv("unifi.host", "WEBHOOK_UNIFI_HOST")
 	_ = viperConfig.BindEnv("unifi.site", "WEBHOOK_UNIFI_SITE")
 	_ = viperConfig.BindEnv("unifi.skip_tls_verify", "WEBHOOK_UNIFI_SKIP_TLS_VERIFY")
+	_ = viperConfig.BindEnv("unifi.ca_file", "WEBHOOK_UNIFI_CA_FILE")
+	_ = viperConfig.BindEnv("unifi.tls_fingerprint", "WEBHOOK_UNIFI_TLS_FINGERPRINT")
 	_ = viperConfig.BindEnv("server.host", "WEBHOOK_SERVER_HOST")
 	_ = viperConfig.BindEnv("server.port", "WEBHOOK_SERVER_PORT")
+	_ = viperConfig.BindEnv("server.auth_token_file", "WEBHOOK_SERVER_AUTH_TOKEN_FILE")
+	_ = viperConfig.BindEnv("server.auth_hmac_key_file", "WEBHOOK_SERVER_AUTH_HMAC_KEY_FILE")
+	_ = viperConfig.BindEnv("server.tls_cert_file", "WEBHOOK_SERVER_TLS_CERT_FILE")
+	_ = viperConfig.BindEnv("server.tls_key_file", "WEBHOOK_SERVER_TLS_KEY_FILE")
+	_ = viperConfig.BindEnv("server.tls_client_ca_file", "WEBHOOK_SERVER_TLS_CLIENT_CA_FILE")
 	_ = viperConfig.BindEnv("health.host", "WEBHOOK_HEALTH_HOST")
 	_ = viperConfig.BindEnv("health.port", "WEBHOOK_HEALTH_PORT")
+	_ = viperConfig.BindEnv("health.tls_cert_file", "WEBHOOK_HEALTH_TLS_CERT_FILE")
+	_ = viperConfig.BindEnv("health.tls_key_file", "WEBHOOK_HEALTH_TLS_KEY_FILE")
+	_ = viperConfig.BindEnv("protection.names", "WEBHOOK_PROTECTION_NAMES")
+	_ = viperConfig.BindEnv("protection.regex_names", "WEBHOOK_PROTECTION_REGEX_NAMES")
+	_ = viperConfig.BindEnv("protection.record_ids", "WEBHOOK_PROTECTION_RECORD_IDS")
+	_ = viperConfig.BindEnv("protection.hide_protected", "WEBHOOK_PROTECTION_HIDE_PROTECTED")
+	_ = viperConfig.BindEnv("rewrite.suffixes", "WEBHOOK_REWRITE_SUFFIXES")
+	_ = viperConfig.BindEnv("rewrite.regexes", "WEBHOOK_REWRITE_REGEXES")
+	_ = viperConfig.BindEnv("rewrite.targets", "WEBHOOK_REWRITE_TARGETS")
+	_ = viperConfig.BindEnv("rewrite.targets_state_file", "WEBHOOK_REWRITE_TARGETS_STATE_FILE")
+	_ = viperConfig.BindEnv("target_policy.allow", "WEBHOOK_TARGET_POLICY_ALLOW")
+	_ = viperConfig.BindEnv("target_policy.deny", "WEBHOOK_TARGET_POLICY_DENY")
+	_ = viperConfig.BindEnv("target_policy.drop_violations", "WEBHOOK_TARGET_POLICY_DROP_VIOLATIONS")
+	_ = viperConfig.BindEnv("verify.server", "WEBHOOK_VERIFY_SERVER")
+	_ = viperConfig.BindEnv("verify.timeout", "WEBHOOK_VERIFY_TIMEOUT")
+	_ = viperConfig.BindEnv("verify.retries", "WEBHOOK_VERIFY_RETRIES")
+	_ = viperConfig.BindEnv("verify.interval", "WEBHOOK_VERIFY_INTERVAL")
+	_ = viperConfig.BindEnv("verify.fail_on_error", "WEBHOOK_VERIFY_FAIL_ON_ERROR")
+	_ = viperConfig.BindEnv("ptr.zones", "WEBHOOK_PTR_ZONES")
+	_ = viperConfig.BindEnv("ptr.sink", "WEBHOOK_PTR_SINK")
+	_ = viperConfig.BindEnv("ptr.file", "WEBHOOK_PTR_FILE")
+	_ = viperConfig.BindEnv("freeze.enabled", "WEBHOOK_FREEZE_ENABLED")
+	_ = viperConfig.BindEnv("freeze.mode", "WEBHOOK_FREEZE_MODE")
+	_ = viperConfig.BindEnv("freeze.timezone", "WEBHOOK_FREEZE_TIMEZONE")
+	_ = viperConfig.BindEnv("limits.max_concurrency", "WEBHOOK_LIMITS_MAX_CONCURRENCY")
+	_ = viperConfig.BindEnv("backup.enabled", "WEBHOOK_BACKUP_ENABLED")
+	_ = viperConfig.BindEnv("backup.directory", "WEBHOOK_BACKUP_DIRECTORY")
+	_ = viperConfig.BindEnv("backup.interval", "WEBHOOK_BACKUP_INTERVAL")
+	_ = viperConfig.BindEnv("backup.retention", "WEBHOOK_BACKUP_RETENTION")
+	_ = viperConfig.BindEnv("drift.enabled", "WEBHOOK_DRIFT_ENABLED")
+	_ = viperConfig.BindEnv("drift.interval", "WEBHOOK_DRIFT_INTERVAL")
+	_ = viperConfig.BindEnv("drift.auto_correct", "WEBHOOK_DRIFT_AUTO_CORRECT")
+	_ = viperConfig.BindEnv("drift.state_file", "WEBHOOK_DRIFT_STATE_FILE")
+	_ = viperConfig.BindEnv("clients.enabled", "WEBHOOK_CLIENTS_ENABLED")
+	_ = viperConfig.BindEnv("clients.domain", "WEBHOOK_CLIENTS_DOMAIN")
+	_ = viperConfig.BindEnv("clients.interval", "WEBHOOK_CLIENTS_INTERVAL")
+	_ = viperConfig.BindEnv("clients.ttl", "WEBHOOK_CLIENTS_TTL")
+	_ = viperConfig.BindEnv("clients.networks", "WEBHOOK_CLIENTS_NETWORKS")
+	_ = viperConfig.BindEnv("clients.include", "WEBHOOK_CLIENTS_INCLUDE")
+	_ = viperConfig.BindEnv("clients.exclude", "WEBHOOK_CLIENTS_EXCLUDE")
+	_ = viperConfig.BindEnv("clients.grace_period", "WEBHOOK_CLIENTS_GRACE_PERIOD")
+	_ = viperConfig.BindEnv("clients.state_file", "WEBHOOK_CLIENTS_STATE_FILE")
+	_ = viperConfig.BindEnv("devices.enabled", "WEBHOOK_DEVICES_ENABLED")
+	_ = viperConfig.BindEnv("devices.domain", "WEBHOOK_DEVICES_DOMAIN")
+	_ = viperConfig.BindEnv("devices.interval", "WEBHOOK_DEVICES_INTERVAL")
+	_ = viperConfig.BindEnv("devices.ttl", "WEBHOOK_DEVICES_TTL")
+	_ = viperConfig.BindEnv("devices.template", "WEBHOOK_DEVICES_TEMPLATE")
+	_ = viperConfig.BindEnv("devices.state_file", "WEBHOOK_DEVICES_STATE_FILE")
+	_ = viperConfig.BindEnv("docker.enabled", "WEBHOOK_DOCKER_ENABLED")
+	_ = viperConfig.BindEnv("docker.socket", "WEBHOOK_DOCKER_SOCKET")
+	_ = viperConfig.BindEnv("docker.domain", "WEBHOOK_DOCKER_DOMAIN")
+	_ = viperConfig.BindEnv("docker.interval", "WEBHOOK_DOCKER_INTERVAL")
+	_ = viperConfig.BindEnv("docker.ttl", "WEBHOOK_DOCKER_TTL")
+	_ = viperConfig.BindEnv("docker.target", "WEBHOOK_DOCKER_TARGET")
+	_ = viperConfig.BindEnv("docker.grace_period", "WEBHOOK_DOCKER_GRACE_PERIOD")
+	_ = viperConfig.BindEnv("docker.state_file", "WEBHOOK_DOCKER_STATE_FILE")
+	_ = viperConfig.BindEnv("static_records.file", "WEBHOOK_STATIC_RECORDS_FILE")
+	_ = viperConfig.BindEnv("static_records.interval", "WEBHOOK_STATIC_RECORDS_INTERVAL")
+	_ = viperConfig.BindEnv("reconcile.source", "WEBHOOK_RECONCILE_SOURCE")
+	_ = viperConfig.BindEnv("reconcile.interval", "WEBHOOK_RECONCILE_INTERVAL")
+	_ = viperConfig.BindEnv("reconcile.policy", "WEBHOOK_RECONCILE_POLICY")
+	_ = viperConfig.BindEnv("reconcile.managed_record_types", "WEBHOOK_RECONCILE_MANAGED_RECORD_TYPES")
 	_ = viperConfig.BindEnv("logging.level", "WEBHOOK_LOGGING_LEVEL")
 	_ = viperConfig.BindEnv("logging.format", "WEBHOOK_LOGGING_FORMAT")
 	_ = viperConfig.BindEnv("debug.pprof_enabled", "WEBHOOK_DEBUG_PPROF_ENABLED")
 	_ = viperConfig.BindEnv("debug.pprof_port", "WEBHOOK_DEBUG_PPROF_PORT")
 
+	configFile := ""
+
+	if flags != nil {
+		err := bindFlags(viperConfig, flags)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to bind flags")
+		}
+
+		configFile, _ = flags.GetString(configFlag)
+	}
+
 	// Try to read config file
-	viperConfig.SetConfigName("config")
 	viperConfig.SetConfigType("yaml")
-	viperConfig.AddConfigPath(".")
-	viperConfig.AddConfigPath("/etc/external-dns-unifios-webhook/")
-	viperConfig.AddConfigPath("$HOME/.external-dns-unifios-webhook/")
+
+	if configFile != "" {
+		// An explicit file must exist, so a typo is not silently ignored
+		viperConfig.SetConfigFile(configFile)
+	} else {
+		viperConfig.SetConfigName("config")
+		viperConfig.AddConfigPath(".")
+		viperConfig.AddConfigPath("/etc/external-dns-unifios-webhook/")
+		viperConfig.AddConfigPath("$HOME/.external-dns-unifios-webhook/")
+	}
 
 	// Ignore config file not found error (config via env vars is fine)
 	err := viperConfig.ReadInConfig()
@@ -106,8 +357,13 @@ func Load() (*Config, error) {
 		return nil, errors.Wrap(err, "failed to unmarshal config")
 	}
 
+	cfg.File = viperConfig.ConfigFileUsed()
+	cfg.flags = flags
+
 	// Validate configuration
-	err = validate(&cfg)
+	cfg.UniFi.Host = strings.TrimSuffix(cfg.UniFi.Host, "/")
+
+	err = validate(&cfg, unknownKeys(viperConfig))
 	if err != nil {
 		return nil, err
 	}
@@ -115,40 +371,13 @@ func Load() (*Config, error) {
 	return &cfg, nil
 }
 
-// validate validates the configuration.
-func validate(cfg *Config) error {
-	// Validate required configuration
-	if cfg.UniFi.Host == "" {
-		return errors.New("WEBHOOK_UNIFI_HOST is required")
-	}
-
-	if cfg.UniFi.APIKey == "" {
-		return errors.New("WEBHOOK_UNIFI_API_KEY is required")
-	}
-
-	// Validate pprof port if pprof is enabled
-	if cfg.Debug.PprofEnabled {
-		port, err := strconv.Atoi(cfg.Debug.PprofPort)
-		if err != nil {
-			//nolint:wrapcheck // Creating new error, not wrapping
-			return errors.Newf("WEBHOOK_DEBUG_PPROF_PORT must be a valid number, got: %s", cfg.Debug.PprofPort)
-		}
-
-		if port < 1024 || port > 65535 {
-			//nolint:wrapcheck // Creating new error, not wrapping
-			return errors.Newf("WEBHOOK_DEBUG_PPROF_PORT must be between 1024 and 65535, got: %d", port)
-		}
-	}
-
-	return nil
-}
-
 // setDefaults sets default configuration values.
 func setDefaults(viperConfig *viper.Viper) {
 	// UniFi defaults
 	// NOTE: unifi.host has no default - must be explicitly configured
 	viperConfig.SetDefault("unifi.site", "default")
 	viperConfig.SetDefault("unifi.skip_tls_verify", true)
+	viperConfig.SetDefault("unifi.self_hosted", false)
 
 	// Server defaults
 	viperConfig.SetDefault("server.host", "localhost")
@@ -158,6 +387,63 @@ func setDefaults(viperConfig *viper.Viper) {
 	viperConfig.SetDefault("health.host", "0.0.0.0")
 	viperConfig.SetDefault("health.port", "8080")
 
+	// Protection defaults (protected records stay visible to external-dns)
+	viperConfig.SetDefault("protection.hide_protected", false)
+
+	// Target policy defaults (violating changes fail the batch)
+	viperConfig.SetDefault("target_policy.drop_violations", false)
+
+	// Freeze defaults (windows are configured in the config file only)
+	viperConfig.SetDefault("freeze.enabled", false)
+	viperConfig.SetDefault("freeze.mode", "reject")
+	viperConfig.SetDefault("freeze.timezone", "UTC")
+
+	// Limits defaults (matches the provider's built-in concurrency)
+	viperConfig.SetDefault("limits.max_concurrency", 5)
+
+	// Backup defaults (hourly snapshots, two days kept when nothing else changes)
+	viperConfig.SetDefault("backup.enabled", false)
+	viperConfig.SetDefault("backup.interval", "1h")
+	viperConfig.SetDefault("backup.retention", 48)
+
+	// Drift defaults (report only)
+	viperConfig.SetDefault("drift.enabled", false)
+	viperConfig.SetDefault("drift.interval", "5m")
+	viperConfig.SetDefault("drift.auto_correct", false)
+
+	// Clients defaults (names survive an hour of absence)
+	viperConfig.SetDefault("clients.enabled", false)
+	viperConfig.SetDefault("clients.interval", "1m")
+	viperConfig.SetDefault("clients.ttl", 300)
+	viperConfig.SetDefault("clients.grace_period", "1h")
+
+	// Devices defaults (devices are named as in UniFi)
+	viperConfig.SetDefault("devices.enabled", false)
+	viperConfig.SetDefault("devices.interval", "5m")
+	viperConfig.SetDefault("devices.ttl", 300)
+	viperConfig.SetDefault("devices.template", "{{.Name}}")
+
+	// Docker defaults (container events trigger syncs in between, records survive container restarts and recreation)
+	viperConfig.SetDefault("docker.enabled", false)
+	viperConfig.SetDefault("docker.socket", "/var/run/docker.sock")
+	viperConfig.SetDefault("docker.interval", "1m")
+	viperConfig.SetDefault("docker.ttl", 300)
+	viperConfig.SetDefault("docker.grace_period", "5m")
+
+	// Verification defaults (at most about 12 seconds per change set, failures are reported to external-dns)
+	viperConfig.SetDefault("verify.timeout", "2s")
+	viperConfig.SetDefault("verify.retries", 3)
+	viperConfig.SetDefault("verify.interval", "1s")
+	viperConfig.SetDefault("verify.fail_on_error", true)
+
+	// Static records defaults (the file itself is watched every few seconds)
+	viperConfig.SetDefault("static_records.interval", "1m")
+
+	// Reconcile defaults (the policy and record types of external-dns)
+	viperConfig.SetDefault("reconcile.interval", "1m")
+	viperConfig.SetDefault("reconcile.policy", "sync")
+	viperConfig.SetDefault("reconcile.managed_record_types", []string{"A", "AAAA", "CNAME"})
+
 	// Logging defaults
 	viperConfig.SetDefault("logging.level", "info")
 	viperConfig.SetDefault("logging.format", "json")
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+)
+
+// descriptions documents every setting for flag help and the JSON Schema, keyed by config file key.
+var descriptions = map[string]string{
+	"unifi.host":            "UniFi controller URL, e.g. https://192.168.1.1",
+	"unifi.api_key":         "UniFi API key",
+	"unifi.api_key_file":    "File containing the UniFi API key, reloaded when it changes",
+	"unifi.username":        "Local admin username for session authentication",
+	"unifi.password":        "Local admin password for session authentication",
+	"unifi.password_file":   "File containing the local admin password, reloaded when it changes",
+	"unifi.self_hosted":     "Controller is a self-hosted Network application instead of a UniFi OS console",
+	"unifi.site":            "UniFi site name",
+	"unifi.skip_tls_verify": "Skip verification of the controller certificate",
+	"unifi.ca_file":         "PEM bundle of CAs that sign the controller certificate",
+	"unifi.tls_fingerprint": "SHA-256 fingerprint of the controller certificate to pin",
+
+	"server.host":               "Webhook server listen address",
+	"server.port":               "Webhook server port",
+	"server.auth_token_file":    "File containing the bearer token required by the webhook API",
+	"server.auth_hmac_key_file": "File containing the HMAC key for request signatures",
+	"server.tls_cert_file":      "Webhook server TLS certificate",
+	"server.tls_key_file":       "Webhook server TLS private key",
+	"server.tls_client_ca_file": "CA bundle for verifying webhook client certificates (mTLS)",
+
+	"health.host":          "Health server listen address",
+	"health.port":          "Health server port",
+	"health.tls_cert_file": "Health server TLS certificate",
+	"health.tls_key_file":  "Health server TLS private key",
+
+	"domain_filter.filters":               "Domains to manage",
+	"domain_filter.exclude_filters":       "Domains to exclude",
+	"domain_filter.regex_filters":         "Regular expressions of domains to manage",
+	"domain_filter.regex_exclude_filters": "Regular expressions of domains to exclude",
+
+	"protection.names":          "Record names or globs the webhook never modifies",
+	"protection.regex_names":    "Regular expressions of record names the webhook never modifies",
+	"protection.record_ids":     "UniFi record IDs the webhook never modifies",
+	"protection.hide_protected": "Hide protected records from external-dns",
+
+	"rewrite.suffixes":           "Domain suffixes replaced in names stored in UniFi, as FROM=TO",
+	"rewrite.regexes":            "Name rewrites stored in UniFi, as PATTERN=TEMPLATE with ${name} group references",
+	"rewrite.targets":            "Target rewrites stored in UniFi, as CIDR=IP, IP=IP or HOST=HOST",
+	"rewrite.targets_state_file": "File the original targets of rewritten records are kept in across restarts",
+
+	"target_policy.allow":           "CIDRs or address classes A and AAAA records must point into",
+	"target_policy.deny":            "CIDRs or address classes A and AAAA records must not point into",
+	"target_policy.rules":           "Rules for some record types and domains, e.g. \"A dmz.example.com allow 10.20.0.0/16\"",
+	"target_policy.drop_violations": "Drop violating changes without failing the batch",
+
+	"verify.server":        "DNS server queried for created and updated records after applying, e.g. the gateway; empty disables verification",
+	"verify.timeout":       "Time a single verification lookup may take",
+	"verify.retries":       "Lookups repeated for a record that is not served yet",
+	"verify.interval":      "Time between lookups of a record",
+	"verify.fail_on_error": "Fail the change set when applied records are not served, so external-dns retries it",
+
+	"ptr.zones": "Reverse zones PTR records are maintained in, as in-addr.arpa/ip6.arpa names or CIDRs; empty disables PTR records",
+	"ptr.sink":  "Where PTR records are stored, required with zones: file, or unifi on Network versions that accept PTR records",
+	"ptr.file":  "Hosts file PTR records are written to with the file sink",
+
+	"freeze.enabled":  "Freeze DNS changes",
+	"freeze.mode":     "Handling of changes while frozen: reject or queue",
+	"freeze.windows":  "Recurring maintenance windows, e.g. \"Sat,Sun 02:00-04:00\"",
+	"freeze.timezone": "IANA time zone the maintenance windows are in, e.g. Europe/Berlin",
+
+	"limits.max_concurrency": "Maximum parallel DNS operations against the UniFi API",
+
+	"backup.enabled":   "Snapshot the site's DNS records on a schedule and before every change",
+	"backup.directory": "Directory the snapshots are written to",
+	"backup.interval":  "Time between scheduled snapshots, e.g. 1h; 0 only snapshots before changes",
+	"backup.retention": "Number of snapshots to keep",
+
+	"drift.enabled":      "Periodically compare managed records with the last applied desired state",
+	"drift.interval":     "Time between drift checks, e.g. 5m",
+	"drift.auto_correct": "Restore missing and modified records when drift is found",
+	"drift.state_file":   "File the desired state is kept in across restarts",
+
+	"clients.enabled":      "Publish A/AAAA records for connected UniFi clients",
+	"clients.domain":       "Domain the client records are published in, e.g. clients.home.lan",
+	"clients.interval":     "Time between client record syncs, e.g. 1m",
+	"clients.ttl":          "TTL of client records",
+	"clients.networks":     "Subnets of the networks whose clients are published",
+	"clients.include":      "Globs of client names to publish",
+	"clients.exclude":      "Globs of client names not to publish",
+	"clients.grace_period": "Time a client record is kept after the client disconnects",
+	"clients.state_file":   "File the created client records are kept in across restarts",
+
+	"devices.enabled":    "Publish A/AAAA records for UniFi gateways, switches and access points",
+	"devices.domain":     "Domain the device records are published in, e.g. net.home.lan",
+	"devices.interval":   "Time between device record syncs, e.g. 5m",
+	"devices.ttl":        "TTL of device records",
+	"devices.template":   "Go template for device names with .Name, .Model, .MAC and .Role, e.g. {{.Role}}-{{.Name}}",
+	"devices.state_file": "File the created device records are kept in across restarts",
+
+	"docker.enabled":      "Publish A/AAAA records for Docker containers labeled with unifi-dns.hostname",
+	"docker.socket":       "Path of the Docker Engine socket",
+	"docker.domain":       "Domain the container records are published in, e.g. docker.home.lan",
+	"docker.interval":     "Time between full container record syncs, e.g. 1m",
+	"docker.ttl":          "TTL of container records",
+	"docker.target":       "Address of containers without a unifi-dns.target label, usually the Docker host",
+	"docker.grace_period": "Time a container record is kept after the container stops",
+	"docker.state_file":   "File the created container records are kept in across restarts",
+
+	"static_records.file":     "YAML, JSON or zone file of records managed alongside external-dns, reloaded when it changes",
+	"static_records.interval": "Time between reconciliations of the static records, e.g. 1m",
+
+	"reconcile.source":               "Records file or directory of records files to apply without external-dns",
+	"reconcile.interval":             "Time between runs of the reconcile loop, e.g. 1m",
+	"reconcile.policy":               "How the reconcile loop applies changes: sync, upsert-only or create-only",
+	"reconcile.managed_record_types": "Record types the reconcile loop creates, updates and deletes",
+
+	"logging.level":  "Log level: debug, info, warn or error",
+	"logging.format": "Log format: json or text",
+
+	"debug.pprof_enabled": "Enable the pprof server on localhost",
+	"debug.pprof_port":    "pprof server port",
+}
+
+// field is a leaf setting of Config.
+type field struct {
+	// key is the config file key, e.g. "unifi.host"
+	key   string
+	spec  reflect.StructField
+	value reflect.Value
+}
+
+// secret reports whether the setting holds a credential that must not be printed.
+func (f field) secret() bool {
+	return f.spec.Tag.Get("json") == "-"
+}
+
+// flagName returns the command-line flag name, e.g. "unifi-host".
+func (f field) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(f.key)
+}
+
+// description returns the documentation of the setting.
+func (f field) description() string {
+	return descriptions[f.key]
+}
+
+// fields returns the leaf settings of cfg in declaration order.
+func fields(cfg *Config) []field {
+	return collectFields(reflect.ValueOf(cfg).Elem(), "")
+}
+
+// collectFields walks a struct and returns its leaf fields that have a config file key.
+func collectFields(value reflect.Value, prefix string) []field {
+	var result []field
+
+	for idx := range value.NumField() {
+		spec := value.Type().Field(idx)
+
+		name := spec.Tag.Get("mapstructure")
+		if name == "" || name == "-" {
+			continue
+		}
+
+		if spec.Type.Kind() == reflect.Struct {
+			result = append(result, collectFields(value.Field(idx), prefix+name+".")...)
+
+			continue
+		}
+
+		result = append(result, field{key: prefix + name, spec: spec, value: value.Field(idx)})
+	}
+
+	return result
+}
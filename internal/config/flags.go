@@ -0,0 +1,55 @@
+package config
+
+import (
+	"reflect"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+// configFlag names the flag that selects an explicit config file.
+const configFlag = "config"
+
+// RegisterFlags adds a flag for every setting, named after its config file key
+// ("unifi.host" becomes --unifi-host), and --config for an explicit config file.
+// Flags take precedence over environment variables, the config file and defaults.
+func RegisterFlags(flags *pflag.FlagSet) {
+	defaults := viper.New()
+	setDefaults(defaults)
+
+	flags.String(configFlag, "", "Config file to read instead of searching the default locations")
+
+	for _, setting := range fields(&Config{}) {
+		name := setting.flagName()
+		usage := setting.description()
+
+		switch setting.spec.Type.Kind() {
+		case reflect.Bool:
+			flags.Bool(name, defaults.GetBool(setting.key), usage)
+		case reflect.Int:
+			flags.Int(name, defaults.GetInt(setting.key), usage)
+		case reflect.Slice:
+			flags.StringSlice(name, defaults.GetStringSlice(setting.key), usage)
+		default:
+			flags.String(name, defaults.GetString(setting.key), usage)
+		}
+	}
+}
+
+// bindFlags makes flags registered by RegisterFlags override other sources.
+// Flags that were not set on the command line fall through to the environment.
+func bindFlags(viperConfig *viper.Viper, flags *pflag.FlagSet) error {
+	for _, setting := range fields(&Config{}) {
+		flag := flags.Lookup(setting.flagName())
+		if flag == nil {
+			continue
+		}
+
+		err := viperConfig.BindPFlag(setting.key, flag)
+		if err != nil {
+			return err //nolint:wrapcheck // Caller wraps with context
+		}
+	}
+
+	return nil
+}
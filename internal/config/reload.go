@@ -0,0 +1,158 @@
+package config
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+)
+
+// watchInterval is how often the config file is checked for changes.
+const watchInterval = 5 * time.Second
+
+// reloadableKeys lists keys, or key prefixes ending in ".", that take effect without a restart.
+// It must match what the reload callback in cmd/webhook applies to the running components.
+var reloadableKeys = []string{
+	"domain_filter.filters",
+	"domain_filter.exclude_filters",
+	"protection.",
+	"target_policy.",
+	"freeze.",
+	"limits.max_concurrency",
+	"logging.level",
+}
+
+// Changes lists the configuration keys that differ between two configurations.
+type Changes struct {
+	// Reloadable keys are applied by the running process.
+	Reloadable []string
+	// RestartRequired keys only take effect after a restart.
+	RestartRequired []string
+}
+
+// Empty reports whether nothing changed.
+func (c Changes) Empty() bool {
+	return len(c.Reloadable) == 0 && len(c.RestartRequired) == 0
+}
+
+// Diff compares two configurations key by key, using the config file key names.
+func Diff(previous, next *Config) Changes {
+	var changes Changes
+
+	nextFields := fields(next)
+
+	for idx, previousField := range fields(previous) {
+		if reflect.DeepEqual(previousField.value.Interface(), nextFields[idx].value.Interface()) {
+			continue
+		}
+
+		if reloadable(previousField.key) {
+			changes.Reloadable = append(changes.Reloadable, previousField.key)
+		} else {
+			changes.RestartRequired = append(changes.RestartRequired, previousField.key)
+		}
+	}
+
+	return changes
+}
+
+// reloadable reports whether key takes effect without a restart.
+func reloadable(key string) bool {
+	for _, candidate := range reloadableKeys {
+		if key == candidate || (strings.HasSuffix(candidate, ".") && strings.HasPrefix(key, candidate)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Watch polls the config file of current and reloads it when it changes.
+// Each valid configuration with reloadable changes is passed to apply; invalid
+// files and failed applies are logged and the running configuration is kept.
+// Watch returns immediately when no config file was read.
+func Watch(ctx context.Context, current *Config, apply func(next *Config, changes Changes) error) {
+	if current.File == "" {
+		return
+	}
+
+	initial := current
+	modTime := fileModTime(current.File)
+
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		latest := fileModTime(current.File)
+		if latest.Equal(modTime) {
+			continue
+		}
+
+		modTime = latest
+
+		next, err := LoadFlags(current.flags)
+		if err != nil {
+			dnsmetrics.ConfigReloads.WithLabelValues("error").Inc()
+			slog.ErrorContext(ctx, "invalid configuration file, keeping the running configuration",
+				"file", current.File, "error", err)
+
+			continue
+		}
+
+		changes := Diff(current, next)
+		if changes.Empty() {
+			continue
+		}
+
+		// Restart-required changes are reported against the startup configuration
+		// so that reverting them clears the warning
+		pending := Diff(initial, next).RestartRequired
+
+		if len(changes.Reloadable) > 0 {
+			err = apply(next, changes)
+			if err != nil {
+				dnsmetrics.ConfigReloads.WithLabelValues("error").Inc()
+				slog.ErrorContext(ctx, "failed to apply configuration, keeping the running configuration",
+					"file", current.File, "error", err)
+
+				continue
+			}
+		}
+
+		current = next
+
+		dnsmetrics.ConfigReloads.WithLabelValues("success").Inc()
+		dnsmetrics.ConfigRestartRequired.Set(float64(len(pending)))
+
+		slog.InfoContext(ctx, "configuration reloaded",
+			"file", current.File,
+			"applied", changes.Reloadable,
+			"restart_required", pending)
+
+		if len(changes.RestartRequired) > 0 {
+			slog.WarnContext(ctx, "configuration changes were not applied, restart to apply them",
+				"file", current.File,
+				"keys", changes.RestartRequired)
+		}
+	}
+}
+
+// fileModTime returns the modification time of path, or the zero time if it cannot be read.
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
@@ -0,0 +1,51 @@
+//nolint:testpackage // Testing private functions requires same-package tests
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	previous := &Config{
+		UniFi:        UniFiConfig{Host: "https://192.168.1.1", APIKey: "old"},
+		DomainFilter: DomainFilterConfig{Filters: []string{"example.com"}},
+		Limits:       LimitsConfig{MaxConcurrency: 5},
+		Logging:      LoggingConfig{Level: "info", Format: "json"},
+		File:         "/etc/external-dns-unifios-webhook/config.yaml",
+	}
+
+	unchanged := *previous
+	assert.True(t, Diff(previous, &unchanged).Empty())
+
+	next := *previous
+	next.UniFi.APIKey = "new"
+	next.DomainFilter.Filters = []string{"example.com", "example.org"}
+	next.DomainFilter.RegexFilters = []string{`\.example\.net$`}
+	next.Limits.MaxConcurrency = 10
+	next.Logging.Level = "debug"
+	next.Logging.Format = "text"
+	next.File = "config.yaml"
+
+	changes := Diff(previous, &next)
+
+	assert.Equal(t, []string{"domain_filter.filters", "limits.max_concurrency", "logging.level"}, changes.Reloadable)
+	assert.Equal(t, []string{"unifi.api_key", "domain_filter.regex_filters", "logging.format"}, changes.RestartRequired)
+}
+
+func TestDiff_Freeze(t *testing.T) {
+	t.Parallel()
+
+	previous := &Config{Freeze: FreezeConfig{Mode: "reject", Timezone: "UTC"}}
+
+	next := *previous
+	next.Freeze = FreezeConfig{Enabled: true, Mode: "queue", Windows: []string{"Sat 02:00-04:00"}, Timezone: "Europe/Berlin"}
+
+	changes := Diff(previous, &next)
+
+	assert.Equal(t, []string{"freeze.enabled", "freeze.mode", "freeze.windows", "freeze.timezone"}, changes.Reloadable)
+	assert.Empty(t, changes.RestartRequired)
+}
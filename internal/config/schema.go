@@ -0,0 +1,135 @@
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/viper"
+)
+
+// redactedValue replaces secrets in printed configuration.
+const redactedValue = "<redacted>"
+
+// schemaID identifies the config file schema.
+const schemaID = "https://github.com/lexfrei/external-dns-unifios-webhook/config.schema.json"
+
+// enums lists the allowed values of settings with a fixed set of choices.
+var enums = map[string][]string{
+	"freeze.mode":      {"reject", "queue"},
+	"reconcile.policy": reconcilePolicies,
+	"logging.level":    {"debug", "info", "warn", "error"},
+	"logging.format":   {"json", "text"},
+}
+
+// Redacted returns the configuration as nested maps keyed like the config file,
+// with secrets replaced so the result can be printed or logged.
+func (c *Config) Redacted() map[string]any {
+	result := make(map[string]any)
+
+	for _, setting := range fields(c) {
+		value := setting.value.Interface()
+		if duration, ok := value.(time.Duration); ok {
+			value = duration.String()
+		}
+
+		if setting.secret() && !setting.value.IsZero() {
+			value = redactedValue
+		}
+
+		setNested(result, setting.key, value)
+	}
+
+	return result
+}
+
+// Schema returns a JSON Schema describing the config file.
+func Schema() ([]byte, error) {
+	defaults := viper.New()
+	setDefaults(defaults)
+
+	root := map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"$id":                  schemaID,
+		"title":                "external-dns-unifios-webhook configuration",
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           map[string]any{},
+	}
+
+	for _, setting := range fields(&Config{}) {
+		property := map[string]any{
+			"description": setting.description(),
+		}
+
+		switch setting.spec.Type.Kind() {
+		case reflect.Bool:
+			property["type"] = "boolean"
+		case reflect.Int:
+			property["type"] = "integer"
+		case reflect.Slice:
+			property["type"] = "array"
+			property["items"] = map[string]any{"type": "string"}
+		default:
+			property["type"] = "string"
+		}
+
+		if values, ok := enums[setting.key]; ok {
+			property["enum"] = values
+		}
+
+		if defaults.IsSet(setting.key) {
+			property["default"] = defaults.Get(setting.key)
+		}
+
+		section, name, _ := strings.Cut(setting.key, ".")
+		sectionSchema := schemaSection(root, section)
+		sectionSchema["properties"].(map[string]any)[name] = property //nolint:forcetypeassert // Built by schemaSection
+	}
+
+	schema, err := json.MarshalIndent(root, "", "  ")
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode schema")
+	}
+
+	return append(schema, '\n'), nil
+}
+
+// schemaSection returns the object schema of a top-level section, creating it on first use.
+func schemaSection(root map[string]any, section string) map[string]any {
+	properties := root["properties"].(map[string]any) //nolint:forcetypeassert // Built by Schema
+
+	existing, ok := properties[section].(map[string]any)
+	if ok {
+		return existing
+	}
+
+	created := map[string]any{
+		"type":                 "object",
+		"additionalProperties": false,
+		"properties":           map[string]any{},
+	}
+	properties[section] = created
+
+	return created
+}
+
+// setNested stores value in nested maps following a dotted key.
+func setNested(target map[string]any, key string, value any) {
+	section, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		target[key] = value
+
+		return
+	}
+
+	child, ok := target[section].(map[string]any)
+	if !ok {
+		child = make(map[string]any)
+		target[section] = child
+	}
+
+	setNested(child, rest, value)
+}
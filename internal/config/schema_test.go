@@ -0,0 +1,77 @@
+//nolint:testpackage // Testing private functions requires same-package tests
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDescriptions_CoverEverySetting(t *testing.T) {
+	t.Parallel()
+
+	settings := fields(&Config{})
+	for _, setting := range settings {
+		assert.NotEmpty(t, setting.description(), "setting %s has no description", setting.key)
+	}
+
+	assert.Len(t, descriptions, len(settings), "descriptions contain keys that are not settings")
+}
+
+func TestSchema_UpToDate(t *testing.T) {
+	t.Parallel()
+
+	schema, err := Schema()
+	require.NoError(t, err)
+
+	committed, err := os.ReadFile("../../docs/configuration/config.schema.json")
+	require.NoError(t, err)
+
+	assert.Equal(t, string(committed), string(schema),
+		"regenerate with: go run ./cmd/webhook schema > docs/configuration/config.schema.json")
+}
+
+func TestRedacted(t *testing.T) {
+	t.Parallel()
+
+	cfg := &Config{UniFi: UniFiConfig{Host: "https://192.168.1.1", APIKey: "secret-key"}}
+
+	unifi, ok := cfg.Redacted()["unifi"].(map[string]any)
+	require.True(t, ok)
+	assert.Equal(t, "https://192.168.1.1", unifi["host"])
+	assert.Equal(t, redactedValue, unifi["api_key"])
+	assert.Empty(t, unifi["password"], "unset secrets stay empty")
+}
+
+//nolint:paralleltest // Modifies environment variables
+func TestLoadFlags_Precedence(t *testing.T) {
+	configFile := t.TempDir() + "/config.yaml"
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+unifi:
+  host: https://file.example
+  api_key: file-key
+  site: file-site
+logging:
+  level: warn
+  format: text
+`), 0o600))
+
+	t.Setenv("WEBHOOK_UNIFI_SITE", "env-site")
+	t.Setenv("WEBHOOK_LOGGING_LEVEL", "error")
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--config", configFile, "--logging-level", "debug"}))
+
+	cfg, err := LoadFlags(flags)
+	require.NoError(t, err)
+
+	assert.Equal(t, "debug", cfg.Logging.Level, "flag wins over environment")
+	assert.Equal(t, "env-site", cfg.UniFi.Site, "environment wins over file")
+	assert.Equal(t, "text", cfg.Logging.Format, "file wins over default")
+	assert.Equal(t, "8888", cfg.Server.Port, "default applies when unset")
+	assert.Equal(t, configFile, cfg.File)
+}
@@ -0,0 +1,466 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// minPollInterval keeps periodic checks from competing with external-dns for the UniFi API.
+const minPollInterval = 10 * time.Second
+
+// maxConcurrencyLimit caps parallel UniFi API operations; controllers throttle beyond this.
+const maxConcurrencyLimit = 50
+
+// maxVerifyDuration keeps verification well within the webhook server's write timeout.
+const maxVerifyDuration = 30 * time.Second
+
+// maxSuggestionDistance is the largest edit distance for which an unknown key gets a suggestion.
+const maxSuggestionDistance = 3
+
+// hostnamePattern matches DNS hostnames made of letters, digits and hyphens.
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+
+// Allowed values of enumerated settings.
+var (
+	logLevels  = []string{"debug", "info", "warn", "error"}
+	logFormats = []string{"json", "text"}
+
+	reconcilePolicies = []string{"sync", "upsert-only", "create-only"}
+	recordTypes       = []string{"A", "AAAA", "CNAME", "MX", "NS", "SRV", "TXT"}
+	ptrSinks          = []string{"unifi", "file"}
+)
+
+// ValidationError lists every problem found in a configuration.
+type ValidationError struct {
+	Problems []string
+}
+
+// Error implements error.
+func (e *ValidationError) Error() string {
+	if len(e.Problems) == 1 {
+		return e.Problems[0]
+	}
+
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
+
+// problems collects validation failures.
+type problems []string
+
+func (p *problems) add(format string, args ...any) {
+	*p = append(*p, fmt.Sprintf(format, args...))
+}
+
+// validate checks the whole configuration and reports every problem at once.
+func validate(cfg *Config, unknown []string) error {
+	var found problems
+
+	for _, key := range unknown {
+		if suggestion := closestKey(key); suggestion != "" {
+			found.add("unknown config key %q, did you mean %q?", key, suggestion)
+		} else {
+			found.add("unknown config key %q", key)
+		}
+	}
+
+	validateUniFi(&found, &cfg.UniFi)
+	validateListener(&found, "WEBHOOK_SERVER", cfg.Server.Host, cfg.Server.Port)
+	validateListener(&found, "WEBHOOK_HEALTH", cfg.Health.Host, cfg.Health.Port)
+
+	if cfg.Server.Port == cfg.Health.Port {
+		found.add("WEBHOOK_SERVER_PORT and WEBHOOK_HEALTH_PORT must differ, both are %s", cfg.Server.Port)
+	}
+
+	// TLS certificates and keys must be configured together
+	if (cfg.Server.TLSCertFile == "") != (cfg.Server.TLSKeyFile == "") {
+		found.add("WEBHOOK_SERVER_TLS_CERT_FILE and WEBHOOK_SERVER_TLS_KEY_FILE must be set together")
+	}
+
+	if cfg.Server.TLSClientCAFile != "" && cfg.Server.TLSCertFile == "" {
+		found.add("WEBHOOK_SERVER_TLS_CLIENT_CA_FILE requires WEBHOOK_SERVER_TLS_CERT_FILE")
+	}
+
+	if (cfg.Health.TLSCertFile == "") != (cfg.Health.TLSKeyFile == "") {
+		found.add("WEBHOOK_HEALTH_TLS_CERT_FILE and WEBHOOK_HEALTH_TLS_KEY_FILE must be set together")
+	}
+
+	if cfg.Freeze.Mode != "reject" && cfg.Freeze.Mode != "queue" {
+		found.add("WEBHOOK_FREEZE_MODE must be reject or queue, got: %s", cfg.Freeze.Mode)
+	}
+
+	if _, err := time.LoadLocation(cfg.Freeze.Timezone); err != nil {
+		found.add("WEBHOOK_FREEZE_TIMEZONE must be an IANA time zone name, got: %s", cfg.Freeze.Timezone)
+	}
+
+	if cfg.Limits.MaxConcurrency < 1 || cfg.Limits.MaxConcurrency > maxConcurrencyLimit {
+		found.add("WEBHOOK_LIMITS_MAX_CONCURRENCY must be between 1 and %d, got: %d",
+			maxConcurrencyLimit, cfg.Limits.MaxConcurrency)
+	}
+
+	if cfg.Verify.Server != "" {
+		validateVerify(&found, &cfg.Verify)
+	}
+
+	if len(cfg.PTR.Zones) > 0 {
+		validatePTR(&found, &cfg.PTR)
+	}
+
+	if cfg.Backup.Enabled {
+		validateBackup(&found, &cfg.Backup)
+	}
+
+	if cfg.Drift.Enabled && cfg.Drift.Interval < minPollInterval {
+		found.add("WEBHOOK_DRIFT_INTERVAL must be at least %s, got: %s", minPollInterval, cfg.Drift.Interval)
+	}
+
+	if cfg.Clients.Enabled {
+		validateClients(&found, &cfg.Clients)
+	}
+
+	if cfg.Devices.Enabled {
+		validateDevices(&found, &cfg.Devices)
+	}
+
+	if cfg.Docker.Enabled {
+		validateDocker(&found, &cfg.Docker)
+	}
+
+	validateSourceDomains(&found, cfg)
+
+	if cfg.Static.File != "" && cfg.Static.Interval < minPollInterval {
+		found.add("WEBHOOK_STATIC_RECORDS_INTERVAL must be at least %s, got: %s", minPollInterval, cfg.Static.Interval)
+	}
+
+	if cfg.Reconcile.Source != "" {
+		validateReconcile(&found, &cfg.Reconcile)
+	}
+
+	if !slices.Contains(logLevels, cfg.Logging.Level) {
+		found.add("WEBHOOK_LOGGING_LEVEL must be one of %s, got: %s", strings.Join(logLevels, ", "), cfg.Logging.Level)
+	}
+
+	if !slices.Contains(logFormats, cfg.Logging.Format) {
+		found.add("WEBHOOK_LOGGING_FORMAT must be one of %s, got: %s", strings.Join(logFormats, ", "), cfg.Logging.Format)
+	}
+
+	// Validate pprof port if pprof is enabled
+	if cfg.Debug.PprofEnabled {
+		port, err := strconv.Atoi(cfg.Debug.PprofPort)
+
+		switch {
+		case err != nil:
+			found.add("WEBHOOK_DEBUG_PPROF_PORT must be a valid number, got: %s", cfg.Debug.PprofPort)
+		case port < 1024 || port > 65535:
+			found.add("WEBHOOK_DEBUG_PPROF_PORT must be between 1024 and 65535, got: %d", port)
+		}
+	}
+
+	if len(found) > 0 {
+		return &ValidationError{Problems: found}
+	}
+
+	return nil
+}
+
+// validateVerify checks the settings of post-apply verification.
+func validateVerify(found *problems, cfg *VerifyConfig) {
+	host := cfg.Server
+	if splitHost, _, err := net.SplitHostPort(cfg.Server); err == nil {
+		host = splitHost
+	}
+
+	if _, err := netip.ParseAddr(strings.Trim(host, "[]")); err != nil && !hostnamePattern.MatchString(host) {
+		found.add("WEBHOOK_VERIFY_SERVER must be an address or hostname with an optional port, got: %s", cfg.Server)
+	}
+
+	if cfg.Timeout <= 0 {
+		found.add("WEBHOOK_VERIFY_TIMEOUT must be positive, got: %s", cfg.Timeout)
+	}
+
+	if cfg.Retries < 0 {
+		found.add("WEBHOOK_VERIFY_RETRIES must not be negative, got: %d", cfg.Retries)
+	}
+
+	if cfg.Interval <= 0 {
+		found.add("WEBHOOK_VERIFY_INTERVAL must be positive, got: %s", cfg.Interval)
+	}
+
+	if total := (cfg.Timeout + cfg.Interval) * time.Duration(cfg.Retries+1); total > maxVerifyDuration {
+		found.add("verification may take up to %s, at most %s is allowed; lower WEBHOOK_VERIFY_TIMEOUT, WEBHOOK_VERIFY_INTERVAL or WEBHOOK_VERIFY_RETRIES",
+			total, maxVerifyDuration)
+	}
+}
+
+// validatePTR checks the settings of reverse record maintenance.
+func validatePTR(found *problems, cfg *PTRConfig) {
+	// Neither sink is a safe default: one needs another DNS server, the other a Network version accepting PTR records
+	if cfg.Sink == "" {
+		found.add("WEBHOOK_PTR_SINK is required when WEBHOOK_PTR_ZONES is set, one of %s", strings.Join(ptrSinks, ", "))
+	} else if !slices.Contains(ptrSinks, cfg.Sink) {
+		found.add("WEBHOOK_PTR_SINK must be one of %s, got: %s", strings.Join(ptrSinks, ", "), cfg.Sink)
+	}
+
+	if cfg.Sink == "file" && cfg.File == "" {
+		found.add("WEBHOOK_PTR_FILE is required when WEBHOOK_PTR_SINK is file")
+	}
+}
+
+// validateBackup checks the snapshot settings.
+func validateBackup(found *problems, cfg *BackupConfig) {
+	if cfg.Directory == "" {
+		found.add("WEBHOOK_BACKUP_DIRECTORY is required when backups are enabled")
+	}
+
+	if cfg.Interval < 0 {
+		found.add("WEBHOOK_BACKUP_INTERVAL must not be negative, got: %s", cfg.Interval)
+	}
+
+	if cfg.Retention < 1 {
+		found.add("WEBHOOK_BACKUP_RETENTION must be at least 1, got: %d", cfg.Retention)
+	}
+}
+
+// validateReconcile checks the settings of the standalone reconcile loop.
+func validateReconcile(found *problems, cfg *ReconcileConfig) {
+	if cfg.Interval < minPollInterval {
+		found.add("WEBHOOK_RECONCILE_INTERVAL must be at least %s, got: %s", minPollInterval, cfg.Interval)
+	}
+
+	if !slices.Contains(reconcilePolicies, cfg.Policy) {
+		found.add("WEBHOOK_RECONCILE_POLICY must be one of %s, got: %s", strings.Join(reconcilePolicies, ", "), cfg.Policy)
+	}
+
+	if len(cfg.ManagedRecordTypes) == 0 {
+		found.add("WEBHOOK_RECONCILE_MANAGED_RECORD_TYPES must list at least one record type")
+	}
+
+	for _, recordType := range cfg.ManagedRecordTypes {
+		if !slices.Contains(recordTypes, recordType) {
+			found.add("WEBHOOK_RECONCILE_MANAGED_RECORD_TYPES must only contain %s, got: %s",
+				strings.Join(recordTypes, ", "), recordType)
+		}
+	}
+}
+
+// validateClients checks the client record settings.
+func validateClients(found *problems, cfg *ClientsConfig) {
+	validateSource(found, "WEBHOOK_CLIENTS", cfg.Domain, cfg.Interval, cfg.TTL)
+
+	if cfg.GracePeriod < 0 {
+		found.add("WEBHOOK_CLIENTS_GRACE_PERIOD must not be negative, got: %s", cfg.GracePeriod)
+	}
+
+	for _, network := range cfg.Networks {
+		_, err := netip.ParsePrefix(network)
+		if err != nil {
+			found.add("WEBHOOK_CLIENTS_NETWORKS must contain CIDR subnets, got: %s", network)
+		}
+	}
+
+	for _, glob := range slices.Concat(cfg.Include, cfg.Exclude) {
+		_, err := path.Match(glob, "")
+		if err != nil {
+			found.add("WEBHOOK_CLIENTS_INCLUDE and WEBHOOK_CLIENTS_EXCLUDE must contain valid globs, got: %s", glob)
+		}
+	}
+}
+
+// validateDevices checks the device record settings. The template is checked when it is parsed.
+func validateDevices(found *problems, cfg *DevicesConfig) {
+	validateSource(found, "WEBHOOK_DEVICES", cfg.Domain, cfg.Interval, cfg.TTL)
+
+	if strings.TrimSpace(cfg.Template) == "" {
+		found.add("WEBHOOK_DEVICES_TEMPLATE must not be empty")
+	}
+}
+
+func validateDocker(found *problems, cfg *DockerConfig) {
+	validateSource(found, "WEBHOOK_DOCKER", cfg.Domain, cfg.Interval, cfg.TTL)
+
+	if cfg.Socket == "" {
+		found.add("WEBHOOK_DOCKER_SOCKET must not be empty")
+	}
+
+	if cfg.Target != "" {
+		_, err := netip.ParseAddr(cfg.Target)
+		if err != nil {
+			found.add("WEBHOOK_DOCKER_TARGET must be an IP address, got: %s", cfg.Target)
+		}
+	}
+
+	if cfg.GracePeriod < 0 {
+		found.add("WEBHOOK_DOCKER_GRACE_PERIOD must not be negative, got: %s", cfg.GracePeriod)
+	}
+}
+
+// validateSourceDomains checks that enabled built-in sources publish in different
+// domains, since each deletes the records below its domain that it did not publish.
+func validateSourceDomains(found *problems, cfg *Config) {
+	sources := []struct {
+		enabled bool
+		prefix  string
+		domain  string
+	}{
+		{cfg.Clients.Enabled, "WEBHOOK_CLIENTS", cfg.Clients.Domain},
+		{cfg.Devices.Enabled, "WEBHOOK_DEVICES", cfg.Devices.Domain},
+		{cfg.Docker.Enabled, "WEBHOOK_DOCKER", cfg.Docker.Domain},
+	}
+
+	for idx, first := range sources {
+		for _, second := range sources[idx+1:] {
+			if first.enabled && second.enabled &&
+				strings.EqualFold(strings.TrimSuffix(first.domain, "."), strings.TrimSuffix(second.domain, ".")) {
+				found.add("%s_DOMAIN and %s_DOMAIN must differ, both are %s", first.prefix, second.prefix, first.domain)
+			}
+		}
+	}
+}
+
+// validateSource checks the settings shared by the built-in record sources.
+func validateSource(found *problems, prefix, domain string, interval time.Duration, ttl int) {
+	trimmed := strings.TrimSuffix(domain, ".")
+
+	switch {
+	case trimmed == "":
+		found.add("%s_DOMAIN is required when %s_ENABLED is set", prefix, prefix)
+	case len(trimmed) > 253 || !hostnamePattern.MatchString(trimmed):
+		found.add("%s_DOMAIN must be a domain name, got: %s", prefix, domain)
+	}
+
+	if interval < minPollInterval {
+		found.add("%s_INTERVAL must be at least %s, got: %s", prefix, minPollInterval, interval)
+	}
+
+	if ttl < 0 {
+		found.add("%s_TTL must not be negative, got: %d", prefix, ttl)
+	}
+}
+
+// validateUniFi checks the controller URL and authentication settings.
+func validateUniFi(found *problems, cfg *UniFiConfig) {
+	if cfg.Host == "" {
+		found.add("WEBHOOK_UNIFI_HOST is required")
+	} else {
+		parsed, err := url.Parse(cfg.Host)
+
+		switch {
+		case err != nil:
+			found.add("WEBHOOK_UNIFI_HOST must be a URL, got: %s", cfg.Host)
+		case parsed.Scheme != "https" && parsed.Scheme != "http":
+			found.add("WEBHOOK_UNIFI_HOST must start with https://, got: %s", cfg.Host)
+		case parsed.Hostname() == "":
+			found.add("WEBHOOK_UNIFI_HOST has no host, got: %s", cfg.Host)
+		case parsed.Path != "" || parsed.RawQuery != "" || parsed.Fragment != "":
+			found.add("WEBHOOK_UNIFI_HOST must be the controller root URL without a path, got: %s", cfg.Host)
+		}
+	}
+
+	if cfg.Site == "" {
+		found.add("WEBHOOK_UNIFI_SITE must not be empty")
+	}
+
+	hasAPIKey := cfg.APIKey != "" || cfg.APIKeyFile != ""
+	hasPassword := cfg.Password != "" || cfg.PasswordFile != ""
+
+	switch {
+	case hasAPIKey && (cfg.Username != "" || hasPassword):
+		found.add("WEBHOOK_UNIFI_API_KEY and WEBHOOK_UNIFI_USERNAME/WEBHOOK_UNIFI_PASSWORD are mutually exclusive")
+	case !hasAPIKey && cfg.Username == "" && !hasPassword:
+		found.add("WEBHOOK_UNIFI_API_KEY or WEBHOOK_UNIFI_USERNAME and WEBHOOK_UNIFI_PASSWORD are required")
+	case !hasAPIKey && (cfg.Username == "" || !hasPassword):
+		found.add("WEBHOOK_UNIFI_USERNAME and WEBHOOK_UNIFI_PASSWORD must be set together")
+	}
+
+	// A secret in the environment would silently shadow the rotated file
+	if cfg.APIKey != "" && cfg.APIKeyFile != "" {
+		found.add("WEBHOOK_UNIFI_API_KEY and WEBHOOK_UNIFI_API_KEY_FILE are mutually exclusive")
+	}
+
+	if cfg.Password != "" && cfg.PasswordFile != "" {
+		found.add("WEBHOOK_UNIFI_PASSWORD and WEBHOOK_UNIFI_PASSWORD_FILE are mutually exclusive")
+	}
+}
+
+// validateListener checks a listen address. An empty host listens on all interfaces.
+func validateListener(found *problems, prefix, host, port string) {
+	if host != "" && net.ParseIP(host) == nil && (len(host) > 253 || !hostnamePattern.MatchString(host)) {
+		found.add("%s_HOST must be an IP address or hostname, got: %s", prefix, host)
+	}
+
+	number, err := strconv.Atoi(port)
+	if err != nil || number < 1 || number > 65535 {
+		found.add("%s_PORT must be a number between 1 and 65535, got: %s", prefix, port)
+	}
+}
+
+// unknownKeys returns config keys that do not correspond to any setting.
+// Environment variables and flags are bound to known keys, so these come from the config file.
+func unknownKeys(viperConfig *viper.Viper) []string {
+	known := make(map[string]bool)
+	for _, setting := range fields(&Config{}) {
+		known[setting.key] = true
+	}
+
+	var unknown []string
+
+	for _, key := range viperConfig.AllKeys() {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+
+	slices.Sort(unknown)
+
+	return unknown
+}
+
+// closestKey returns the known key most similar to key, or "" if none is close.
+func closestKey(key string) string {
+	best, bestDistance := "", maxSuggestionDistance+1
+
+	for _, setting := range fields(&Config{}) {
+		distance := editDistance(key, setting.key)
+		if distance < bestDistance {
+			best, bestDistance = setting.key, distance
+		}
+	}
+
+	return best
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(left, right string) int {
+	previous := make([]int, len(right)+1)
+	current := make([]int, len(right)+1)
+
+	for idx := range previous {
+		previous[idx] = idx
+	}
+
+	for leftIdx := 1; leftIdx <= len(left); leftIdx++ {
+		current[0] = leftIdx
+
+		for rightIdx := 1; rightIdx <= len(right); rightIdx++ {
+			cost := 1
+			if left[leftIdx-1] == right[rightIdx-1] {
+				cost = 0
+			}
+
+			current[rightIdx] = min(previous[rightIdx]+1, current[rightIdx-1]+1, previous[rightIdx-1]+cost)
+		}
+
+		previous, current = current, previous
+	}
+
+	return previous[len(right)]
+}
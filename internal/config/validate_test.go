@@ -0,0 +1,136 @@
+//nolint:testpackage // Testing private functions requires same-package tests
+package config
+
+import (
+	"os"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// validConfig returns a configuration that passes validation.
+func validConfig() *Config {
+	return &Config{
+		UniFi:   UniFiConfig{Host: "https://192.168.1.1", APIKey: "key", Site: "default"},
+		Server:  ServerConfig{Host: "localhost", Port: "8888"},
+		Health:  HealthConfig{Host: "0.0.0.0", Port: "8080"},
+		Freeze:  FreezeConfig{Mode: "reject"},
+		Limits:  LimitsConfig{MaxConcurrency: 5},
+		Logging: LoggingConfig{Level: "info", Format: "json"},
+	}
+}
+
+func TestValidate_Valid(t *testing.T) {
+	t.Parallel()
+
+	require.NoError(t, validate(validConfig(), nil))
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	t.Parallel()
+
+	cfg := validConfig()
+	cfg.UniFi.Host = "192.168.1.1"
+	cfg.Server.Port = "88888"
+	cfg.Health.Host = "not a host"
+	cfg.Logging.Level = "verbose"
+	cfg.Logging.Format = "xml"
+
+	err := validate(cfg, nil)
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{
+		"WEBHOOK_UNIFI_HOST must start with https://, got: 192.168.1.1",
+		"WEBHOOK_SERVER_PORT must be a number between 1 and 65535, got: 88888",
+		"WEBHOOK_HEALTH_HOST must be an IP address or hostname, got: not a host",
+		"WEBHOOK_LOGGING_LEVEL must be one of debug, info, warn, error, got: verbose",
+		"WEBHOOK_LOGGING_FORMAT must be one of json, text, got: xml",
+	}, validationErr.Problems)
+	assert.Contains(t, err.Error(), "invalid configuration:\n  - WEBHOOK_UNIFI_HOST")
+}
+
+func TestValidate_ControllerURL(t *testing.T) {
+	t.Parallel()
+
+	for host, valid := range map[string]bool{
+		"https://192.168.1.1":      true,
+		"https://unifi.local:8443": true,
+		"http://192.168.1.1":       true,
+		"ftp://192.168.1.1":        false,
+		"https://":                 false,
+		"https://192.168.1.1/api":  false,
+		"https://192.168.1.1?x=1":  false,
+	} {
+		cfg := validConfig()
+		cfg.UniFi.Host = host
+
+		if valid {
+			assert.NoError(t, validate(cfg, nil), host)
+		} else {
+			assert.Error(t, validate(cfg, nil), host)
+		}
+	}
+}
+
+//nolint:paralleltest // Loads configuration from the environment
+func TestLoadFlags_UnknownKeys(t *testing.T) {
+	configFile := t.TempDir() + "/config.yaml"
+	require.NoError(t, os.WriteFile(configFile, []byte(`
+unifi:
+  host: https://192.168.1.1/
+  api_key: key
+domain_filters:
+  filters:
+    - example.com
+logging:
+  levle: debug
+`), 0o600))
+
+	flags := pflag.NewFlagSet("test", pflag.ContinueOnError)
+	RegisterFlags(flags)
+	require.NoError(t, flags.Parse([]string{"--config", configFile}))
+
+	_, err := LoadFlags(flags)
+	require.Error(t, err)
+
+	var validationErr *ValidationError
+	require.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []string{
+		`unknown config key "domain_filters.filters", did you mean "domain_filter.filters"?`,
+		`unknown config key "logging.levle", did you mean "logging.level"?`,
+	}, validationErr.Problems, "a trailing slash on the controller URL is accepted")
+}
+
+func TestValidate_PTRSink(t *testing.T) {
+	t.Parallel()
+
+	cfg := validConfig()
+	cfg.PTR.Zones = []string{"1.168.192.in-addr.arpa"}
+
+	// Reverse zones need an explicitly chosen sink
+	var validationErr *ValidationError
+	require.ErrorAs(t, validate(cfg, nil), &validationErr)
+	assert.Equal(t, []string{"WEBHOOK_PTR_SINK is required when WEBHOOK_PTR_ZONES is set, one of unifi, file"}, validationErr.Problems)
+
+	cfg.PTR.Sink = "unifi"
+	require.NoError(t, validate(cfg, nil))
+}
+
+func TestValidate_FreezeTimezone(t *testing.T) {
+	t.Parallel()
+
+	cfg := validConfig()
+	cfg.Freeze.Timezone = "Europe/Berlin"
+	require.NoError(t, validate(cfg, nil))
+
+	cfg.Freeze.Timezone = "Mars/Olympus"
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, validate(cfg, nil), &validationErr)
+	assert.Equal(t, []string{"WEBHOOK_FREEZE_TIMEZONE must be an IANA time zone name, got: Mars/Olympus"}, validationErr.Problems)
+}
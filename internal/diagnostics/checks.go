@@ -0,0 +1,334 @@
+package diagnostics
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/config"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/freeze"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/tlsconfig"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/unificlient"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+const (
+	// sitesPageSize is the page size used to list controller sites.
+	sitesPageSize = 100
+
+	// probeTarget is a TEST-NET-1 address (RFC 5737) that never routes anywhere.
+	probeTarget = "192.0.2.1"
+)
+
+// hostnamePattern matches DNS hostnames made of letters, digits and hyphens.
+var hostnamePattern = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?(\.[A-Za-z0-9]([A-Za-z0-9-]{0,61}[A-Za-z0-9])?)*$`)
+
+// CheckConfig validates every configuration field beyond what config.Load requires.
+func CheckConfig(cfg *config.Config) *Report {
+	report := &Report{}
+
+	if cfg.File != "" {
+		report.Add("config.file", StatusPass, "loaded %s", cfg.File)
+	} else {
+		report.Add("config.file", StatusPass, "no config file, using environment only")
+	}
+
+	checkController(report, cfg.UniFi)
+	checkListeners(report, cfg)
+	checkDomainFilter(report, cfg.DomainFilter)
+	checkProtection(report, cfg.Protection)
+	checkFreeze(report, cfg.Freeze)
+	checkServerTLS(report, cfg)
+
+	return report
+}
+
+// checkController validates the controller URL and certificate trust settings.
+func checkController(report *Report, cfg config.UniFiConfig) {
+	parsed, err := url.Parse(cfg.Host)
+
+	switch {
+	case err != nil:
+		report.Add("config.unifi.host", StatusFail, "invalid URL %q: %v", cfg.Host, err)
+	case parsed.Scheme != "https" && parsed.Scheme != "http":
+		report.Add("config.unifi.host", StatusFail, "URL %q must start with https://", cfg.Host)
+	case parsed.Hostname() == "":
+		report.Add("config.unifi.host", StatusFail, "URL %q has no host", cfg.Host)
+	case parsed.Path != "" && parsed.Path != "/":
+		report.Add("config.unifi.host", StatusWarn, "URL %q has a path; the controller root URL is expected", cfg.Host)
+	case parsed.Scheme == "http":
+		report.Add("config.unifi.host", StatusWarn, "credentials are sent unencrypted to %s", cfg.Host)
+	default:
+		report.Add("config.unifi.host", StatusPass, "%s", cfg.Host)
+	}
+
+	trust := unificlient.TrustConfig{CAFile: cfg.CAFile, Fingerprint: cfg.TLSFingerprint, SkipVerify: cfg.SkipTLSVerify}
+
+	_, err = unificlient.NewTLSConfig(trust)
+
+	switch {
+	case err != nil:
+		report.Add("config.unifi.tls", StatusFail, "%v", err)
+	case trust.Insecure():
+		report.Add("config.unifi.tls", StatusWarn, "certificate verification is disabled")
+	default:
+		report.Add("config.unifi.tls", StatusPass, "controller certificate is verified")
+	}
+}
+
+// listener is an address the webhook listens on.
+type listener struct {
+	name string
+	host string
+	port string
+}
+
+// checkListeners validates the listen hosts and ports and that they do not collide.
+func checkListeners(report *Report, cfg *config.Config) {
+	listeners := []listener{
+		{"server", cfg.Server.Host, cfg.Server.Port},
+		{"health", cfg.Health.Host, cfg.Health.Port},
+	}
+
+	if cfg.Debug.PprofEnabled {
+		listeners = append(listeners, listener{"debug.pprof", "127.0.0.1", cfg.Debug.PprofPort})
+	}
+
+	seen := make(map[string]string, len(listeners))
+
+	for _, listener := range listeners {
+		name := "config." + listener.name + ".address"
+
+		if !validHost(listener.host) {
+			report.Add(name, StatusFail, "invalid host %q", listener.host)
+
+			continue
+		}
+
+		port, err := strconv.Atoi(listener.port)
+		if err != nil || port < 1 || port > 65535 {
+			report.Add(name, StatusFail, "port must be a number between 1 and 65535, got %q", listener.port)
+
+			continue
+		}
+
+		if other, ok := seen[listener.port]; ok {
+			report.Add(name, StatusFail, "port %d is also used by %s", port, other)
+
+			continue
+		}
+
+		seen[listener.port] = listener.name
+
+		report.Add(name, StatusPass, "%s", net.JoinHostPort(listener.host, listener.port))
+	}
+}
+
+// validHost reports whether host is an IP address or a hostname.
+func validHost(host string) bool {
+	if net.ParseIP(host) != nil {
+		return true
+	}
+
+	return len(host) <= 253 && hostnamePattern.MatchString(host)
+}
+
+// checkDomainFilter validates domain filter entries and regular expressions.
+func checkDomainFilter(report *Report, cfg config.DomainFilterConfig) {
+	for _, domain := range slices.Concat(cfg.Filters, cfg.ExcludeFilters) {
+		if strings.TrimSpace(domain) == "" {
+			report.Add("config.domain_filter", StatusFail, "empty domain in filter list")
+
+			return
+		}
+	}
+
+	for _, expression := range slices.Concat(cfg.RegexFilters, cfg.RegexExcludeFilters) {
+		_, err := regexp.Compile(expression)
+		if err != nil {
+			report.Add("config.domain_filter", StatusFail, "invalid regular expression %q: %v", expression, err)
+
+			return
+		}
+	}
+
+	if len(cfg.Filters) == 0 && len(cfg.RegexFilters) == 0 {
+		report.Add("config.domain_filter", StatusWarn, "no domain filter, all records on the controller are managed")
+
+		return
+	}
+
+	report.Add("config.domain_filter", StatusPass, "%d domains, %d exclusions",
+		len(cfg.Filters)+len(cfg.RegexFilters), len(cfg.ExcludeFilters)+len(cfg.RegexExcludeFilters))
+}
+
+// checkProtection validates protected record patterns.
+func checkProtection(report *Report, cfg config.ProtectionConfig) {
+	_, err := provider.NewProtection(cfg.Names, cfg.RegexNames, cfg.RecordIDs, cfg.HideProtected)
+	if err != nil {
+		report.Add("config.protection", StatusFail, "%v", err)
+
+		return
+	}
+
+	report.Add("config.protection", StatusPass, "%d names, %d patterns, %d record IDs",
+		len(cfg.Names), len(cfg.RegexNames), len(cfg.RecordIDs))
+}
+
+// checkFreeze validates maintenance windows.
+func checkFreeze(report *Report, cfg config.FreezeConfig) {
+	for _, window := range cfg.Windows {
+		_, err := freeze.ParseWindow(window)
+		if err != nil {
+			report.Add("config.freeze", StatusFail, "%v", err)
+
+			return
+		}
+	}
+
+	if cfg.Enabled {
+		report.Add("config.freeze", StatusWarn, "DNS changes are frozen")
+
+		return
+	}
+
+	report.Add("config.freeze", StatusPass, "%d maintenance windows", len(cfg.Windows))
+}
+
+// checkServerTLS loads the configured server certificates.
+func checkServerTLS(report *Report, cfg *config.Config) {
+	servers := []struct {
+		name, cert, key, clientCA string
+	}{
+		{"server", cfg.Server.TLSCertFile, cfg.Server.TLSKeyFile, cfg.Server.TLSClientCAFile},
+		{"health", cfg.Health.TLSCertFile, cfg.Health.TLSKeyFile, ""},
+	}
+
+	for _, server := range servers {
+		name := "config." + server.name + ".tls"
+
+		if server.cert == "" {
+			report.Add(name, StatusSkip, "TLS not configured")
+
+			continue
+		}
+
+		_, err := tlsconfig.NewServer(server.cert, server.key, server.clientCA)
+		if err != nil {
+			report.Add(name, StatusFail, "%v", err)
+
+			continue
+		}
+
+		report.Add(name, StatusPass, "certificate %s loaded", server.cert)
+	}
+}
+
+// CheckController connects to the controller, verifies that site exists and that DNS
+// records can be read. With writeProbe it also creates and deletes a probe record.
+func CheckController(ctx context.Context, report *Report, client unifi.NetworkAPIClient, site string, writeProbe bool) {
+	sites, err := listSites(ctx, client)
+	if err != nil {
+		report.Add("controller.connect", StatusFail, "%v", err)
+		report.Add("controller.site", StatusSkip, "controller not reachable")
+		report.Add("controller.dns.read", StatusSkip, "controller not reachable")
+		report.Add("controller.dns.write", StatusSkip, "controller not reachable")
+
+		return
+	}
+
+	report.Add("controller.connect", StatusPass, "authenticated, %d sites visible", len(sites))
+
+	found := slices.ContainsFunc(sites, func(item unifi.SiteListItem) bool {
+		return item.InternalReference == site || strings.EqualFold(item.Name, site)
+	})
+	if !found {
+		available := make([]string, 0, len(sites))
+		for _, item := range sites {
+			available = append(available, item.InternalReference)
+		}
+
+		report.Add("controller.site", StatusFail, "site %q not found; available: %s", site, strings.Join(available, ", "))
+		report.Add("controller.dns.read", StatusSkip, "site not found")
+		report.Add("controller.dns.write", StatusSkip, "site not found")
+
+		return
+	}
+
+	report.Add("controller.site", StatusPass, "site %q exists", site)
+
+	records, err := client.ListDNSRecords(ctx, unifi.Site(site))
+	if err != nil {
+		report.Add("controller.dns.read", StatusFail, "%v", err)
+		report.Add("controller.dns.write", StatusSkip, "DNS records cannot be read")
+
+		return
+	}
+
+	report.Add("controller.dns.read", StatusPass, "%d DNS records", len(records))
+
+	if !writeProbe {
+		report.Add("controller.dns.write", StatusSkip, "write probe disabled")
+
+		return
+	}
+
+	probeWrite(ctx, report, client, site)
+}
+
+// listSites returns all sites visible to the client.
+func listSites(ctx context.Context, client unifi.NetworkAPIClient) ([]unifi.SiteListItem, error) {
+	var sites []unifi.SiteListItem
+
+	for offset := 0; ; offset += sitesPageSize {
+		limit := sitesPageSize
+
+		page, err := client.ListSites(ctx, &unifi.ListSitesParams{Offset: &offset, Limit: &limit})
+		if err != nil {
+			return nil, err
+		}
+
+		sites = append(sites, page.Data...)
+
+		if len(page.Data) < sitesPageSize || len(sites) >= page.TotalCount {
+			return sites, nil
+		}
+	}
+}
+
+// probeWrite creates a record with a unique name under the reserved .invalid TLD
+// and deletes it again, proving write and delete permission without touching real names.
+func probeWrite(ctx context.Context, report *Report, client unifi.NetworkAPIClient, site string) {
+	suffix := make([]byte, 4)
+	_, _ = rand.Read(suffix)
+
+	name := "external-dns-unifi-check-" + hex.EncodeToString(suffix) + ".invalid"
+
+	record, err := client.CreateDNSRecord(ctx, unifi.Site(site), &unifi.DNSRecordInput{
+		Key:        name,
+		RecordType: unifi.DNSRecordInputRecordTypeA,
+		Value:      probeTarget,
+	})
+	if err != nil {
+		report.Add("controller.dns.write", StatusFail, "cannot create DNS records: %v", err)
+
+		return
+	}
+
+	err = client.DeleteDNSRecord(ctx, unifi.Site(site), unifi.RecordId(record.UnderscoreId))
+	if err != nil {
+		report.Add("controller.dns.write", StatusFail,
+			"created probe record %s but cannot delete it, remove record %s manually: %v", name, record.UnderscoreId, err)
+
+		return
+	}
+
+	report.Add("controller.dns.write", StatusPass, "created and deleted probe record %s", name)
+}
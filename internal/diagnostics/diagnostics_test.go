@@ -0,0 +1,136 @@
+package diagnostics_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/config"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/diagnostics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/unificlient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeController serves the endpoints used by the checks and records deleted record IDs.
+type fakeController struct {
+	deleted []string
+}
+
+func (c *fakeController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+
+	switch {
+	case r.URL.Path == "/proxy/network/integration/v1/sites":
+		_, _ = w.Write([]byte(`{"count":1,"totalCount":1,"data":[{"internalReference":"default","name":"Default"}]}`))
+	case r.URL.Path == "/proxy/network/v2/api/site/default/static-dns" && r.Method == http.MethodGet:
+		_, _ = w.Write([]byte(`[]`))
+	case r.URL.Path == "/proxy/network/v2/api/site/default/static-dns" && r.Method == http.MethodPost:
+		_, _ = w.Write([]byte(`{"_id":"probe-1","key":"probe.invalid","value":"192.0.2.1","record_type":"A","enabled":true}`))
+	case strings.HasPrefix(r.URL.Path, "/proxy/network/v2/api/site/default/static-dns/") && r.Method == http.MethodDelete:
+		c.deleted = append(c.deleted, strings.TrimPrefix(r.URL.Path, "/proxy/network/v2/api/site/default/static-dns/"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newClient(t *testing.T, controller http.Handler) *unificlient.Client {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(controller)
+	t.Cleanup(srv.Close)
+
+	tlsConfig, err := unificlient.NewTLSConfig(unificlient.TrustConfig{SkipVerify: true})
+	require.NoError(t, err)
+
+	client, err := unificlient.New(&unificlient.Config{ControllerURL: srv.URL, APIKey: "key", TLSConfig: tlsConfig})
+	require.NoError(t, err)
+
+	return client
+}
+
+func statuses(report *diagnostics.Report) map[string]diagnostics.Status {
+	result := make(map[string]diagnostics.Status, len(report.Checks))
+	for _, check := range report.Checks {
+		result[check.Name] = check.Status
+	}
+
+	return result
+}
+
+func TestCheckController(t *testing.T) {
+	t.Parallel()
+
+	controller := &fakeController{}
+	client := newClient(t, controller)
+
+	report := &diagnostics.Report{}
+	diagnostics.CheckController(context.Background(), report, client, "default", true)
+
+	assert.False(t, report.Failed())
+	assert.Equal(t, diagnostics.StatusPass, statuses(report)["controller.dns.write"])
+	assert.Equal(t, []string{"probe-1"}, controller.deleted, "probe record must be removed")
+
+	report = &diagnostics.Report{}
+	diagnostics.CheckController(context.Background(), report, client, "branch-office", true)
+
+	assert.True(t, report.Failed())
+	assert.Equal(t, diagnostics.StatusFail, statuses(report)["controller.site"])
+	assert.Equal(t, diagnostics.StatusSkip, statuses(report)["controller.dns.write"])
+}
+
+func TestCheckConfig(t *testing.T) {
+	t.Parallel()
+
+	cfg := &config.Config{
+		UniFi:        config.UniFiConfig{Host: "https://192.168.1.1", APIKey: "key", SkipTLSVerify: true},
+		Server:       config.ServerConfig{Host: "localhost", Port: "8888"},
+		Health:       config.HealthConfig{Host: "0.0.0.0", Port: "8080"},
+		DomainFilter: config.DomainFilterConfig{Filters: []string{"example.com"}},
+		Freeze:       config.FreezeConfig{Mode: "reject"},
+	}
+
+	report := diagnostics.CheckConfig(cfg)
+	assert.False(t, report.Failed())
+	assert.Equal(t, diagnostics.StatusWarn, statuses(report)["config.unifi.tls"])
+
+	cfg.Health.Port = "8888"
+	cfg.DomainFilter.RegexFilters = []string{"("}
+	cfg.Freeze.Windows = []string{"Someday 25:00-26:00"}
+
+	report = diagnostics.CheckConfig(cfg)
+	assert.True(t, report.Failed())
+
+	results := statuses(report)
+	assert.Equal(t, diagnostics.StatusFail, results["config.health.address"])
+	assert.Equal(t, diagnostics.StatusFail, results["config.domain_filter"])
+	assert.Equal(t, diagnostics.StatusFail, results["config.freeze"])
+}
+
+func TestReport_Output(t *testing.T) {
+	t.Parallel()
+
+	report := &diagnostics.Report{}
+	report.Add("config.file", diagnostics.StatusPass, "loaded %s", "config.yaml")
+	report.Add("controller.connect", diagnostics.StatusFail, "connection refused")
+
+	var text bytes.Buffer
+	require.NoError(t, report.WriteText(&text))
+	assert.Contains(t, text.String(), "FAIL  controller.connect  connection refused")
+	assert.Contains(t, text.String(), "1 passed, 0 warnings, 1 failed, 0 skipped")
+
+	var encoded bytes.Buffer
+	require.NoError(t, report.WriteJSON(&encoded))
+
+	var decoded struct {
+		OK     bool                 `json:"ok"`
+		Checks []diagnostics.Result `json:"checks"`
+	}
+	require.NoError(t, json.Unmarshal(encoded.Bytes(), &decoded))
+	assert.False(t, decoded.OK)
+	assert.Len(t, decoded.Checks, 2)
+}
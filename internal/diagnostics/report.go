@@ -0,0 +1,116 @@
+// Package diagnostics checks the configuration and the UniFi controller connection
+// so that onboarding problems are reported up front instead of as failing probes.
+package diagnostics
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Status is the outcome of a single check.
+type Status string
+
+// Check outcomes.
+const (
+	StatusPass Status = "pass"
+	StatusWarn Status = "warn"
+	StatusFail Status = "fail"
+	StatusSkip Status = "skip"
+)
+
+// Result is the outcome of a single check.
+type Result struct {
+	Name    string `json:"name"`
+	Status  Status `json:"status"`
+	Message string `json:"message"`
+}
+
+// Report collects check results in the order they ran.
+type Report struct {
+	Checks []Result `json:"checks"`
+}
+
+// Add records the outcome of a check.
+func (r *Report) Add(name string, status Status, format string, args ...any) {
+	r.Checks = append(r.Checks, Result{Name: name, Status: status, Message: fmt.Sprintf(format, args...)})
+}
+
+// Failed reports whether any check failed. Warnings do not fail the report.
+func (r *Report) Failed() bool {
+	for _, result := range r.Checks {
+		if result.Status == StatusFail {
+			return true
+		}
+	}
+
+	return false
+}
+
+// count returns how many checks have the given status.
+func (r *Report) count(status Status) int {
+	total := 0
+
+	for _, result := range r.Checks {
+		if result.Status == status {
+			total++
+		}
+	}
+
+	return total
+}
+
+// WriteText writes a human-readable report.
+func (r *Report) WriteText(w io.Writer) error {
+	table := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	for _, result := range r.Checks {
+		_, _ = fmt.Fprintf(table, "%s\t%s\t%s\n", statusLabel(result.Status), result.Name, result.Message)
+	}
+
+	err := table.Flush()
+	if err != nil {
+		return errors.Wrap(err, "failed to write report")
+	}
+
+	_, err = fmt.Fprintf(w, "\n%d passed, %d warnings, %d failed, %d skipped\n",
+		r.count(StatusPass), r.count(StatusWarn), r.count(StatusFail), r.count(StatusSkip))
+	if err != nil {
+		return errors.Wrap(err, "failed to write report")
+	}
+
+	return nil
+}
+
+// WriteJSON writes the report as JSON with an overall ok flag.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+
+	err := encoder.Encode(struct {
+		OK     bool     `json:"ok"`
+		Checks []Result `json:"checks"`
+	}{OK: !r.Failed(), Checks: r.Checks})
+	if err != nil {
+		return errors.Wrap(err, "failed to write report")
+	}
+
+	return nil
+}
+
+// statusLabel returns the fixed-width label shown in text reports.
+func statusLabel(status Status) string {
+	switch status {
+	case StatusPass:
+		return "PASS"
+	case StatusWarn:
+		return "WARN"
+	case StatusFail:
+		return "FAIL"
+	default:
+		return "SKIP"
+	}
+}
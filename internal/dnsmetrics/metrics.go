@@ -53,6 +53,275 @@ var (
 		[]string{"change_type"}, // change_type: create/update/delete
 	)
 
+	// ProtectedRecordsBlocked tracks changes refused because they touch protected records.
+	ProtectedRecordsBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "protected_records_blocked_total",
+			Help:      "Total number of changes blocked because they touch protected records",
+		},
+		[]string{labelOperation}, // operation: create/update/delete
+	)
+
+	// TargetPolicyRejections tracks creates and updates rejected because their targets violate the target policy.
+	TargetPolicyRejections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "target_policy_rejections_total",
+			Help:      "Total number of changes rejected because their targets violate the target policy",
+		},
+		[]string{labelOperation, "reason"}, // operation: create/update, reason: address class, denied or not-allowed
+	)
+
+	// TargetPolicyViolations reports the existing records whose targets violate the target policy.
+	TargetPolicyViolations = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "target_policy_violating_records",
+			Help:      "Number of records whose targets violate the target policy, as of the last read",
+		},
+		[]string{"reason"},
+	)
+
+	// FreezeActive reports whether DNS changes are currently frozen (1) or not (0).
+	FreezeActive = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "freeze_active",
+			Help:      "Whether DNS changes are currently frozen (1) or not (0)",
+		},
+	)
+
+	// FreezePendingChanges reports whether a queued change set is waiting for the freeze to end.
+	FreezePendingChanges = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "freeze_pending_changes",
+			Help:      "Whether a queued change set is waiting for the freeze to end (1) or not (0)",
+		},
+	)
+
+	// FreezeDeferredRequests tracks change requests received while frozen.
+	FreezeDeferredRequests = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "freeze_deferred_requests_total",
+			Help:      "Total number of change requests received while DNS changes were frozen",
+		},
+		[]string{"action"}, // action: queued/rejected
+	)
+
+	// AuthFailures tracks webhook requests rejected with 401 Unauthorized.
+	AuthFailures = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "webhook_auth_failures_total",
+			Help:      "Total number of webhook requests rejected as unauthenticated",
+		},
+		[]string{"reason"}, // reason: missing_token/invalid_token/missing_signature/invalid_signature/expired_timestamp
+	)
+
+	// UniFiTLSVerificationDisabled reports whether the UniFi controller certificate is not verified.
+	UniFiTLSVerificationDisabled = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "unifi_tls_verification_disabled",
+			Help:      "Whether TLS certificate verification of the UniFi controller is disabled (1) or not (0)",
+		},
+	)
+
+	// UniFiCredentialsLastReload records when the UniFi credentials were last loaded successfully.
+	UniFiCredentialsLastReload = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "unifi_credentials_last_reload_timestamp_seconds",
+			Help:      "Unix timestamp of the last successful UniFi credential load",
+		},
+	)
+
+	// UniFiCredentialReloadErrors tracks rotated credentials that could not be applied.
+	UniFiCredentialReloadErrors = prometheus.NewCounter(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "unifi_credential_reload_errors_total",
+			Help:      "Total number of failed UniFi credential reloads",
+		},
+	)
+
+	// ConfigReloads tracks configuration file reloads.
+	ConfigReloads = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "config_reloads_total",
+			Help:      "Total number of configuration file reloads",
+		},
+		[]string{"result"}, // result: success/error
+	)
+
+	// ConfigRestartRequired reports how many changed settings only take effect after a restart.
+	ConfigRestartRequired = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "config_restart_required_fields",
+			Help:      "Number of changed configuration settings waiting for a restart",
+		},
+	)
+
+	// BackupSnapshots tracks DNS record snapshots by reason and result.
+	BackupSnapshots = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "backup_snapshots_total",
+			Help:      "Total number of DNS record snapshots attempted",
+		},
+		[]string{"reason", "result"}, // reason: startup/scheduled/apply/manual/restore, result: written/unchanged/error
+	)
+
+	// BackupLastSnapshot reports when the last snapshot was written.
+	BackupLastSnapshot = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "backup_last_snapshot_timestamp_seconds",
+			Help:      "Unix time of the last DNS record snapshot written",
+		},
+	)
+
+	// BackupRestores tracks restores from snapshots by result.
+	BackupRestores = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "backup_restores_total",
+			Help:      "Total number of restores from DNS record snapshots",
+		},
+		[]string{"result"}, // result: success/error
+	)
+
+	// DriftRecords reports managed records that differ from the desired state.
+	DriftRecords = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "drift_records",
+			Help:      "Number of managed DNS records that drifted from the desired state",
+		},
+		[]string{"kind", "record_type"}, // kind: missing/modified/extra
+	)
+
+	// DriftLastCheck reports when drift was last checked.
+	DriftLastCheck = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "drift_last_check_timestamp_seconds",
+			Help:      "Unix time of the last completed drift check",
+		},
+	)
+
+	// DriftCorrections tracks automatic drift corrections by result.
+	DriftCorrections = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "drift_corrections_total",
+			Help:      "Total number of automatic drift corrections",
+		},
+		[]string{"result"}, // result: success/error
+	)
+
+	// AutoRecords reports the records published by built-in sources.
+	AutoRecords = prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "auto_records",
+			Help:      "Number of DNS records published by built-in sources",
+		},
+		[]string{"source"}, // source: clients/devices
+	)
+
+	// AutoRecordSyncs tracks reconciliations of records published by built-in sources.
+	AutoRecordSyncs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "auto_record_syncs_total",
+			Help:      "Total number of reconciliations of records published by built-in sources",
+		},
+		[]string{"result"}, // result: success/error/frozen
+	)
+
+	// StaticRecords reports the records declared in the static records file.
+	StaticRecords = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "static_records",
+			Help:      "Number of DNS records declared in the static records file",
+		},
+	)
+
+	// StaticRecordSyncs tracks reconciliations of the static records file.
+	StaticRecordSyncs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "static_record_syncs_total",
+			Help:      "Total number of reconciliations of the static records file",
+		},
+		[]string{"result"}, // result: success/error/frozen
+	)
+
+	// StaticRecordConflicts reports external-dns endpoints that conflict with static records.
+	StaticRecordConflicts = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "static_record_conflicts",
+			Help:      "Number of external-dns endpoints that conflict with the static records file",
+		},
+	)
+
+	// StaticRecordsBlocked tracks external-dns changes refused because they touch static records.
+	StaticRecordsBlocked = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "static_records_blocked_total",
+			Help:      "Total number of external-dns changes blocked because they touch static records",
+		},
+		[]string{labelOperation},
+	)
+
+	// ReconcileRuns tracks runs of the standalone reconcile loop.
+	ReconcileRuns = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "reconcile_runs_total",
+			Help:      "Total number of runs of the standalone reconcile loop",
+		},
+		[]string{"result"}, // result: success/error/frozen
+	)
+
+	// ReconcileDesiredRecords reports the desired records read by the reconcile loop.
+	ReconcileDesiredRecords = prometheus.NewGauge(
+		prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "reconcile_desired_records",
+			Help:      "Number of desired record sets read by the reconcile loop",
+		},
+	)
+
+	// VerifiedRecords tracks changed records looked up after applying, by whether the resolver served them.
+	VerifiedRecords = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "verified_records_total",
+			Help:      "Total number of changed records looked up after applying, by result",
+		},
+		[]string{"result"}, // result: success/failure
+	)
+
+	// PTRSyncs tracks updates of reverse records after applying changes, by outcome.
+	PTRSyncs = prometheus.NewCounterVec(
+		prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "ptr_syncs_total",
+			Help:      "Total number of reverse record updates after applying changes, by result",
+		},
+		[]string{"result"}, // result: success/error
+	)
+
 	// ReadinessCacheHits tracks the number of readiness cache hits.
 	ReadinessCacheHits = prometheus.NewCounter(
 		prometheus.CounterOpts{
@@ -88,6 +357,34 @@ func Register(registry *prometheus.Registry) {
 		DNSOperationDuration,
 		DNSRecordsManaged,
 		DNSChangesApplied,
+		ProtectedRecordsBlocked,
+		TargetPolicyRejections,
+		TargetPolicyViolations,
+		FreezeActive,
+		FreezePendingChanges,
+		FreezeDeferredRequests,
+		AuthFailures,
+		UniFiTLSVerificationDisabled,
+		UniFiCredentialsLastReload,
+		UniFiCredentialReloadErrors,
+		ConfigReloads,
+		ConfigRestartRequired,
+		BackupSnapshots,
+		BackupLastSnapshot,
+		BackupRestores,
+		DriftRecords,
+		DriftLastCheck,
+		DriftCorrections,
+		AutoRecords,
+		AutoRecordSyncs,
+		StaticRecords,
+		StaticRecordSyncs,
+		StaticRecordConflicts,
+		StaticRecordsBlocked,
+		ReconcileRuns,
+		ReconcileDesiredRecords,
+		VerifiedRecords,
+		PTRSyncs,
 		ReadinessCacheHits,
 		ReadinessCacheMisses,
 		ReadinessCacheAge,
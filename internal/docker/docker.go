@@ -0,0 +1,300 @@
+// Package docker publishes DNS records for Docker containers from their labels.
+//
+// It talks to the Docker Engine API over its unix socket and lists running
+// containers labeled with unifi-dns.hostname. The records are published by an
+// autorecords.Syncer, which deletes them once their container stops.
+package docker
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/autorecords"
+)
+
+// Container labels read by the source.
+const (
+	// LabelHostname lists the names of a container, comma-separated. Each is a
+	// single label or a name below the domain of the source.
+	LabelHostname = "unifi-dns.hostname"
+	// LabelTarget is the address the names point to.
+	LabelTarget = "unifi-dns.target"
+)
+
+// DefaultSocket is the usual path of the Docker Engine socket.
+const DefaultSocket = "/var/run/docker.sock"
+
+const (
+	// requestTimeout bounds a container listing.
+	requestTimeout = 30 * time.Second
+	// retryDelay is the wait before watching events again after the stream broke.
+	retryDelay = 5 * time.Second
+)
+
+// container is the part of a Docker Engine container listing the source uses.
+type container struct {
+	ID              string            `json:"Id"`
+	Names           []string          `json:"Names"`
+	Labels          map[string]string `json:"Labels"`
+	Created         int64             `json:"Created"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress         string `json:"IPAddress"`
+			GlobalIPv6Address string `json:"GlobalIPv6Address"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// Source lists the running containers labeled with a hostname.
+type Source struct {
+	client *http.Client
+	domain string
+	target netip.Addr
+}
+
+// Option configures optional Source behavior.
+type Option func(*Source)
+
+// WithTarget sets the address of containers without a target label, usually the
+// address of the Docker host. By default their address on their first network is used.
+func WithTarget(target netip.Addr) Option {
+	return func(s *Source) {
+		s.target = target
+	}
+}
+
+// New creates a source for the Docker Engine listening on socket, publishing names below domain.
+func New(socket, domain string, opts ...Option) *Source {
+	transport := &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+
+			return dialer.DialContext(ctx, "unix", socket)
+		},
+	}
+
+	source := &Source{
+		client: &http.Client{Transport: transport},
+		domain: strings.ToLower(strings.TrimSuffix(domain, ".")),
+	}
+
+	for _, opt := range opts {
+		opt(source)
+	}
+
+	return source
+}
+
+// Name implements autorecords.Source.
+func (s *Source) Name() string {
+	return "docker"
+}
+
+// Hosts implements autorecords.Source. The most recently created container wins a name.
+func (s *Source) Hosts(ctx context.Context) ([]autorecords.Host, error) {
+	ctx, cancel := context.WithTimeout(ctx, requestTimeout)
+	defer cancel()
+
+	filters, err := json.Marshal(map[string][]string{"label": {LabelHostname}})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to encode container filters")
+	}
+
+	response, err := s.get(ctx, "/containers/json?filters="+url.QueryEscape(string(filters)))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list containers")
+	}
+	defer response.Body.Close()
+
+	var containers []container
+
+	err = json.NewDecoder(response.Body).Decode(&containers)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode containers")
+	}
+
+	slices.SortStableFunc(containers, func(left, right container) int {
+		return cmp.Compare(right.Created, left.Created)
+	})
+
+	var hosts []autorecords.Host
+
+	for _, item := range containers {
+		hosts = append(hosts, s.hosts(ctx, item)...)
+	}
+
+	return hosts, nil
+}
+
+// hosts returns the hosts for the names of one container.
+func (s *Source) hosts(ctx context.Context, item container) []autorecords.Host {
+	address, ok := s.address(item)
+	if !ok {
+		slog.WarnContext(ctx, "skipping container without a usable address",
+			"container", containerName(item), LabelTarget, item.Labels[LabelTarget])
+
+		return nil
+	}
+
+	var hosts []autorecords.Host
+
+	for name := range strings.SplitSeq(item.Labels[LabelHostname], ",") {
+		label, ok := s.label(name)
+		if !ok {
+			slog.WarnContext(ctx, "skipping container hostname outside the domain",
+				"container", containerName(item), "hostname", name, "domain", s.domain)
+
+			continue
+		}
+
+		hosts = append(hosts, autorecords.Host{Name: label, Address: address})
+	}
+
+	return hosts
+}
+
+// address returns the target label, the configured target or the container's
+// address on the first of its networks by name, in that order.
+func (s *Source) address(item container) (netip.Addr, bool) {
+	if target, ok := item.Labels[LabelTarget]; ok {
+		address, err := netip.ParseAddr(strings.TrimSpace(target))
+
+		return address, err == nil
+	}
+
+	if s.target.IsValid() {
+		return s.target, true
+	}
+
+	networks := item.NetworkSettings.Networks
+
+	for _, network := range slices.Sorted(maps.Keys(networks)) {
+		for _, candidate := range []string{networks[network].IPAddress, networks[network].GlobalIPv6Address} {
+			address, err := netip.ParseAddr(candidate)
+			if err == nil {
+				return address, true
+			}
+		}
+	}
+
+	return netip.Addr{}, false
+}
+
+// label turns a hostname from a container label into a DNS label below the
+// domain. Names ending in the domain are cut down to their first label.
+func (s *Source) label(name string) (string, bool) {
+	name = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(name), "."))
+	name = strings.TrimSuffix(name, "."+s.domain)
+
+	if strings.Contains(name, ".") {
+		return "", false
+	}
+
+	label := autorecords.Label(name)
+
+	return label, label != ""
+}
+
+// Watch calls changed whenever a container starts or stops, until ctx is done.
+// A broken event stream is opened again after a short delay.
+func (s *Source) Watch(ctx context.Context, changed func()) {
+	for {
+		err := s.watch(ctx, changed)
+		if ctx.Err() != nil {
+			return
+		}
+
+		slog.WarnContext(ctx, "Docker event stream ended, watching again", "error", err, "delay", retryDelay)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryDelay):
+		}
+	}
+}
+
+func (s *Source) watch(ctx context.Context, changed func()) error {
+	filters, err := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "die"},
+		"label": {LabelHostname},
+	})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode event filters")
+	}
+
+	response, err := s.get(ctx, "/events?filters="+url.QueryEscape(string(filters)))
+	if err != nil {
+		return errors.Wrap(err, "failed to watch events")
+	}
+	defer response.Body.Close()
+
+	decoder := json.NewDecoder(response.Body)
+
+	for {
+		var event struct {
+			Action string `json:"Action"`
+			Actor  struct {
+				Attributes map[string]string `json:"Attributes"`
+			} `json:"Actor"`
+		}
+
+		err := decoder.Decode(&event)
+		if err != nil {
+			return errors.Wrap(err, "failed to decode event")
+		}
+
+		// Events carry the container labels as attributes
+		if _, ok := event.Actor.Attributes[LabelHostname]; ok {
+			slog.DebugContext(ctx, "labeled container changed", "action", event.Action,
+				"container", event.Actor.Attributes["name"])
+
+			changed()
+		}
+	}
+}
+
+// get sends a GET request to the Docker Engine API and checks the status.
+func (s *Source) get(ctx context.Context, path string) (*http.Response, error) {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker"+path, http.NoBody)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create request")
+	}
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to reach the Docker Engine")
+	}
+
+	if response.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(response.Body, 1<<10))
+		response.Body.Close()
+
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return nil, errors.Newf("Docker Engine returned %s: %s", response.Status, strings.TrimSpace(string(body)))
+	}
+
+	return response, nil
+}
+
+// containerName returns the name of a container without the leading slash, or its ID.
+func containerName(item container) string {
+	if len(item.Names) > 0 {
+		return strings.TrimPrefix(item.Names[0], "/")
+	}
+
+	return item.ID
+}
@@ -0,0 +1,221 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package docker
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/autorecords"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeEngine stands in for the Docker Engine API on a unix socket.
+type fakeEngine struct {
+	mu         sync.Mutex
+	containers []map[string]any
+	events     chan map[string]any
+}
+
+func (f *fakeEngine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/containers/json":
+		var filters map[string][]string
+		if json.Unmarshal([]byte(r.URL.Query().Get("filters")), &filters) != nil || !slices.Equal(filters["label"], []string{LabelHostname}) {
+			http.Error(w, "missing label filter", http.StatusBadRequest)
+
+			return
+		}
+
+		f.mu.Lock()
+		defer f.mu.Unlock()
+
+		_ = json.NewEncoder(w).Encode(f.containers)
+	case "/events":
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+
+		for {
+			select {
+			case <-r.Context().Done():
+				return
+			case event := <-f.events:
+				_ = json.NewEncoder(w).Encode(event)
+				w.(http.Flusher).Flush()
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// startEngine serves engine on a unix socket and returns the socket path.
+func startEngine(t *testing.T, engine *fakeEngine) string {
+	t.Helper()
+
+	// Socket paths are limited to about 100 bytes, too short for some test temp dirs
+	dir, err := os.MkdirTemp("", "docker")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = os.RemoveAll(dir) })
+
+	socket := filepath.Join(dir, "docker.sock")
+
+	listener, err := net.Listen("unix", socket)
+	require.NoError(t, err)
+
+	server := httptest.NewUnstartedServer(engine)
+	server.Listener = listener
+	server.Start()
+	t.Cleanup(server.Close)
+
+	return socket
+}
+
+// labeled returns a running container listing with labels and a bridge address.
+func labeled(name string, created int64, address string, labels map[string]string) map[string]any {
+	return map[string]any{
+		"Id":      name + "-id",
+		"Names":   []string{"/" + name},
+		"Created": created,
+		"Labels":  labels,
+		"NetworkSettings": map[string]any{
+			"Networks": map[string]any{"bridge": map[string]any{"IPAddress": address}},
+		},
+	}
+}
+
+// fakeProvider keeps endpoints in memory.
+type fakeProvider struct {
+	mu        sync.Mutex
+	endpoints []*endpoint.Endpoint
+}
+
+func (f *fakeProvider) RecordsIn(_ context.Context, domainFilter endpoint.DomainFilter) ([]*endpoint.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*endpoint.Endpoint
+
+	for _, item := range f.endpoints {
+		if domainFilter.Match(item.DNSName) {
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
+
+func (f *fakeProvider) ApplyChanges(_ context.Context, changes *plan.Changes) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, removed := range slices.Concat(changes.Delete, changes.UpdateOld) {
+		f.endpoints = slices.DeleteFunc(f.endpoints, func(item *endpoint.Endpoint) bool {
+			return item.DNSName == removed.DNSName
+		})
+	}
+
+	f.endpoints = append(f.endpoints, slices.Concat(changes.Create, changes.UpdateNew)...)
+
+	return nil
+}
+
+// names returns the name and targets of every endpoint, sorted.
+func (f *fakeProvider) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := make([]string, 0, len(f.endpoints))
+	for _, item := range f.endpoints {
+		result = append(result, item.DNSName+" "+item.Targets.String())
+	}
+
+	slices.Sort(result)
+
+	return result
+}
+
+func TestHosts(t *testing.T) {
+	t.Parallel()
+
+	engine := &fakeEngine{containers: []map[string]any{
+		labeled("grafana", 100, "172.17.0.2", map[string]string{LabelHostname: "grafana, dashboards.docker.home.lan"}),
+		labeled("grafana-old", 50, "172.17.0.9", map[string]string{LabelHostname: "grafana"}),
+		labeled("nas", 100, "172.17.0.3", map[string]string{LabelHostname: "Files", LabelTarget: "192.168.1.10"}),
+		labeled("broken", 100, "172.17.0.4", map[string]string{LabelHostname: "broken", LabelTarget: "nas.home.lan"}),
+		labeled("elsewhere", 100, "172.17.0.5", map[string]string{LabelHostname: "web.other.lan"}),
+	}}
+
+	socket := startEngine(t, engine)
+	source := New(socket, "docker.home.lan.")
+
+	hosts, err := source.Hosts(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, []autorecords.Host{
+		{Name: "grafana", Address: netip.MustParseAddr("172.17.0.2")},
+		{Name: "dashboards", Address: netip.MustParseAddr("172.17.0.2")},
+		{Name: "files", Address: netip.MustParseAddr("192.168.1.10")},
+		{Name: "grafana", Address: netip.MustParseAddr("172.17.0.9")},
+	}, hosts, "newest containers first, unusable targets and other domains skipped")
+
+	// The configured target replaces network addresses, not target labels
+	source = New(socket, "docker.home.lan", WithTarget(netip.MustParseAddr("192.168.1.5")))
+
+	hosts, err = source.Hosts(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, netip.MustParseAddr("192.168.1.5"), hosts[0].Address)
+	assert.Equal(t, netip.MustParseAddr("192.168.1.10"), hosts[2].Address)
+}
+
+func TestWatch_SyncsOnContainerEvents(t *testing.T) {
+	t.Parallel()
+
+	engine := &fakeEngine{
+		containers: []map[string]any{
+			labeled("grafana", 100, "172.17.0.2", map[string]string{LabelHostname: "grafana"}),
+		},
+		events: make(chan map[string]any),
+	}
+
+	source := New(startEngine(t, engine), "docker.home.lan")
+	prov := &fakeProvider{}
+	syncer := autorecords.New(prov, "docker.home.lan", []autorecords.Source{source})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go source.Watch(ctx, syncer.Trigger)
+	go syncer.Run(ctx, time.Hour)
+
+	require.Eventually(t, func() bool {
+		return slices.Equal(prov.names(), []string{"grafana.docker.home.lan 172.17.0.2"})
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// The container stops; its record goes away without waiting for the interval
+	engine.mu.Lock()
+	engine.containers = nil
+	engine.mu.Unlock()
+
+	engine.events <- map[string]any{
+		"Type":   "container",
+		"Action": "die",
+		"Actor":  map[string]any{"Attributes": map[string]string{"name": "grafana", LabelHostname: "grafana"}},
+	}
+
+	require.Eventually(t, func() bool {
+		return len(prov.names()) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,491 @@
+// Package drift detects changes made to managed DNS records outside the webhook.
+package drift
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Drift kinds used in reports and metrics.
+const (
+	KindMissing  = "missing"
+	KindModified = "modified"
+	KindExtra    = "extra"
+)
+
+// ErrApplying is returned by Check when changes were applied while it read the
+// records, so the comparison would not be reliable.
+var ErrApplying = errors.New("changes were applied during the drift check")
+
+// Modified is a record whose targets or TTL differ from the desired state.
+type Modified struct {
+	Desired records.Record `json:"desired"`
+	Current records.Record `json:"current"`
+}
+
+// Report is the outcome of a drift check.
+type Report struct {
+	CheckedAt time.Time        `json:"checked_at"`
+	InSync    bool             `json:"in_sync"`
+	Missing   []records.Record `json:"missing"`
+	Modified  []Modified       `json:"modified"`
+	Extra     []records.Record `json:"extra"`
+	Corrected bool             `json:"corrected"`
+}
+
+// Detector remembers the desired state of managed records and compares it with UniFi.
+//
+// The desired state is made of the change sets the provider applied successfully:
+// created and updated records are desired, deleted ones must not come back.
+// Records the webhook never wrote are not part of it, so drift that happened
+// before they were applied cannot be mistaken for the desired state. The state
+// is kept in a state file, if configured, to survive restarts.
+type Detector struct {
+	provider    provider.DNSProvider
+	autoCorrect bool
+	frozen      func() bool
+	scope       func(name string) bool
+	now         func() time.Time
+	state       string
+
+	mu         sync.Mutex
+	desired    map[string]records.Record
+	deleted    map[string]records.Record
+	dirty      bool
+	applying   int
+	generation uint64
+	report     *Report
+}
+
+// desiredState is the desired state as written to the state file.
+type desiredState struct {
+	Desired []records.Record `json:"desired"`
+	Deleted []records.Record `json:"deleted"`
+}
+
+// Option configures optional Detector behavior.
+type Option func(*Detector)
+
+// WithAutoCorrect makes Run restore missing and modified records.
+func WithAutoCorrect(enabled bool) Option {
+	return func(d *Detector) {
+		d.autoCorrect = enabled
+	}
+}
+
+// WithFreeze suspends corrections while frozen reports true.
+func WithFreeze(frozen func() bool) Option {
+	return func(d *Detector) {
+		d.frozen = frozen
+	}
+}
+
+// WithScope limits the desired state to names for which scope reports true, such as the
+// names in the provider's domain filter. Changes to other names are not followed, since
+// Records never returns them.
+func WithScope(scope func(name string) bool) Option {
+	return func(d *Detector) {
+		d.scope = scope
+	}
+}
+
+// WithState keeps the desired state in the file at path, so it survives restarts.
+func WithState(path string) Option {
+	return func(d *Detector) {
+		d.state = path
+	}
+}
+
+// New creates a detector reading records through prov.
+func New(prov provider.DNSProvider, opts ...Option) (*Detector, error) {
+	detector := &Detector{
+		provider: prov,
+		frozen:   func() bool { return false },
+		scope:    func(string) bool { return true },
+		now:      time.Now,
+		desired:  make(map[string]records.Record),
+		deleted:  make(map[string]records.Record),
+	}
+
+	for _, opt := range opts {
+		opt(detector)
+	}
+
+	if detector.state != "" {
+		err := detector.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return detector, nil
+}
+
+// BeforeApply marks changes as in progress; install it with provider.WithBeforeApply.
+func (d *Detector) BeforeApply(context.Context) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.applying++
+}
+
+// AfterApply adds applied changes to the desired state; install it with provider.WithAfterApply.
+func (d *Detector) AfterApply(ctx context.Context, changes *plan.Changes, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.applying--
+	d.generation++
+
+	removed := records.FromEndpoints(slices.Concat(changes.Delete, changes.UpdateOld))
+	added := records.FromEndpoints(slices.Concat(changes.Create, changes.UpdateNew))
+
+	// What a failed change set left behind is unknown until external-dns applies it again
+	if err != nil {
+		for _, record := range slices.Concat(removed, added) {
+			delete(d.desired, key(record))
+			delete(d.deleted, key(record))
+		}
+
+		d.dirty = true
+		d.saveLocked(ctx)
+
+		return
+	}
+
+	for _, record := range removed {
+		delete(d.desired, key(record))
+
+		if d.scope(record.Name) {
+			d.deleted[key(record)] = record
+		}
+	}
+
+	for _, record := range added {
+		if d.scope(record.Name) {
+			d.desired[key(record)] = record
+			delete(d.deleted, key(record))
+		}
+	}
+
+	d.dirty = true
+	d.saveLocked(ctx)
+}
+
+// Check compares the records in UniFi with the desired state and updates the drift metrics.
+func (d *Detector) Check(ctx context.Context) (*Report, error) {
+	report, _, err := d.check(ctx)
+
+	return report, err
+}
+
+// check implements Check and also returns the desired records for corrections.
+func (d *Detector) check(ctx context.Context) (*Report, []records.Record, error) {
+	d.mu.Lock()
+	generation, applying := d.generation, d.applying
+	d.mu.Unlock()
+
+	if applying > 0 {
+		return nil, nil, ErrApplying
+	}
+
+	endpoints, err := d.provider.Records(ctx)
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed to read records")
+	}
+
+	current := records.FromEndpoints(endpoints)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.generation != generation || d.applying > 0 {
+		return nil, nil, ErrApplying
+	}
+
+	report := compare(d.desired, d.deleted, current)
+	report.CheckedAt = d.now()
+	d.report = report
+
+	updateMetrics(report)
+
+	desired := make([]records.Record, 0, len(d.desired))
+	for _, record := range d.desired {
+		desired = append(desired, record)
+	}
+
+	return report, desired, nil
+}
+
+// Run checks for drift every interval until ctx is done, correcting it when enabled.
+func (d *Detector) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		d.runOnce(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// runOnce performs one check and, if enabled, one correction.
+func (d *Detector) runOnce(ctx context.Context) {
+	report, desired, err := d.check(ctx)
+
+	switch {
+	case errors.Is(err, ErrApplying):
+		slog.DebugContext(ctx, "skipping drift check while changes are applied")
+
+		return
+	case err != nil:
+		slog.WarnContext(ctx, "drift check failed", "error", err)
+
+		return
+	case report.InSync:
+		return
+	}
+
+	slog.WarnContext(ctx, "managed DNS records drifted from the desired state",
+		KindMissing, len(report.Missing),
+		KindModified, len(report.Modified),
+		KindExtra, len(report.Extra))
+
+	if !d.autoCorrect || len(report.Missing)+len(report.Modified) == 0 {
+		return
+	}
+
+	if d.frozen() {
+		slog.InfoContext(ctx, "not correcting drift while DNS changes are frozen")
+
+		return
+	}
+
+	err = d.correct(ctx, desired)
+	if err != nil {
+		dnsmetrics.DriftCorrections.WithLabelValues("error").Inc()
+		slog.ErrorContext(ctx, "failed to correct drift", "error", err)
+
+		return
+	}
+
+	dnsmetrics.DriftCorrections.WithLabelValues("success").Inc()
+
+	d.mu.Lock()
+	report.Corrected = true
+	d.mu.Unlock()
+}
+
+// correct restores missing and modified records. Extra records are left alone,
+// since they may have been added on purpose.
+func (d *Detector) correct(ctx context.Context, desired []records.Record) error {
+	endpoints, err := d.provider.Records(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read records")
+	}
+
+	changes := records.Diff(records.FromEndpoints(endpoints), desired, false)
+	if changes.Empty() {
+		return nil
+	}
+
+	slog.InfoContext(ctx, "correcting drift", "changes", len(changes.Changes))
+
+	return errors.Wrap(d.provider.ApplyChanges(ctx, changes.ProviderChanges()), "failed to apply corrections")
+}
+
+// ServeHTTP returns the latest drift report, or 503 before the first check.
+func (d *Detector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	d.mu.Lock()
+
+	var report Report
+	if d.report != nil {
+		report = *d.report
+	}
+
+	d.mu.Unlock()
+
+	if report.CheckedAt.IsZero() {
+		http.Error(w, "no drift check has completed yet", http.StatusServiceUnavailable)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// compare reports the differences between the desired and current records. Current
+// records the webhook deleted are extra; those it never wrote are not its concern.
+func compare(desired, deleted map[string]records.Record, current []records.Record) *Report {
+	report := &Report{Missing: []records.Record{}, Modified: []Modified{}, Extra: []records.Record{}}
+	seen := make(map[string]bool, len(current))
+
+	for _, record := range current {
+		seen[key(record)] = true
+
+		want, ok := desired[key(record)]
+
+		switch {
+		case !ok:
+			if _, gone := deleted[key(record)]; gone {
+				report.Extra = append(report.Extra, record)
+			}
+		case !slices.Equal(want.Targets, record.Targets) || (want.TTL != 0 && want.TTL != record.TTL):
+			report.Modified = append(report.Modified, Modified{Desired: want, Current: record})
+		}
+	}
+
+	for _, record := range desired {
+		if !seen[key(record)] {
+			report.Missing = append(report.Missing, record)
+		}
+	}
+
+	slices.SortFunc(report.Missing, func(left, right records.Record) int {
+		return strings.Compare(key(left), key(right))
+	})
+
+	report.InSync = len(report.Missing)+len(report.Modified)+len(report.Extra) == 0
+
+	return report
+}
+
+// updateMetrics publishes the number of drifted records by kind and type.
+func updateMetrics(report *Report) {
+	dnsmetrics.DriftRecords.Reset()
+
+	for _, record := range report.Missing {
+		dnsmetrics.DriftRecords.WithLabelValues(KindMissing, record.Type).Inc()
+	}
+
+	for _, modified := range report.Modified {
+		dnsmetrics.DriftRecords.WithLabelValues(KindModified, modified.Desired.Type).Inc()
+	}
+
+	for _, record := range report.Extra {
+		dnsmetrics.DriftRecords.WithLabelValues(KindExtra, record.Type).Inc()
+	}
+
+	dnsmetrics.DriftLastCheck.Set(float64(report.CheckedAt.Unix()))
+}
+
+// load reads the desired state from the state file, if it exists.
+func (d *Detector) load() error {
+	content, err := os.ReadFile(d.state)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read drift state")
+	}
+
+	var state desiredState
+
+	err = json.Unmarshal(content, &state)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode drift state %s", d.state)
+	}
+
+	for _, record := range state.Desired {
+		d.desired[key(record)] = record
+	}
+
+	for _, record := range state.Deleted {
+		d.deleted[key(record)] = record
+	}
+
+	return nil
+}
+
+// saveLocked writes the desired state to the state file if it changed, logging
+// failures, which must not fail changes that were already applied. d.mu must be held.
+func (d *Detector) saveLocked(ctx context.Context) {
+	if d.state == "" || !d.dirty {
+		return
+	}
+
+	state := desiredState{Desired: sortedRecords(d.desired), Deleted: sortedRecords(d.deleted)}
+
+	content, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to encode drift state", "error", err)
+
+		return
+	}
+
+	// Written to a temporary file and renamed into place, so a crash never leaves a truncated file
+	temp, err := os.CreateTemp(filepath.Dir(d.state), ".drift-*.tmp")
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to create drift state", "error", err)
+
+		return
+	}
+
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Close()
+	} else {
+		_ = temp.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), d.state)
+	}
+
+	if err != nil {
+		_ = os.Remove(temp.Name())
+
+		slog.ErrorContext(ctx, "failed to write drift state", "error", err)
+
+		return
+	}
+
+	d.dirty = false
+}
+
+// sortedRecords returns the records of a set ordered by key.
+func sortedRecords(set map[string]records.Record) []records.Record {
+	list := make([]records.Record, 0, len(set))
+	for _, record := range set {
+		list = append(list, record)
+	}
+
+	slices.SortFunc(list, func(left, right records.Record) int {
+		return strings.Compare(key(left), key(right))
+	})
+
+	return list
+}
+
+// key identifies a record set by name and type.
+func key(record records.Record) string {
+	return record.Name + "/" + record.Type
+}
@@ -0,0 +1,241 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package drift
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeProvider keeps endpoints in memory and calls the detector hooks like the UniFi provider.
+type fakeProvider struct {
+	mu        sync.Mutex
+	endpoints []*endpoint.Endpoint
+	detector  *Detector
+	fail      error
+}
+
+func (f *fakeProvider) Records(context.Context) ([]*endpoint.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.endpoints), nil
+}
+
+func (f *fakeProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	f.detector.BeforeApply(ctx)
+	f.edit(changes)
+	f.detector.AfterApply(ctx, changes, f.fail)
+
+	return f.fail
+}
+
+func (f *fakeProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	return endpoints, nil
+}
+
+// edit changes records without the detector noticing, like an admin in the UniFi UI.
+func (f *fakeProvider) edit(changes *plan.Changes) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for _, removed := range slices.Concat(changes.Delete, changes.UpdateOld) {
+		f.endpoints = slices.DeleteFunc(f.endpoints, func(item *endpoint.Endpoint) bool {
+			return item.DNSName == removed.DNSName && item.RecordType == removed.RecordType
+		})
+	}
+
+	f.endpoints = append(f.endpoints, slices.Concat(changes.Create, changes.UpdateNew)...)
+}
+
+// newDetector creates a detector whose desired state holds app.home.lan and db.home.lan,
+// next to old.home.lan, which the webhook deleted, and printer.home.lan, which it never wrote.
+func newDetector(t *testing.T, opts ...Option) (*Detector, *fakeProvider) {
+	t.Helper()
+
+	prov := &fakeProvider{endpoints: []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("old.home.lan", endpoint.RecordTypeA, 300, "192.168.1.9"),
+		endpoint.NewEndpointWithTTL("printer.home.lan", endpoint.RecordTypeA, 300, "192.168.1.20"),
+	}}
+
+	detector, err := New(prov, opts...)
+	require.NoError(t, err)
+
+	prov.detector = detector
+
+	require.NoError(t, prov.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("app.home.lan", endpoint.RecordTypeA, 300, "192.168.1.10"),
+			endpoint.NewEndpointWithTTL("db.home.lan", endpoint.RecordTypeA, 300, "192.168.1.11"),
+		},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("old.home.lan", endpoint.RecordTypeA, 300, "192.168.1.9")},
+	}))
+
+	return detector, prov
+}
+
+func TestCheck_DetectsDrift(t *testing.T) {
+	t.Parallel()
+
+	detector, prov := newDetector(t)
+	ctx := context.Background()
+
+	report, err := detector.Check(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.InSync, "records the webhook never wrote are not drift")
+
+	prov.edit(&plan.Changes{
+		Delete:    []*endpoint.Endpoint{endpoint.NewEndpoint("db.home.lan", endpoint.RecordTypeA)},
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("app.home.lan", endpoint.RecordTypeA)},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("app.home.lan", endpoint.RecordTypeA, 300, "10.0.0.1")},
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpoint("manual.home.lan", endpoint.RecordTypeA, "192.168.1.50"),
+			endpoint.NewEndpoint("old.home.lan", endpoint.RecordTypeA, "192.168.1.9"),
+		},
+	})
+
+	report, err = detector.Check(ctx)
+	require.NoError(t, err)
+	assert.False(t, report.InSync)
+	require.Len(t, report.Missing, 1)
+	assert.Equal(t, "db.home.lan", report.Missing[0].Name)
+	require.Len(t, report.Modified, 1)
+	assert.Equal(t, []string{"192.168.1.10"}, report.Modified[0].Desired.Targets)
+	assert.Equal(t, []string{"10.0.0.1"}, report.Modified[0].Current.Targets)
+	require.Len(t, report.Extra, 1)
+	assert.Equal(t, "old.home.lan", report.Extra[0].Name)
+}
+
+func TestCheck_IgnoresFailedChanges(t *testing.T) {
+	t.Parallel()
+
+	detector, prov := newDetector(t)
+	ctx := context.Background()
+
+	// A failed update leaves app.home.lan in an unknown state, neither old nor new is desired
+	prov.fail = errors.New("UniFi API unavailable")
+	require.Error(t, prov.ApplyChanges(ctx, &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("app.home.lan", endpoint.RecordTypeA, 300, "192.168.1.10")},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("app.home.lan", endpoint.RecordTypeA, 300, "10.0.0.1")},
+	}))
+
+	prov.edit(&plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{endpoint.NewEndpoint("app.home.lan", endpoint.RecordTypeA)},
+		UpdateNew: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("app.home.lan", endpoint.RecordTypeA, 300, "192.168.1.99")},
+	})
+
+	report, err := detector.Check(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.InSync)
+}
+
+func TestNew_State(t *testing.T) {
+	t.Parallel()
+
+	state := filepath.Join(t.TempDir(), "drift.json")
+	_, prov := newDetector(t, WithState(state))
+
+	// A restarted detector knows the desired state without seeding it from UniFi
+	restarted, err := New(prov, WithState(state))
+	require.NoError(t, err)
+
+	prov.edit(&plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("db.home.lan", endpoint.RecordTypeA)},
+	})
+
+	report, err := restarted.Check(context.Background())
+	require.NoError(t, err)
+	require.Len(t, report.Missing, 1)
+	assert.Equal(t, "db.home.lan", report.Missing[0].Name)
+}
+
+func TestCheck_FollowsAppliedChanges(t *testing.T) {
+	t.Parallel()
+
+	detector, prov := newDetector(t)
+	ctx := context.Background()
+
+	_, err := detector.Check(ctx)
+	require.NoError(t, err)
+
+	require.NoError(t, prov.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("new.home.lan", endpoint.RecordTypeA, "192.168.1.12")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("db.home.lan", endpoint.RecordTypeA, "192.168.1.11")},
+	}))
+
+	report, err := detector.Check(ctx)
+	require.NoError(t, err)
+	assert.True(t, report.InSync)
+
+	detector.BeforeApply(ctx)
+
+	_, err = detector.Check(ctx)
+	require.ErrorIs(t, err, ErrApplying)
+}
+
+func TestRunOnce_AutoCorrect(t *testing.T) {
+	t.Parallel()
+
+	frozen := true
+	detector, prov := newDetector(t, WithAutoCorrect(true), WithFreeze(func() bool { return frozen }))
+	ctx := context.Background()
+
+	detector.runOnce(ctx)
+	prov.edit(&plan.Changes{
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("db.home.lan", endpoint.RecordTypeA)},
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("manual.home.lan", endpoint.RecordTypeA, "192.168.1.50")},
+	})
+
+	detector.runOnce(ctx)
+
+	current, _ := prov.Records(ctx)
+	assert.Len(t, current, 3, "nothing is corrected while frozen")
+
+	frozen = false
+
+	detector.runOnce(ctx)
+	assert.True(t, detector.report.Corrected)
+
+	current, _ = prov.Records(ctx)
+	assert.ElementsMatch(t, []string{"app.home.lan", "db.home.lan", "manual.home.lan", "printer.home.lan"},
+		names(records.FromEndpoints(current)), "missing records are restored and extra ones kept")
+}
+
+func TestServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	detector, _ := newDetector(t)
+
+	recorder := httptest.NewRecorder()
+	detector.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/drift", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+
+	_, err := detector.Check(context.Background())
+	require.NoError(t, err)
+
+	recorder = httptest.NewRecorder()
+	detector.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/drift", nil))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"in_sync": true, "missing": [], "modified": [], "extra": [], "corrected": false, "checked_at": "`+
+		detector.report.CheckedAt.Format("2006-01-02T15:04:05.999999999Z07:00")+`"}`, recorder.Body.String())
+}
+
+func names(list []records.Record) []string {
+	result := make([]string, 0, len(list))
+	for _, record := range list {
+		result = append(result, record.Name)
+	}
+
+	return result
+}
@@ -0,0 +1,243 @@
+// Package freeze implements maintenance windows during which DNS changes are not applied.
+package freeze
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Mode controls what happens to changes submitted while frozen.
+type Mode string
+
+const (
+	// ModeReject refuses changes while frozen; external-dns retries them on its next sync.
+	ModeReject Mode = "reject"
+	// ModeQueue accepts changes while frozen and applies them when the freeze ends.
+	ModeQueue Mode = "queue"
+)
+
+// Freeze sources reported in status and logs.
+const (
+	SourceConfig   = "config"
+	SourceSchedule = "schedule"
+	SourceAdmin    = "admin"
+)
+
+// checkInterval is how often the controller re-evaluates schedules and flushes queued changes.
+const checkInterval = 15 * time.Second
+
+// Controller tracks whether DNS changes are frozen and holds changes deferred during a freeze.
+type Controller struct {
+	provider provider.DNSProvider
+	now      func() time.Time
+
+	// mu guards the settings, which can change when the configuration is reloaded, and the state below them
+	mu       sync.Mutex
+	mode     Mode
+	enabled  bool
+	windows  []Window
+	location *time.Location
+	admin    bool
+	pending  *plan.Changes
+}
+
+// Option configures optional Controller behavior.
+type Option func(*Controller)
+
+// WithLocation evaluates the windows in location instead of UTC.
+func WithLocation(location *time.Location) Option {
+	return func(c *Controller) {
+		c.location = location
+	}
+}
+
+// New creates a freeze controller. Changes queued while frozen are applied through prov.
+func New(prov provider.DNSProvider, mode Mode, enabled bool, schedule []string, opts ...Option) (*Controller, error) {
+	controller := &Controller{
+		provider: prov,
+		location: time.UTC,
+		now:      time.Now,
+	}
+
+	for _, opt := range opts {
+		opt(controller)
+	}
+
+	err := controller.Reconfigure(mode, enabled, schedule, controller.location)
+	if err != nil {
+		return nil, err
+	}
+
+	return controller, nil
+}
+
+// Reconfigure replaces the mode, the configured freeze and the windows, for
+// configuration reloads. On error the previous settings are kept. Changes
+// queued before are applied when the freeze ends, whatever the new mode.
+func (c *Controller) Reconfigure(mode Mode, enabled bool, schedule []string, location *time.Location) error {
+	if mode != ModeReject && mode != ModeQueue {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.Newf("unknown freeze mode %q", mode)
+	}
+
+	windows := make([]Window, 0, len(schedule))
+
+	for _, expr := range schedule {
+		window, err := ParseWindow(expr)
+		if err != nil {
+			return err
+		}
+
+		windows = append(windows, window)
+	}
+
+	c.mu.Lock()
+	c.mode = mode
+	c.enabled = enabled
+	c.windows = windows
+	c.location = location
+	c.mu.Unlock()
+
+	c.updateMetrics()
+
+	return nil
+}
+
+// Mode returns the configured freeze mode.
+func (c *Controller) Mode() Mode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.mode
+}
+
+// Frozen reports whether DNS changes are currently frozen.
+func (c *Controller) Frozen() bool {
+	return len(c.Sources()) > 0
+}
+
+// Sources returns the reasons the controller is currently frozen.
+func (c *Controller) Sources() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var sources []string
+
+	if c.enabled {
+		sources = append(sources, SourceConfig)
+	}
+
+	now := c.now().In(c.location)
+	for _, window := range c.windows {
+		if window.Contains(now) {
+			sources = append(sources, SourceSchedule)
+
+			break
+		}
+	}
+
+	if c.admin {
+		sources = append(sources, SourceAdmin)
+	}
+
+	return sources
+}
+
+// SetAdmin enables or disables the administrative freeze override.
+// Clearing it does not lift a freeze caused by configuration or schedule.
+func (c *Controller) SetAdmin(frozen bool) {
+	c.mu.Lock()
+	c.admin = frozen
+	c.mu.Unlock()
+
+	slog.Info("administrative freeze changed", "frozen", frozen)
+	c.updateMetrics()
+}
+
+// Defer stores changes to be applied when the freeze ends.
+// Records do not change while frozen, so every plan from external-dns is computed
+// against the same state and the latest one supersedes any earlier queued plan.
+func (c *Controller) Defer(changes *plan.Changes) {
+	c.mu.Lock()
+	replaced := c.pending != nil
+	c.pending = changes
+	c.mu.Unlock()
+
+	slog.Info("queued DNS changes until freeze ends",
+		"create", len(changes.Create),
+		"update", len(changes.UpdateNew),
+		"delete", len(changes.Delete),
+		"replaced_previous", replaced)
+
+	dnsmetrics.FreezePendingChanges.Set(1)
+}
+
+// HasPending reports whether changes are queued.
+func (c *Controller) HasPending() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.pending != nil
+}
+
+// Run periodically re-evaluates the freeze state and applies queued changes once it ends.
+// It blocks until the context is canceled.
+func (c *Controller) Run(ctx context.Context) {
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+
+	c.updateMetrics()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.updateMetrics()
+			c.flush(ctx)
+		}
+	}
+}
+
+// flush applies queued changes if the freeze has ended.
+func (c *Controller) flush(ctx context.Context) {
+	if c.Frozen() {
+		return
+	}
+
+	c.mu.Lock()
+	changes := c.pending
+	c.pending = nil
+	c.mu.Unlock()
+
+	if changes == nil {
+		return
+	}
+
+	dnsmetrics.FreezePendingChanges.Set(0)
+
+	slog.InfoContext(ctx, "freeze ended, applying queued DNS changes")
+
+	// A failed plan is dropped: external-dns computes a fresh one on its next sync
+	err := c.provider.ApplyChanges(ctx, changes)
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to apply queued DNS changes", "error", err)
+	}
+}
+
+// updateMetrics exports the current freeze state.
+func (c *Controller) updateMetrics() {
+	frozen := 0.0
+	if c.Frozen() {
+		frozen = 1
+	}
+
+	dnsmetrics.FreezeActive.Set(frozen)
+}
@@ -0,0 +1,182 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package freeze
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeProvider records applied change sets.
+type fakeProvider struct {
+	applied []*plan.Changes
+}
+
+func (f *fakeProvider) Records(context.Context) ([]*endpoint.Endpoint, error) {
+	return nil, nil
+}
+
+func (f *fakeProvider) ApplyChanges(_ context.Context, changes *plan.Changes) error {
+	f.applied = append(f.applied, changes)
+
+	return nil
+}
+
+func (f *fakeProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	return endpoints, nil
+}
+
+func TestParseWindow_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, expr := range []string{
+		"",
+		"02:00-04:00",
+		"Someday 02:00-04:00",
+		"Sat 02:00",
+		"Sat 25:00-26:00",
+		"Sat 02:61-03:00",
+		"Sat 02:00-02:00",
+	} {
+		_, err := ParseWindow(expr)
+		assert.Error(t, err, expr)
+	}
+}
+
+func TestWindow_Contains(t *testing.T) {
+	t.Parallel()
+
+	// 2026-10-17 is a Saturday
+	saturday := func(hour, minute int) time.Time {
+		return time.Date(2026, 10, 17, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		expr     string
+		moment   time.Time
+		expected bool
+	}{
+		{name: "inside", expr: "Sat 02:00-04:00", moment: saturday(3, 0), expected: true},
+		{name: "end is exclusive", expr: "Sat 02:00-04:00", moment: saturday(4, 0), expected: false},
+		{name: "other day", expr: "Mon-Fri 02:00-04:00", moment: saturday(3, 0), expected: false},
+		{name: "wrapping day range", expr: "Fri-Sun 02:00-04:00", moment: saturday(3, 0), expected: true},
+		{name: "every day", expr: "* 00:00-24:00", moment: saturday(23, 59), expected: true},
+		{name: "overnight evening", expr: "Sat 22:00-06:00", moment: saturday(23, 0), expected: true},
+		{name: "overnight morning belongs to previous day", expr: "Fri 22:00-06:00", moment: saturday(5, 0), expected: true},
+		{name: "overnight morning of other day", expr: "Sat 22:00-06:00", moment: saturday(5, 0), expected: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			window, err := ParseWindow(tt.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, window.Contains(tt.moment))
+		})
+	}
+}
+
+func TestController_QueueAndFlush(t *testing.T) {
+	t.Parallel()
+
+	prov := &fakeProvider{}
+
+	controller, err := New(prov, ModeQueue, false, nil)
+	require.NoError(t, err)
+
+	controller.SetAdmin(true)
+	assert.True(t, controller.Frozen())
+	assert.Equal(t, []string{SourceAdmin}, controller.Sources())
+
+	first := &plan.Changes{Create: []*endpoint.Endpoint{{DNSName: "a.example.com"}}}
+	latest := &plan.Changes{Create: []*endpoint.Endpoint{{DNSName: "b.example.com"}}}
+
+	controller.Defer(first)
+	controller.Defer(latest)
+
+	// Still frozen: nothing is applied
+	controller.flush(context.Background())
+	assert.Empty(t, prov.applied)
+
+	controller.SetAdmin(false)
+	controller.flush(context.Background())
+
+	require.Len(t, prov.applied, 1)
+	assert.Same(t, latest, prov.applied[0])
+	assert.False(t, controller.HasPending())
+}
+
+func TestController_Location(t *testing.T) {
+	t.Parallel()
+
+	// 01:00 UTC on a Saturday is 03:00 in Berlin summer time
+	now := func() time.Time { return time.Date(2026, 7, 18, 1, 0, 0, 0, time.UTC) }
+
+	controller, err := New(&fakeProvider{}, ModeReject, false, []string{"Sat 02:00-04:00"})
+	require.NoError(t, err)
+
+	controller.now = now
+	assert.False(t, controller.Frozen(), "windows are in UTC by default")
+
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	controller, err = New(&fakeProvider{}, ModeReject, false, []string{"Sat 02:00-04:00"}, WithLocation(berlin))
+	require.NoError(t, err)
+
+	controller.now = now
+	assert.Equal(t, []string{SourceSchedule}, controller.Sources())
+}
+
+func TestController_Reconfigure(t *testing.T) {
+	t.Parallel()
+
+	controller, err := New(&fakeProvider{}, ModeReject, false, nil)
+	require.NoError(t, err)
+
+	controller.now = func() time.Time { return time.Date(2026, 10, 17, 3, 0, 0, 0, time.UTC) }
+
+	require.NoError(t, controller.Reconfigure(ModeQueue, true, []string{"Sat 02:00-04:00"}, time.UTC))
+	assert.Equal(t, ModeQueue, controller.Mode())
+	assert.Equal(t, []string{SourceConfig, SourceSchedule}, controller.Sources())
+
+	// Invalid settings keep the previous ones
+	require.Error(t, controller.Reconfigure(ModeReject, false, []string{"Someday 02:00-04:00"}, time.UTC))
+	assert.Equal(t, ModeQueue, controller.Mode())
+	assert.True(t, controller.Frozen())
+
+	require.NoError(t, controller.Reconfigure(ModeReject, false, nil, time.UTC))
+	assert.False(t, controller.Frozen())
+}
+
+func TestController_ServeHTTP(t *testing.T) {
+	t.Parallel()
+
+	controller, err := New(&fakeProvider{}, ModeReject, false, nil)
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	controller.ServeHTTP(recorder, httptest.NewRequest(http.MethodPut, "/admin/freeze", nil))
+
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.JSONEq(t, `{"frozen":true,"mode":"reject","sources":["admin"],"pending":false}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	controller.ServeHTTP(recorder, httptest.NewRequest(http.MethodDelete, "/admin/freeze", nil))
+
+	assert.JSONEq(t, `{"frozen":false,"mode":"reject","sources":[],"pending":false}`, recorder.Body.String())
+
+	recorder = httptest.NewRecorder()
+	controller.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/admin/freeze", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, recorder.Code)
+}
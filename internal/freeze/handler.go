@@ -0,0 +1,48 @@
+package freeze
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Status is the JSON representation of the freeze state.
+type Status struct {
+	Frozen  bool     `json:"frozen"`
+	Mode    Mode     `json:"mode"`
+	Sources []string `json:"sources"`
+	Pending bool     `json:"pending"`
+}
+
+// ServeHTTP implements the administrative freeze endpoint.
+// GET returns the current status, PUT enables the administrative freeze
+// and DELETE clears it. Every method responds with the resulting status.
+func (c *Controller) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		c.SetAdmin(true)
+	case http.MethodDelete:
+		c.SetAdmin(false)
+	default:
+		w.Header().Set("Allow", "GET, PUT, DELETE")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	sources := c.Sources()
+	if sources == nil {
+		sources = []string{}
+	}
+
+	status := Status{
+		Frozen:  len(sources) > 0,
+		Mode:    c.Mode(),
+		Sources: sources,
+		Pending: c.HasPending(),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(status)
+}
@@ -0,0 +1,163 @@
+package freeze
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+const (
+	minutesPerHour = 60
+	minutesPerDay  = 24 * minutesPerHour
+	daysPerWeek    = 7
+)
+
+//nolint:gochecknoglobals // Read-only lookup table for weekday parsing
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// Window is a recurring weekly maintenance window.
+// Windows whose end is before their start span midnight and end on the following day.
+type Window struct {
+	days  [daysPerWeek]bool
+	start int // minutes since midnight
+	end   int // minutes since midnight
+}
+
+// ParseWindow parses a schedule expression of the form "<days> <HH:MM>-<HH:MM>".
+// Days is "*" for every day or a comma-separated list of weekday abbreviations
+// and ranges, for example "Sat,Sun 02:00-04:00" or "Mon-Fri 22:00-06:00".
+func ParseWindow(expr string) (Window, error) {
+	var window Window
+
+	fields := strings.Fields(expr)
+	if len(fields) != 2 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return window, errors.Newf("invalid freeze window %q: expected \"<days> <HH:MM>-<HH:MM>\"", expr)
+	}
+
+	err := window.parseDays(fields[0])
+	if err != nil {
+		return window, errors.Wrapf(err, "invalid freeze window %q", expr)
+	}
+
+	startText, endText, found := strings.Cut(fields[1], "-")
+	if !found {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return window, errors.Newf("invalid freeze window %q: expected time range HH:MM-HH:MM", expr)
+	}
+
+	window.start, err = parseClock(startText)
+	if err != nil {
+		return window, errors.Wrapf(err, "invalid freeze window %q", expr)
+	}
+
+	window.end, err = parseClock(endText)
+	if err != nil {
+		return window, errors.Wrapf(err, "invalid freeze window %q", expr)
+	}
+
+	if window.start == window.end {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return window, errors.Newf("invalid freeze window %q: start and end must differ", expr)
+	}
+
+	return window, nil
+}
+
+// Contains reports whether the given time falls inside the window.
+func (w Window) Contains(moment time.Time) bool {
+	minute := moment.Hour()*minutesPerHour + moment.Minute()
+
+	if w.start < w.end {
+		return w.days[moment.Weekday()] && minute >= w.start && minute < w.end
+	}
+
+	// Overnight window: the evening part belongs to today, the morning part to yesterday
+	if minute >= w.start {
+		return w.days[moment.Weekday()]
+	}
+
+	yesterday := (moment.Weekday() + daysPerWeek - 1) % daysPerWeek
+
+	return minute < w.end && w.days[yesterday]
+}
+
+// parseDays parses the weekday part of a schedule expression.
+func (w *Window) parseDays(text string) error {
+	if text == "*" {
+		for day := range w.days {
+			w.days[day] = true
+		}
+
+		return nil
+	}
+
+	for part := range strings.SplitSeq(strings.ToLower(text), ",") {
+		firstText, lastText, isRange := strings.Cut(part, "-")
+
+		first, ok := weekdays[firstText]
+		if !ok {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return errors.Newf("unknown weekday %q", firstText)
+		}
+
+		last := first
+
+		if isRange {
+			last, ok = weekdays[lastText]
+			if !ok {
+				//nolint:wrapcheck // Creating new error, not wrapping
+				return errors.Newf("unknown weekday %q", lastText)
+			}
+		}
+
+		for day := first; ; day = (day + 1) % daysPerWeek {
+			w.days[day] = true
+
+			if day == last {
+				break
+			}
+		}
+	}
+
+	return nil
+}
+
+// parseClock parses HH:MM into minutes since midnight.
+func parseClock(text string) (int, error) {
+	hoursText, minutesText, found := strings.Cut(text, ":")
+	if !found {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return 0, errors.Newf("invalid time %q: expected HH:MM", text)
+	}
+
+	hours, err := strconv.Atoi(hoursText)
+	if err != nil || hours < 0 || hours > 24 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return 0, errors.Newf("invalid hour in %q", text)
+	}
+
+	minutes, err := strconv.Atoi(minutesText)
+	if err != nil || minutes < 0 || minutes >= minutesPerHour {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return 0, errors.Newf("invalid minute in %q", text)
+	}
+
+	total := hours*minutesPerHour + minutes
+	if total > minutesPerDay {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return 0, errors.Newf("invalid time %q: must not exceed 24:00", text)
+	}
+
+	return total, nil
+}
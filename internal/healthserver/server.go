@@ -8,6 +8,7 @@ import (
 
 	"github.com/lexfrei/external-dns-unifios-webhook/api/health"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/freeze"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
@@ -39,11 +40,22 @@ type Server struct {
 	registry       *prometheus.Registry
 	readinessCache *readinessCache
 	checkGroup     singleflight.Group
+	freeze         *freeze.Controller
+}
+
+// Option configures optional Server behavior.
+type Option func(*Server)
+
+// WithFreeze reports the freeze state of the given controller in readiness responses.
+func WithFreeze(controller *freeze.Controller) Option {
+	return func(s *Server) {
+		s.freeze = controller
+	}
 }
 
 // New creates a new health server instance with a custom Prometheus registry.
-func New(prov provider.DNSProvider, registry *prometheus.Registry) *Server {
-	return &Server{
+func New(prov provider.DNSProvider, registry *prometheus.Registry, opts ...Option) *Server {
+	srv := &Server{
 		provider: prov,
 		registry: registry,
 		readinessCache: &readinessCache{
@@ -51,6 +63,12 @@ func New(prov provider.DNSProvider, registry *prometheus.Registry) *Server {
 			checkedAt: time.Time{}, // Zero value means cache is cold
 		},
 	}
+
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	return srv
 }
 
 // Liveness returns OK if the service is alive.
@@ -156,6 +174,11 @@ func (s *Server) writeReadinessResponse(w http.ResponseWriter, isReady bool) {
 		statusCode = http.StatusOK
 		statusValue = health.Ok
 		message = "Service is ready"
+
+		// Freeze does not affect readiness, but operators should see it in probe output
+		if s.freeze != nil && s.freeze.Frozen() {
+			message = "Service is ready, DNS changes are frozen"
+		}
 	} else {
 		statusCode = http.StatusServiceUnavailable
 		statusValue = health.Error
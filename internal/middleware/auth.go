@@ -0,0 +1,167 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/secret"
+)
+
+const (
+	// SignatureHeader carries the hex-encoded HMAC-SHA256 request signature, prefixed with "sha256=".
+	SignatureHeader = "X-Webhook-Signature"
+	// TimestampHeader carries the Unix time in seconds at which the request was signed.
+	TimestampHeader = "X-Webhook-Timestamp"
+
+	signaturePrefix = "sha256="
+	bearerPrefix    = "Bearer "
+
+	// maxSignatureSkew bounds the age of signed requests to limit replay.
+	maxSignatureSkew = 5 * time.Minute
+
+	// maxSignedBodySize matches the webhook request body limit.
+	maxSignedBodySize = 5 << 20 // 5MB
+)
+
+// Authentication failure reasons used as metric labels.
+const (
+	reasonMissingToken     = "missing_token"
+	reasonInvalidToken     = "invalid_token"
+	reasonMissingSignature = "missing_signature"
+	reasonInvalidSignature = "invalid_signature"
+	reasonExpired          = "expired_timestamp"
+	reasonUnreadableBody   = "unreadable_body"
+)
+
+// Authenticator verifies bearer tokens and HMAC request signatures.
+// Either secret may be nil; when both are set, requests must satisfy both.
+// external-dns sends neither, so it authenticates with a client certificate instead.
+type Authenticator struct {
+	token   *secret.File
+	hmacKey *secret.File
+	now     func() time.Time
+}
+
+// NewAuthenticator creates an Authenticator from rotating secret files.
+func NewAuthenticator(token, hmacKey *secret.File) *Authenticator {
+	return &Authenticator{
+		token:   token,
+		hmacKey: hmacKey,
+		now:     time.Now,
+	}
+}
+
+// Wrap returns a handler that rejects unauthenticated requests with 401 Unauthorized.
+func (a *Authenticator) Wrap(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reason := a.authenticate(r)
+		if reason != "" {
+			slog.WarnContext(r.Context(), "unauthenticated webhook request",
+				"reason", reason,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"remote_addr", r.RemoteAddr)
+			dnsmetrics.AuthFailures.WithLabelValues(reason).Inc()
+
+			if a.token != nil {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="external-dns-unifios-webhook"`)
+			}
+
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// authenticate returns an empty string for authenticated requests, or the failure reason.
+func (a *Authenticator) authenticate(r *http.Request) string {
+	if a.token != nil {
+		reason := a.checkToken(r)
+		if reason != "" {
+			return reason
+		}
+	}
+
+	if a.hmacKey != nil {
+		return a.checkSignature(r)
+	}
+
+	return ""
+}
+
+// checkToken validates the bearer token using a constant-time comparison.
+func (a *Authenticator) checkToken(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if !strings.HasPrefix(header, bearerPrefix) {
+		return reasonMissingToken
+	}
+
+	presented := []byte(strings.TrimPrefix(header, bearerPrefix))
+	if subtle.ConstantTimeCompare(presented, a.token.Value()) != 1 {
+		return reasonInvalidToken
+	}
+
+	return ""
+}
+
+// checkSignature validates HMAC-SHA256 over "<timestamp>.<body>" and restores the body for the next handler.
+func (a *Authenticator) checkSignature(r *http.Request) string {
+	signatureHeader := r.Header.Get(SignatureHeader)
+	timestampHeader := r.Header.Get(TimestampHeader)
+
+	if !strings.HasPrefix(signatureHeader, signaturePrefix) || timestampHeader == "" {
+		return reasonMissingSignature
+	}
+
+	timestamp, err := strconv.ParseInt(timestampHeader, 10, 64)
+	if err != nil {
+		return reasonInvalidSignature
+	}
+
+	skew := a.now().Sub(time.Unix(timestamp, 0)).Abs()
+	if skew > maxSignatureSkew {
+		return reasonExpired
+	}
+
+	presented, err := hex.DecodeString(strings.TrimPrefix(signatureHeader, signaturePrefix))
+	if err != nil {
+		return reasonInvalidSignature
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxSignedBodySize))
+	if err != nil {
+		return reasonUnreadableBody
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	if !hmac.Equal(presented, Sign(a.hmacKey.Value(), timestampHeader, body)) {
+		return reasonInvalidSignature
+	}
+
+	return ""
+}
+
+// Sign computes the HMAC-SHA256 request signature for the given timestamp and body.
+// Clients send it hex-encoded in SignatureHeader with the "sha256=" prefix.
+func Sign(key []byte, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte{'.'})
+	mac.Write(body)
+
+	return mac.Sum(nil)
+}
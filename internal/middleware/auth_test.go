@@ -0,0 +1,125 @@
+//nolint:testpackage // Testing private fields requires same-package tests
+package middleware
+
+import (
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/secret"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecret(t *testing.T, name, value string) *secret.File {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(value+"\n"), 0o600))
+
+	file, err := secret.NewFile(path)
+	require.NoError(t, err)
+
+	return file
+}
+
+// echoHandler responds with the request body so tests can check it survives signature checks.
+func echoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		_, _ = w.Write(body)
+	})
+}
+
+func TestAuthenticator_BearerToken(t *testing.T) {
+	t.Parallel()
+
+	handler := NewAuthenticator(writeSecret(t, "token", "s3cret"), nil).Wrap(echoHandler())
+
+	tests := []struct {
+		name     string
+		header   string
+		expected int
+	}{
+		{name: "valid", header: "Bearer s3cret", expected: http.StatusOK},
+		{name: "wrong token", header: "Bearer other", expected: http.StatusUnauthorized},
+		{name: "missing", header: "", expected: http.StatusUnauthorized},
+		{name: "wrong scheme", header: "Basic s3cret", expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			req := httptest.NewRequest(http.MethodGet, "/records", nil)
+			if tt.header != "" {
+				req.Header.Set("Authorization", tt.header)
+			}
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expected, recorder.Code)
+		})
+	}
+}
+
+func TestAuthenticator_HMACSignature(t *testing.T) {
+	t.Parallel()
+
+	key := "hmac-key"
+	authenticator := NewAuthenticator(nil, writeSecret(t, "hmac", key))
+	handler := authenticator.Wrap(echoHandler())
+
+	body := `{"create":[]}`
+	now := time.Now()
+
+	sign := func(timestamp time.Time, payload string) (string, string) {
+		ts := strconv.FormatInt(timestamp.Unix(), 10)
+
+		return ts, signaturePrefix + hex.EncodeToString(Sign([]byte(key), ts, []byte(payload)))
+	}
+
+	tests := []struct {
+		name      string
+		timestamp time.Time
+		signed    string
+		expected  int
+	}{
+		{name: "valid", timestamp: now, signed: body, expected: http.StatusOK},
+		{name: "tampered body", timestamp: now, signed: `{"delete":[]}`, expected: http.StatusUnauthorized},
+		{name: "expired", timestamp: now.Add(-time.Hour), signed: body, expected: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			timestamp, signature := sign(tt.timestamp, tt.signed)
+
+			req := httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(body))
+			req.Header.Set(TimestampHeader, timestamp)
+			req.Header.Set(SignatureHeader, signature)
+
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			assert.Equal(t, tt.expected, recorder.Code)
+
+			if tt.expected == http.StatusOK {
+				assert.Equal(t, body, recorder.Body.String(), "body must be passed through")
+			}
+		})
+	}
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/records", strings.NewReader(body)))
+	assert.Equal(t, http.StatusUnauthorized, recorder.Code, "unsigned request must be rejected")
+}
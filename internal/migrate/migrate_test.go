@@ -0,0 +1,157 @@
+package migrate_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/migrate"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func parse(t *testing.T, source migrate.Source, content string) *migrate.Parsed {
+	t.Helper()
+
+	parsed, err := migrate.Parse(strings.NewReader(content), source, "test")
+	require.NoError(t, err)
+
+	return parsed
+}
+
+func names(parsed *migrate.Parsed) []string {
+	result := make([]string, 0, len(parsed.Entries))
+	for _, entry := range parsed.Entries {
+		result = append(result, entry.Record.Type+" "+entry.Record.Name+" "+strings.Join(entry.Record.Targets, ","))
+	}
+
+	return result
+}
+
+func TestParse_Pihole(t *testing.T) {
+	t.Parallel()
+
+	parsed := parse(t, migrate.SourcePihole, `# custom.list
+192.168.1.10 nas.home.lan NAS.home.lan.
+fd00::10     nas.home.lan
+0.0.0.0      ads.example.com
+cname=www.home.lan,nas.home.lan
+cname=a.home.lan,b.home.lan,nas.home.lan,600
+`)
+
+	assert.Equal(t, []string{
+		"A nas.home.lan 192.168.1.10",
+		"A nas.home.lan 192.168.1.10",
+		"AAAA nas.home.lan fd00::10",
+		"CNAME www.home.lan nas.home.lan",
+		"CNAME a.home.lan nas.home.lan",
+		"CNAME b.home.lan nas.home.lan",
+	}, names(parsed))
+	assert.Equal(t, int64(600), parsed.Entries[5].Record.TTL)
+	require.Len(t, parsed.Skipped, 1)
+	assert.Equal(t, "test:4", parsed.Skipped[0].Location)
+}
+
+func TestParse_Dnsmasq(t *testing.T) {
+	t.Parallel()
+
+	parsed := parse(t, migrate.SourceDnsmasq, `address=/printer.home.lan/192.168.1.30
+address=/blocked.example.com/
+address=/a.home.lan/b.home.lan/192.168.1.31
+host-record=router.home.lan,gw.home.lan,192.168.1.1,fd00::1,3600
+server=1.1.1.1
+192.168.1.40 hosts-line.home.lan
+`)
+
+	assert.Equal(t, []string{
+		"A printer.home.lan 192.168.1.30",
+		"A a.home.lan 192.168.1.31",
+		"A b.home.lan 192.168.1.31",
+		"A router.home.lan 192.168.1.1",
+		"A gw.home.lan 192.168.1.1",
+		"AAAA router.home.lan fd00::1",
+		"AAAA gw.home.lan fd00::1",
+	}, names(parsed))
+	assert.Len(t, parsed.Skipped, 3, "empty address, server= and the hosts line")
+}
+
+func TestParse_AdGuard(t *testing.T) {
+	t.Parallel()
+
+	full := parse(t, migrate.SourceAdGuard, `dns:
+  upstream_dns: [1.1.1.1]
+filtering:
+  rewrites:
+    - domain: nas.home.lan
+      answer: 192.168.1.10
+    - domain: www.home.lan
+      answer: nas.home.lan
+    - domain: '*.apps.home.lan'
+      answer: 192.168.1.20
+    - domain: '*.cname.home.lan'
+      answer: nas.home.lan
+    - domain: keep.home.lan
+      answer: A
+`)
+
+	assert.Equal(t, []string{
+		"A nas.home.lan 192.168.1.10",
+		"CNAME www.home.lan nas.home.lan",
+		"A *.apps.home.lan 192.168.1.20",
+	}, names(full))
+	assert.Len(t, full.Skipped, 2, "wildcard CNAME and upstream answer")
+
+	list := parse(t, migrate.SourceAdGuard, "- domain: nas.home.lan\n  answer: 192.168.1.10\n")
+	assert.Equal(t, []string{"A nas.home.lan 192.168.1.10"}, names(list))
+}
+
+func TestNewPlan(t *testing.T) {
+	t.Parallel()
+
+	hosts := parse(t, migrate.SourceHosts, `192.168.1.10 nas.home.lan
+192.168.1.11 nas.home.lan
+192.168.1.20 existing.home.lan
+192.168.1.30 changed.home.lan
+192.168.1.40 aliased.home.lan
+192.168.1.50 mixed.home.lan
+192.168.1.60 outside.example.com
+`)
+	cnames := parse(t, migrate.SourcePihole, "cname=mixed.home.lan,nas.home.lan\n")
+
+	hosts.Exclude(func(name string) bool { return strings.HasSuffix(name, ".home.lan") }, "is outside the domain filters")
+
+	current := []records.Record{
+		{Name: "existing.home.lan", Type: "A", Targets: []string{"192.168.1.20"}},
+		{Name: "changed.home.lan", Type: "A", Targets: []string{"192.168.1.99"}},
+		{Name: "aliased.home.lan", Type: "CNAME", Targets: []string{"nas.home.lan"}},
+	}
+
+	migration := migrate.NewPlan(current, hosts, cnames)
+
+	require.Len(t, migration.Create, 1)
+	assert.Equal(t, records.Record{Name: "nas.home.lan", Type: "A", Targets: []string{"192.168.1.10", "192.168.1.11"}}, migration.Create[0])
+	require.Len(t, migration.Existing, 1)
+	assert.Equal(t, "existing.home.lan", migration.Existing[0].Name)
+
+	conflicts := make(map[string]string)
+	for _, conflict := range migration.Conflicts {
+		conflicts[conflict.Record.Name+" "+conflict.Record.Type] = conflict.Reason
+	}
+
+	assert.Equal(t, map[string]string{
+		"changed.home.lan A":   "UniFi has changed.home.lan A [192.168.1.99]",
+		"aliased.home.lan A":   "UniFi has other records for this name: CNAME",
+		"mixed.home.lan A":     "the sources mix a CNAME with other records for this name",
+		"mixed.home.lan CNAME": "the sources mix a CNAME with other records for this name",
+	}, conflicts)
+	assert.Len(t, migration.Skipped, 1)
+	assert.Len(t, migration.ProviderChanges().Create, 1)
+
+	var out bytes.Buffer
+
+	require.NoError(t, migration.Write(&out))
+	assert.Contains(t, out.String(), "+ nas.home.lan A [192.168.1.10, 192.168.1.11] (test:1, test:2)")
+	assert.Contains(t, out.String(), "? test:7: outside.example.com is outside the domain filters")
+	assert.Contains(t, out.String(), "1 to create, 1 existing, 4 conflicts, 1 skipped")
+}
@@ -0,0 +1,335 @@
+// Package migrate converts local DNS records of Pi-hole, AdGuard Home and dnsmasq into records for UniFi.
+package migrate
+
+import (
+	"bufio"
+	"io"
+	"net/netip"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"go.yaml.in/yaml/v3"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Source is a file format to migrate from.
+type Source string
+
+// Supported sources.
+const (
+	// SourcePihole is Pi-hole's custom.list (hosts format) or its CNAME file (dnsmasq cname= lines).
+	SourcePihole Source = "pihole"
+	// SourceAdGuard is AdGuardHome.yaml, or a bare list of rewrites.
+	SourceAdGuard Source = "adguard"
+	// SourceDnsmasq is a dnsmasq configuration file with address=, cname= and host-record= lines.
+	SourceDnsmasq Source = "dnsmasq"
+	// SourceHosts is a hosts file.
+	SourceHosts Source = "hosts"
+)
+
+// Entry is a single record read from a source, with where it came from.
+type Entry struct {
+	Record   records.Record
+	Location string
+}
+
+// Skipped is a source line that cannot be migrated.
+type Skipped struct {
+	Location string
+	Reason   string
+}
+
+// Parsed is the outcome of reading a source file.
+type Parsed struct {
+	Entries []Entry
+	Skipped []Skipped
+}
+
+// Parse reads a source file. name is used in locations, e.g. "custom.list:3".
+func Parse(r io.Reader, source Source, name string) (*Parsed, error) {
+	switch source {
+	case SourcePihole:
+		return parseLines(r, name, true, true)
+	case SourceDnsmasq:
+		return parseLines(r, name, true, false)
+	case SourceHosts:
+		return parseLines(r, name, false, true)
+	case SourceAdGuard:
+		return parseAdGuard(r, name)
+	default:
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return nil, errors.Newf("unknown source %q", source)
+	}
+}
+
+// parseLines reads dnsmasq directives and/or hosts entries line by line.
+func parseLines(r io.Reader, name string, dnsmasq, hosts bool) (*Parsed, error) {
+	parsed := &Parsed{}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+		location := name + ":" + strconv.Itoa(lineNumber)
+
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+		line = strings.TrimSpace(line)
+
+		if line == "" {
+			continue
+		}
+
+		key, value, directive := strings.Cut(line, "=")
+
+		switch {
+		case dnsmasq && directive && !strings.ContainsAny(key, " \t"):
+			parsed.dnsmasqLine(location, strings.TrimSpace(key), strings.TrimSpace(value))
+		case hosts:
+			parsed.hostsLine(location, strings.Fields(line))
+		default:
+			parsed.skip(location, "not a dnsmasq directive")
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", name)
+	}
+
+	return parsed, nil
+}
+
+// hostsLine parses "IP name [alias...]".
+func (p *Parsed) hostsLine(location string, fields []string) {
+	if len(fields) < 2 {
+		p.skip(location, "expected an address followed by host names")
+
+		return
+	}
+
+	addr, ok := p.address(location, fields[0])
+	if !ok {
+		return
+	}
+
+	for _, host := range fields[1:] {
+		p.add(location, host, addr)
+	}
+}
+
+// dnsmasqLine parses a single dnsmasq directive.
+func (p *Parsed) dnsmasqLine(location, key, value string) {
+	switch key {
+	case "address":
+		// address=/name[/name...]/IP
+		parts := strings.Split(strings.TrimPrefix(value, "/"), "/")
+		if len(parts) < 2 || parts[len(parts)-1] == "" || parts[len(parts)-1] == "#" {
+			p.skip(location, "address= without an IP only changes upstream behavior")
+
+			return
+		}
+
+		addr, ok := p.address(location, parts[len(parts)-1])
+		if !ok {
+			return
+		}
+
+		for _, host := range parts[:len(parts)-1] {
+			p.add(location, host, addr)
+		}
+	case "host-record":
+		// host-record=name[,name...],IP[,IP...][,TTL]
+		var names, addrs []string
+
+		for _, field := range strings.Split(value, ",") {
+			field = strings.TrimSpace(field)
+			if _, err := netip.ParseAddr(field); err == nil {
+				addrs = append(addrs, field)
+			} else if _, err := strconv.Atoi(field); err != nil {
+				names = append(names, field)
+			}
+		}
+
+		if len(names) == 0 || len(addrs) == 0 {
+			p.skip(location, "host-record= needs a name and an address")
+
+			return
+		}
+
+		for _, field := range addrs {
+			addr, ok := p.address(location, field)
+			if !ok {
+				continue
+			}
+
+			for _, host := range names {
+				p.add(location, host, addr)
+			}
+		}
+	case "cname":
+		// cname=alias[,alias...],target[,TTL]
+		fields := strings.Split(value, ",")
+
+		var ttl int64
+
+		if len(fields) > 2 {
+			if parsed, err := strconv.ParseInt(strings.TrimSpace(fields[len(fields)-1]), 10, 64); err == nil {
+				ttl = parsed
+				fields = fields[:len(fields)-1]
+			}
+		}
+
+		if len(fields) < 2 {
+			p.skip(location, "cname= needs an alias and a target")
+
+			return
+		}
+
+		target := normalize(fields[len(fields)-1])
+
+		for _, alias := range fields[:len(fields)-1] {
+			p.addRecord(location, records.Record{
+				Name: normalize(alias), Type: endpoint.RecordTypeCNAME, TTL: ttl, Targets: []string{target},
+			})
+		}
+	default:
+		p.skip(location, key+"= is not a DNS record")
+	}
+}
+
+// adGuardRewrite is a DNS rewrite rule of AdGuard Home.
+type adGuardRewrite struct {
+	Domain string `yaml:"domain"`
+	Answer string `yaml:"answer"`
+}
+
+// adGuardConfig holds the parts of AdGuardHome.yaml that contain rewrites;
+// newer versions keep them under filtering, older ones under dns.
+type adGuardConfig struct {
+	DNS struct {
+		Rewrites []adGuardRewrite `yaml:"rewrites"`
+	} `yaml:"dns"`
+	Filtering struct {
+		Rewrites []adGuardRewrite `yaml:"rewrites"`
+	} `yaml:"filtering"`
+}
+
+// parseAdGuard reads rewrites from AdGuardHome.yaml or a bare list of rewrites.
+func parseAdGuard(r io.Reader, name string) (*Parsed, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s", name)
+	}
+
+	var rewrites []adGuardRewrite
+
+	var full adGuardConfig
+
+	if yaml.Unmarshal(content, &full) == nil {
+		rewrites = append(full.Filtering.Rewrites, full.DNS.Rewrites...)
+	} else {
+		err = yaml.Unmarshal(content, &rewrites)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to parse %s as AdGuard Home configuration or rewrite list", name)
+		}
+	}
+
+	parsed := &Parsed{}
+
+	for idx, rewrite := range rewrites {
+		location := name + ":rewrite " + strconv.Itoa(idx+1)
+		answer := strings.TrimSpace(rewrite.Answer)
+
+		switch {
+		case rewrite.Domain == "" || answer == "":
+			parsed.skip(location, "rewrite needs a domain and an answer")
+		case answer == "A" || answer == "AAAA":
+			parsed.skip(location, "rewrite keeps the upstream answer, nothing to migrate")
+		case isAddress(answer):
+			addr, ok := parsed.address(location, answer)
+			if ok {
+				parsed.add(location, rewrite.Domain, addr)
+			}
+		default:
+			parsed.addRecord(location, records.Record{
+				Name: normalize(rewrite.Domain), Type: endpoint.RecordTypeCNAME, Targets: []string{normalize(answer)},
+			})
+		}
+	}
+
+	return parsed, nil
+}
+
+// address parses an IP address, skipping those that block or point at the local host.
+func (p *Parsed) address(location, value string) (netip.Addr, bool) {
+	addr, err := netip.ParseAddr(value)
+
+	switch {
+	case err != nil:
+		p.skip(location, "invalid address "+strconv.Quote(value))
+	case addr.IsUnspecified():
+		p.skip(location, value+" blocks the name, nothing to migrate")
+	case addr.IsLoopback():
+		p.skip(location, value+" is a loopback address")
+	default:
+		return addr.Unmap(), true
+	}
+
+	return netip.Addr{}, false
+}
+
+// add records an address for a host name.
+func (p *Parsed) add(location, host string, addr netip.Addr) {
+	recordType := endpoint.RecordTypeA
+	if addr.Is6() {
+		recordType = endpoint.RecordTypeAAAA
+	}
+
+	p.addRecord(location, records.Record{Name: normalize(host), Type: recordType, Targets: []string{addr.String()}})
+}
+
+// addRecord records an entry unless its name cannot be stored in UniFi.
+func (p *Parsed) addRecord(location string, record records.Record) {
+	switch {
+	case record.Name == "":
+		p.skip(location, "empty host name")
+	case record.Type == endpoint.RecordTypeCNAME && strings.HasPrefix(record.Name, "*"):
+		p.skip(location, "wildcard CNAME records are not supported by UniFi")
+	default:
+		p.Entries = append(p.Entries, Entry{Record: record, Location: location})
+	}
+}
+
+// skip records a line that is not migrated.
+func (p *Parsed) skip(location, reason string) {
+	p.Skipped = append(p.Skipped, Skipped{Location: location, Reason: reason})
+}
+
+// isAddress reports whether value is an IP address.
+func isAddress(value string) bool {
+	_, err := netip.ParseAddr(value)
+
+	return err == nil
+}
+
+// normalize lowercases a name and removes the trailing dot of absolute names.
+func normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// Exclude moves entries whose names do not match into Skipped with the given reason.
+func (p *Parsed) Exclude(match func(name string) bool, reason string) {
+	kept := p.Entries[:0]
+
+	for _, entry := range p.Entries {
+		if match(entry.Record.Name) {
+			kept = append(kept, entry)
+		} else {
+			p.skip(entry.Location, entry.Record.Name+" "+reason)
+		}
+	}
+
+	p.Entries = kept
+}
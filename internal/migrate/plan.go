@@ -0,0 +1,190 @@
+package migrate
+
+import (
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Conflict is a migrated record that was not created because it contradicts
+// another migrated record or a record already in UniFi.
+type Conflict struct {
+	Record    records.Record
+	Locations []string
+	Reason    string
+}
+
+// Plan is the outcome of comparing migrated records with UniFi.
+type Plan struct {
+	// Create are records missing from UniFi.
+	Create []records.Record
+	// Existing are records UniFi already has with the same targets.
+	Existing []records.Record
+	// Conflicts are records that are not created.
+	Conflicts []Conflict
+	// Skipped are source lines that cannot be migrated.
+	Skipped []Skipped
+
+	locations map[string][]string
+}
+
+// NewPlan merges parsed sources and compares them with the records in UniFi.
+// Entries with the same name and type are merged into one record. Existing
+// records are never changed: a name that UniFi already holds with other
+// targets, or where a CNAME would share its name with other records, is
+// reported as a conflict.
+func NewPlan(current []records.Record, sources ...*Parsed) *Plan {
+	result := &Plan{locations: make(map[string][]string)}
+	merged := make(map[string]*records.Record)
+
+	var order []string
+
+	for _, parsed := range sources {
+		result.Skipped = append(result.Skipped, parsed.Skipped...)
+
+		for _, entry := range parsed.Entries {
+			key := entry.Record.Name + "/" + entry.Record.Type
+			result.locations[key] = append(result.locations[key], entry.Location)
+
+			existing, ok := merged[key]
+			if !ok {
+				record := entry.Record
+				record.Targets = slices.Clone(record.Targets)
+				merged[key] = &record
+				order = append(order, key)
+
+				continue
+			}
+
+			for _, target := range entry.Record.Targets {
+				if !slices.Contains(existing.Targets, target) {
+					existing.Targets = append(existing.Targets, target)
+				}
+			}
+
+			existing.TTL = max(existing.TTL, entry.Record.TTL)
+		}
+	}
+
+	desired := make([]records.Record, 0, len(order))
+	for _, key := range order {
+		record := *merged[key]
+		slices.Sort(record.Targets)
+		desired = append(desired, record)
+	}
+
+	desiredTypes := typesByName(desired)
+	currentTypes := typesByName(current)
+
+	currentByKey := make(map[string]records.Record, len(current))
+	for _, record := range current {
+		currentByKey[record.Name+"/"+record.Type] = record
+	}
+
+	for _, record := range desired {
+		existing, exists := currentByKey[record.Name+"/"+record.Type]
+
+		switch {
+		case record.Type == endpoint.RecordTypeCNAME && len(record.Targets) > 1:
+			result.conflict(record, "the sources name several CNAME targets")
+		case cnameClash(record.Type, desiredTypes[record.Name]):
+			result.conflict(record, "the sources mix a CNAME with other records for this name")
+		case exists && slices.Equal(existing.Targets, record.Targets):
+			result.Existing = append(result.Existing, record)
+		case exists:
+			result.conflict(record, "UniFi has "+describe(existing))
+		case cnameClash(record.Type, currentTypes[record.Name]):
+			result.conflict(record, "UniFi has other records for this name: "+strings.Join(currentTypes[record.Name], ", "))
+		default:
+			result.Create = append(result.Create, record)
+		}
+	}
+
+	return result
+}
+
+// ProviderChanges returns the creations to pass to the provider's ApplyChanges.
+func (p *Plan) ProviderChanges() *plan.Changes {
+	return &plan.Changes{Create: records.Endpoints(p.Create)}
+}
+
+// Write prints the plan, one line per record or skipped line.
+func (p *Plan) Write(w io.Writer) error {
+	var lines []string
+
+	for _, record := range p.Create {
+		lines = append(lines, "+ "+describe(record)+p.from(record))
+	}
+
+	for _, record := range p.Existing {
+		lines = append(lines, "= "+describe(record)+" already exists")
+	}
+
+	for _, conflict := range p.Conflicts {
+		lines = append(lines, "! "+describe(conflict.Record)+": "+conflict.Reason+" ("+strings.Join(conflict.Locations, ", ")+")")
+	}
+
+	for _, skipped := range p.Skipped {
+		lines = append(lines, "? "+skipped.Location+": "+skipped.Reason)
+	}
+
+	lines = append(lines, fmt.Sprintf("%d to create, %d existing, %d conflicts, %d skipped",
+		len(p.Create), len(p.Existing), len(p.Conflicts), len(p.Skipped)))
+
+	for _, line := range lines {
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return errors.Wrap(err, "failed to write migration plan")
+		}
+	}
+
+	return nil
+}
+
+// conflict records a record that is not created.
+func (p *Plan) conflict(record records.Record, reason string) {
+	p.Conflicts = append(p.Conflicts, Conflict{
+		Record:    record,
+		Locations: p.locations[record.Name+"/"+record.Type],
+		Reason:    reason,
+	})
+}
+
+// from formats where a record was read.
+func (p *Plan) from(record records.Record) string {
+	return " (" + strings.Join(p.locations[record.Name+"/"+record.Type], ", ") + ")"
+}
+
+// cnameClash reports whether a record of recordType cannot share its name
+// with records of the other types: a CNAME must be alone.
+func cnameClash(recordType string, types []string) bool {
+	for _, other := range types {
+		if other != recordType && (recordType == endpoint.RecordTypeCNAME || other == endpoint.RecordTypeCNAME) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// typesByName lists the record types present for each name.
+func typesByName(list []records.Record) map[string][]string {
+	result := make(map[string][]string)
+
+	for _, record := range list {
+		result[record.Name] = append(result[record.Name], record.Type)
+	}
+
+	return result
+}
+
+// describe formats a record for the plan.
+func describe(record records.Record) string {
+	return record.Name + " " + record.Type + " [" + strings.Join(record.Targets, ", ") + "]"
+}
@@ -0,0 +1,62 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+func TestApplyChanges_BeforeApply(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.Anything).
+		Return(&unifi.DNSRecord{UnderscoreId: "new-record-id"}, nil)
+
+	calls := 0
+	provider := New(mockClient, "default", endpoint.DomainFilter{},
+		WithBeforeApply(func(context.Context) { calls++ }))
+
+	require.NoError(t, provider.ApplyChanges(context.Background(), &plan.Changes{}))
+	assert.Equal(t, 0, calls, "empty changes do not reach UniFi")
+
+	require.NoError(t, provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint(testNewDNSName, endpoint.RecordTypeA, testNewTarget)},
+	}))
+	assert.Equal(t, 1, calls)
+}
+
+func TestApplyChanges_AfterApply(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.Anything).
+		Return(&unifi.DNSRecord{UnderscoreId: "new-record-id"}, nil)
+
+	var calls []string
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{},
+		WithBeforeApply(func(context.Context) { calls = append(calls, "before") }),
+		WithAfterApply(func(_ context.Context, changes *plan.Changes, err error) {
+			assert.Len(t, changes.Create, 1)
+			assert.NoError(t, err)
+
+			calls = append(calls, "after")
+		}))
+
+	require.NoError(t, provider.ApplyChanges(context.Background(), &plan.Changes{}))
+	assert.Empty(t, calls, "empty changes do not reach UniFi")
+
+	require.NoError(t, provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint(testNewDNSName, endpoint.RecordTypeA, testNewTarget)},
+	}))
+	assert.Equal(t, []string{"before", "after"}, calls)
+}
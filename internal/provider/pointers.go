@@ -0,0 +1,93 @@
+package provider
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/ptr"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// SyncPointers makes the reverse records match the A and AAAA records within the
+// domain filter as they are stored in UniFi, for records that existed before
+// reverse records were enabled or changed while the webhook was not running.
+func (p *UniFiProvider) SyncPointers(ctx context.Context) error {
+	if p.pointers == nil {
+		return nil
+	}
+
+	records, err := p.client.ListDNSRecords(ctx, p.site)
+	if err != nil {
+		return errors.Wrap(err, "failed to list DNS records for PTR records")
+	}
+
+	domainFilter := p.filter()
+
+	// Reverse records point to names as stored in UniFi, which resolve on the LAN
+	owns := func(hostname string) bool {
+		return domainFilter.Match(p.rewrites.ExternalName(hostname))
+	}
+
+	var want []ptr.Pointer
+
+	for _, record := range records {
+		isAddress := record.RecordType == unifi.DNSRecordRecordTypeA || record.RecordType == unifi.DNSRecordRecordTypeAAAA
+		if !isAddress || !owns(record.Key) {
+			continue
+		}
+
+		address, parseErr := netip.ParseAddr(record.Value)
+		if parseErr != nil {
+			continue
+		}
+
+		want = append(want, ptr.Pointer{Address: address, Hostname: record.Key})
+	}
+
+	//nolint:wrapcheck // The manager wraps its errors
+	return p.pointers.Sync(ctx, want, owns)
+}
+
+// updatePointers removes the reverse records of deleted and replaced A and AAAA
+// records and adds those of created ones. Failures are logged and counted rather
+// than failing the changes, which UniFi already applied.
+func (p *UniFiProvider) updatePointers(ctx context.Context, changes *plan.Changes) {
+	if p.pointers == nil {
+		return
+	}
+
+	err := p.pointers.Update(ctx,
+		p.pointersOf(slices.Concat(changes.Delete, changes.UpdateOld)),
+		p.pointersOf(slices.Concat(changes.Create, changes.UpdateNew)))
+	if err != nil {
+		slog.ErrorContext(ctx, "failed to update PTR records", "error", err)
+	}
+}
+
+// pointersOf returns the reverse records of A and AAAA endpoints, with names and
+// addresses as stored in UniFi.
+func (p *UniFiProvider) pointersOf(endpoints []*endpoint.Endpoint) []ptr.Pointer {
+	var pointers []ptr.Pointer
+
+	for _, endpointItem := range endpoints {
+		if endpointItem.RecordType != endpoint.RecordTypeA && endpointItem.RecordType != endpoint.RecordTypeAAAA {
+			continue
+		}
+
+		hostname := p.rewrites.UniFiName(endpointItem.DNSName)
+
+		for _, target := range endpointItem.Targets {
+			address, err := netip.ParseAddr(p.targets.UniFiTarget(endpointItem.RecordType, target))
+			if err == nil {
+				pointers = append(pointers, ptr.Pointer{Address: address, Hostname: hostname})
+			}
+		}
+	}
+
+	return pointers
+}
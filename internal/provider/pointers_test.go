@@ -0,0 +1,95 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/ptr"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+// isPointer matches the input of a PTR record for key pointing to value.
+func isPointer(key, value string) func(*unifi.DNSRecordInput) bool {
+	return func(input *unifi.DNSRecordInput) bool {
+		return input.Key == key && string(input.RecordType) == "PTR" && input.Value == value
+	}
+}
+
+func TestProvider_Pointers(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+
+	pointers, err := ptr.New([]string{"1.168.192.in-addr.arpa"}, ptr.NewUniFiSink(mockClient, "default"))
+	require.NoError(t, err)
+
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("old.home.lan", "192.168.1.10", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("10.1.168.192.in-addr.arpa", "old.home.lan", "PTR"),
+	}, nil)
+	mockClient.On("DeleteDNSRecord", mock.Anything, unifi.Site("default"), "test-id-old.home.lan").Return(nil).Once()
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.MatchedBy(func(input *unifi.DNSRecordInput) bool {
+		return input.RecordType == unifi.DNSRecordInputRecordTypeA
+	})).Return(&unifi.DNSRecord{}, nil).Times(3)
+
+	// Pointers of deleted records are removed, those of created records in the zones added
+	mockClient.On("DeleteDNSRecord", mock.Anything, unifi.Site("default"), "test-id-10.1.168.192.in-addr.arpa").Return(nil).Once()
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"),
+		mock.MatchedBy(isPointer("50.1.168.192.in-addr.arpa", "app.home.lan"))).Return(&unifi.DNSRecord{}, nil).Once()
+
+	// A failing sink is logged, the changes UniFi applied still succeed
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"),
+		mock.MatchedBy(isPointer("51.1.168.192.in-addr.arpa", "web.home.lan"))).Return(nil, errors.New("unsupported record type")).Once()
+
+	provider := New(mockClient, "default", *endpoint.NewDomainFilter([]string{"home.lan"}), WithPointers(pointers))
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "app.home.lan", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.50"}},
+			{DNSName: "web.home.lan", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.51"}},
+			{DNSName: "vpn.home.lan", RecordType: endpoint.RecordTypeA, Targets: []string{"10.8.0.1"}},
+		},
+		Delete: []*endpoint.Endpoint{
+			{DNSName: "old.home.lan", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.10"}},
+		},
+	})
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNumberOfCalls(t, "CreateDNSRecord", 5)
+}
+
+func TestProvider_SyncPointers(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+
+	pointers, err := ptr.New([]string{"1.168.192.in-addr.arpa"}, ptr.NewUniFiSink(mockClient, "default"))
+	require.NoError(t, err)
+
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("app.home.lan", "192.168.1.50", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("vpn.home.lan", "10.8.0.1", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("printer.other.lan", "192.168.1.20", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("10.1.168.192.in-addr.arpa", "old.home.lan", "PTR"),
+		createMockDNSRecord("20.1.168.192.in-addr.arpa", "printer.other.lan", "PTR"),
+	}, nil)
+
+	// Only addresses in the reverse zones get pointers, and only pointers to managed names are removed
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"),
+		mock.MatchedBy(isPointer("50.1.168.192.in-addr.arpa", "app.home.lan"))).Return(&unifi.DNSRecord{}, nil).Once()
+	mockClient.On("DeleteDNSRecord", mock.Anything, unifi.Site("default"), "test-id-10.1.168.192.in-addr.arpa").Return(nil).Once()
+
+	provider := New(mockClient, "default", *endpoint.NewDomainFilter([]string{"home.lan"}), WithPointers(pointers))
+
+	require.NoError(t, provider.SyncPointers(context.Background()))
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNumberOfCalls(t, "CreateDNSRecord", 1)
+	mockClient.AssertNumberOfCalls(t, "DeleteDNSRecord", 1)
+}
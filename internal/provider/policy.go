@@ -0,0 +1,345 @@
+package provider
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Reasons a target violates the target policy, besides the address classes.
+const (
+	// ReasonDenied is a target within a denied CIDR.
+	ReasonDenied = "denied"
+	// ReasonNotAllowed is a target outside the allowed ranges.
+	ReasonNotAllowed = "not-allowed"
+)
+
+// addressClasses are the named ranges policies can allow or deny.
+//
+//nolint:gochecknoglobals // Read-only lookup table
+var addressClasses = map[string]func(netip.Addr) bool{
+	"public": func(address netip.Addr) bool {
+		return address.IsGlobalUnicast() && !address.IsPrivate()
+	},
+	"private":     netip.Addr.IsPrivate,
+	"loopback":    netip.Addr.IsLoopback,
+	"link-local":  func(address netip.Addr) bool { return address.IsLinkLocalUnicast() || address.IsLinkLocalMulticast() },
+	"multicast":   netip.Addr.IsMulticast,
+	"unspecified": netip.Addr.IsUnspecified,
+}
+
+// TargetPolicy restricts the addresses A and AAAA records may point to, so a
+// mis-annotated Service cannot point LAN names at public or loopback addresses.
+// A nil *TargetPolicy allows every target.
+type TargetPolicy struct {
+	rules []policyRule
+	drop  bool
+}
+
+// policyRule allows or denies ranges for some record types and domains.
+type policyRule struct {
+	types  []string
+	domain string
+	allow  bool
+	ranges []addressRange
+}
+
+// addressRange is a CIDR or an address class.
+type addressRange struct {
+	class  string
+	prefix netip.Prefix
+}
+
+func (r addressRange) contains(address netip.Addr) bool {
+	if r.class != "" {
+		return addressClasses[r.class](address)
+	}
+
+	return r.prefix.Contains(address)
+}
+
+// NewTargetPolicy creates a target policy. allow and deny are CIDRs or address
+// classes (public, private, loopback, link-local, multicast, unspecified) that apply
+// to all records. rules apply to some of them, as "<types> <domain> allow|deny <ranges>",
+// for example "A,AAAA dmz.example.com allow 10.20.0.0/16", with * for any type or domain.
+// Violating changes fail the batch they are in, unless drop is set.
+func NewTargetPolicy(allow, deny, rules []string, drop bool) (*TargetPolicy, error) {
+	policy := &TargetPolicy{drop: drop}
+
+	for _, global := range []struct {
+		ranges []string
+		allow  bool
+	}{{ranges: deny}, {ranges: allow, allow: true}} {
+		if len(global.ranges) == 0 {
+			continue
+		}
+
+		ranges, err := parseRanges(global.ranges)
+		if err != nil {
+			return nil, err
+		}
+
+		policy.rules = append(policy.rules, policyRule{allow: global.allow, ranges: ranges})
+	}
+
+	for _, rule := range rules {
+		parsed, err := parsePolicyRule(rule)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid target policy rule %q", rule)
+		}
+
+		policy.rules = append(policy.rules, parsed)
+	}
+
+	return policy, nil
+}
+
+// parsePolicyRule parses "<types> <domain> allow|deny <ranges>".
+func parsePolicyRule(rule string) (policyRule, error) {
+	fields := strings.Fields(rule)
+	if len(fields) != 4 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return policyRule{}, errors.New("expected <types> <domain> allow|deny <ranges>")
+	}
+
+	var parsed policyRule
+
+	if fields[0] != "*" {
+		for recordType := range strings.SplitSeq(strings.ToUpper(fields[0]), ",") {
+			if recordType != endpoint.RecordTypeA && recordType != endpoint.RecordTypeAAAA {
+				//nolint:wrapcheck // Creating new error, not wrapping
+				return policyRule{}, errors.Newf("record type %s has no address targets", recordType)
+			}
+
+			parsed.types = append(parsed.types, recordType)
+		}
+	}
+
+	if fields[1] != "*" {
+		parsed.domain = normalizeName(fields[1])
+	}
+
+	switch fields[2] {
+	case "allow":
+		parsed.allow = true
+	case "deny":
+	default:
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return policyRule{}, errors.Newf("expected allow or deny, got %q", fields[2])
+	}
+
+	ranges, err := parseRanges(strings.Split(fields[3], ","))
+	if err != nil {
+		return policyRule{}, err
+	}
+
+	parsed.ranges = ranges
+
+	return parsed, nil
+}
+
+// parseRanges parses CIDRs, addresses and address classes.
+func parseRanges(values []string) ([]addressRange, error) {
+	ranges := make([]addressRange, 0, len(values))
+
+	for _, value := range values {
+		value = strings.ToLower(strings.TrimSpace(value))
+
+		if _, ok := addressClasses[value]; ok {
+			ranges = append(ranges, addressRange{class: value})
+
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			address, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				//nolint:wrapcheck // Creating new error, not wrapping
+				return nil, errors.Newf("%q is neither a CIDR nor an address class", value)
+			}
+
+			prefix = netip.PrefixFrom(address, address.BitLen())
+		}
+
+		ranges = append(ranges, addressRange{prefix: prefix.Masked()})
+	}
+
+	return ranges, nil
+}
+
+// Check returns why target violates the policy for a record of recordType named
+// name, or an empty string if it does not. Only A and AAAA targets are checked.
+func (t *TargetPolicy) Check(name, recordType, target string) string {
+	if t == nil || (recordType != endpoint.RecordTypeA && recordType != endpoint.RecordTypeAAAA) {
+		return ""
+	}
+
+	address, err := netip.ParseAddr(target)
+	if err != nil {
+		return ""
+	}
+
+	address = address.Unmap()
+	name = normalizeName(name)
+
+	allowRules, allowed := 0, false
+
+	for _, rule := range t.rules {
+		if !rule.applies(name, recordType) {
+			continue
+		}
+
+		for _, candidate := range rule.ranges {
+			if !candidate.contains(address) {
+				continue
+			}
+
+			if !rule.allow {
+				return cmp.Or(candidate.class, ReasonDenied)
+			}
+
+			allowed = true
+		}
+
+		if rule.allow {
+			allowRules++
+		}
+	}
+
+	if allowRules > 0 && !allowed {
+		return ReasonNotAllowed
+	}
+
+	return ""
+}
+
+// applies reports whether the rule covers records of recordType named name.
+func (r policyRule) applies(name, recordType string) bool {
+	if len(r.types) > 0 && !slices.Contains(r.types, recordType) {
+		return false
+	}
+
+	return r.domain == "" || name == r.domain || strings.HasSuffix(name, "."+r.domain)
+}
+
+// violation returns the first target of endpointItem that violates policy, and why.
+func (p *UniFiProvider) violation(policy *TargetPolicy, endpointItem *endpoint.Endpoint) (string, string) {
+	for _, target := range endpointItem.Targets {
+		// Targets are checked as stored in UniFi, after rewriting
+		reason := policy.Check(endpointItem.DNSName, endpointItem.RecordType,
+			p.targets.UniFiTarget(endpointItem.RecordType, target))
+		if reason != "" {
+			return target, reason
+		}
+	}
+
+	return "", ""
+}
+
+// filterPolicy returns a copy of changes without creates and updates whose targets
+// violate the target policy, logging and counting each of them, and an error naming
+// them unless the policy drops violations. The rest of the batch is still applied,
+// so the error only makes external-dns retry the violating endpoints.
+// An update is rejected as a whole: every UpdateOld and UpdateNew endpoint of a
+// rejected name is dropped, so no old records are deleted without replacements.
+func (p *UniFiProvider) filterPolicy(ctx context.Context, changes *plan.Changes) (*plan.Changes, error) {
+	policy := p.policy()
+	if policy == nil {
+		return changes, nil
+	}
+
+	var rejected []string
+
+	reject := func(endpointItem *endpoint.Endpoint, operation string) bool {
+		target, reason := p.violation(policy, endpointItem)
+		if reason == "" {
+			return false
+		}
+
+		slog.WarnContext(ctx, "rejecting DNS record violating the target policy",
+			"operation", operation,
+			"name", endpointItem.DNSName,
+			"type", endpointItem.RecordType,
+			"target", target,
+			"reason", reason)
+
+		dnsmetrics.TargetPolicyRejections.WithLabelValues(operation, reason).Inc()
+
+		rejected = append(rejected, endpointItem.DNSName+" "+endpointItem.RecordType+" "+target+" ("+reason+")")
+
+		return true
+	}
+
+	filtered := &plan.Changes{Delete: changes.Delete}
+
+	for _, endpointItem := range changes.Create {
+		if !reject(endpointItem, "create") {
+			filtered.Create = append(filtered.Create, endpointItem)
+		}
+	}
+
+	// UpdateOld and UpdateNew need not pair up by index, so updates are matched by name
+	rejectedNames := make(map[string]bool)
+
+	for _, endpointItem := range changes.UpdateNew {
+		if reject(endpointItem, "update") {
+			rejectedNames[normalizeName(endpointItem.DNSName)] = true
+		}
+	}
+
+	for _, endpointItem := range changes.UpdateOld {
+		if !rejectedNames[normalizeName(endpointItem.DNSName)] {
+			filtered.UpdateOld = append(filtered.UpdateOld, endpointItem)
+		}
+	}
+
+	for _, endpointItem := range changes.UpdateNew {
+		if !rejectedNames[normalizeName(endpointItem.DNSName)] {
+			filtered.UpdateNew = append(filtered.UpdateNew, endpointItem)
+		}
+	}
+
+	if len(rejected) == 0 || policy.drop {
+		return filtered, nil
+	}
+
+	//nolint:wrapcheck // Creating new error, not wrapping
+	return filtered, errors.Newf("target policy rejected %s", strings.Join(rejected, ", "))
+}
+
+// flagViolations logs endpoints whose targets violate the target policy and counts
+// them by reason. The endpoints are returned unchanged, since external-dns would
+// see any label it did not set as a change to apply.
+func (p *UniFiProvider) flagViolations(ctx context.Context, endpoints []*endpoint.Endpoint) {
+	dnsmetrics.TargetPolicyViolations.Reset()
+
+	policy := p.policy()
+	if policy == nil {
+		return
+	}
+
+	for _, endpointItem := range endpoints {
+		// Records are read with their original targets, so rewriting them again yields the stored ones
+		target, reason := p.violation(policy, endpointItem)
+		if reason == "" {
+			continue
+		}
+
+		slog.WarnContext(ctx, "DNS record violates the target policy",
+			"name", endpointItem.DNSName,
+			"type", endpointItem.RecordType,
+			"target", target,
+			"reason", reason)
+
+		dnsmetrics.TargetPolicyViolations.WithLabelValues(reason).Inc()
+	}
+}
@@ -0,0 +1,178 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+func TestNewTargetPolicy_Invalid(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewTargetPolicy(nil, []string{"internet"}, nil, false)
+	require.Error(t, err)
+
+	for _, rule := range []string{
+		"A example.com deny",
+		"CNAME example.com deny public",
+		"A example.com block public",
+		"A example.com allow 10.0.0.0/33",
+	} {
+		_, err := NewTargetPolicy(nil, nil, []string{rule}, false)
+		require.Error(t, err, rule)
+	}
+}
+
+func TestTargetPolicy_Check(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewTargetPolicy(nil, []string{"public", "loopback", "link-local", "192.168.99.0/24"}, []string{
+		"A dmz.example.com allow 10.20.0.0/16",
+		"AAAA * deny fd00:dead::/32",
+	}, false)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		recordType string
+		target     string
+		expected   string
+	}{
+		{name: "app.example.com", recordType: endpoint.RecordTypeA, target: "192.168.1.10", expected: ""},
+		{name: "app.example.com", recordType: endpoint.RecordTypeA, target: "203.0.113.10", expected: "public"},
+		{name: "app.example.com", recordType: endpoint.RecordTypeA, target: "127.0.0.1", expected: "loopback"},
+		{name: "app.example.com", recordType: endpoint.RecordTypeAAAA, target: "fe80::1", expected: "link-local"},
+		{name: "app.example.com", recordType: endpoint.RecordTypeA, target: "192.168.99.5", expected: ReasonDenied},
+		{name: "app.example.com", recordType: endpoint.RecordTypeAAAA, target: "fd00:dead::1", expected: ReasonDenied},
+		{name: "web.dmz.example.com", recordType: endpoint.RecordTypeA, target: "10.20.1.1", expected: ""},
+		{name: "web.dmz.example.com", recordType: endpoint.RecordTypeA, target: "192.168.1.10", expected: ReasonNotAllowed},
+		{name: "web.dmz.example.com", recordType: endpoint.RecordTypeAAAA, target: "fd00::1", expected: ""},
+		{name: "app.example.com", recordType: endpoint.RecordTypeCNAME, target: "127.0.0.1", expected: ""},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, policy.Check(tt.name, tt.recordType, tt.target), "%s %s %s", tt.name, tt.recordType, tt.target)
+	}
+
+	var none *TargetPolicy
+
+	assert.Empty(t, none.Check("app.example.com", endpoint.RecordTypeA, "127.0.0.1"))
+}
+
+func TestProvider_TargetPolicy(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewTargetPolicy(nil, []string{"public"}, nil, true)
+	require.NoError(t, err)
+
+	targets, err := NewTargetRewrites([]string{"203.0.113.0/24=192.168.1.50"}, "")
+	require.NoError(t, err)
+
+	mockClient := new(MockNetworkClient)
+
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("leak.example.com", "198.51.100.1", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("nas.example.com", "192.168.1.10", unifi.DNSRecordRecordTypeA),
+	}, nil)
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.MatchedBy(func(input *unifi.DNSRecordInput) bool {
+		return input.Key == "app.example.com" && input.Value == "192.168.1.50"
+	})).Return(&unifi.DNSRecord{}, nil).Once()
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{},
+		WithTargetPolicy(policy), WithTargetRewrites(targets))
+
+	// Existing violations are logged and counted, not hidden or labeled
+	endpoints, err := provider.Records(context.Background())
+	require.NoError(t, err)
+	require.Len(t, endpoints, 2)
+	assert.Empty(t, endpoints[0].Labels)
+
+	// Violating changes are dropped without failing the batch when asked to, the rest are applied.
+	// The public address of app is rewritten to a private one, so it is allowed.
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "app.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"203.0.113.10"}},
+			{DNSName: "bad.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"198.51.100.2"}},
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			{DNSName: "nas.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.10"}},
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			{DNSName: "nas.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"198.51.100.3"}},
+		},
+	})
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNumberOfCalls(t, "CreateDNSRecord", 1)
+	mockClient.AssertNotCalled(t, "DeleteDNSRecord", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestProvider_TargetPolicyFailsBatch(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewTargetPolicy(nil, []string{"public"}, nil, false)
+	require.NoError(t, err)
+
+	mockClient := new(MockNetworkClient)
+
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.MatchedBy(func(input *unifi.DNSRecordInput) bool {
+		return input.Key == "app.example.com" && input.Value == "192.168.1.50"
+	})).Return(&unifi.DNSRecord{}, nil).Once()
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithTargetPolicy(policy))
+
+	// The allowed create is applied, the violating one fails the batch by name
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "app.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.50"}},
+			{DNSName: "bad.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"198.51.100.2"}},
+		},
+	})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "bad.example.com A 198.51.100.2")
+	assert.NotContains(t, err.Error(), "app.example.com")
+	mockClient.AssertExpectations(t)
+
+	// A batch with only violations fails as well
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "bad.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"198.51.100.2"}},
+		},
+	})
+	require.Error(t, err)
+	mockClient.AssertNumberOfCalls(t, "CreateDNSRecord", 1)
+}
+
+func TestProvider_TargetPolicyUnpairedUpdates(t *testing.T) {
+	t.Parallel()
+
+	policy, err := NewTargetPolicy(nil, []string{"public"}, nil, true)
+	require.NoError(t, err)
+
+	mockClient := new(MockNetworkClient)
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithTargetPolicy(policy))
+
+	// Without pairs, both sides of a rejected name are dropped so its old records are kept
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			{DNSName: "nas.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.10"}},
+			{DNSName: "nas.example.com", RecordType: endpoint.RecordTypeAAAA, Targets: []string{"fd00::10"}},
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			{DNSName: "nas.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"198.51.100.3"}},
+		},
+	})
+	require.NoError(t, err)
+	mockClient.AssertNotCalled(t, "ListDNSRecords", mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "DeleteDNSRecord", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "CreateDNSRecord", mock.Anything, mock.Anything, mock.Anything)
+}
@@ -0,0 +1,215 @@
+package provider
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Protection describes DNS records that the webhook must never modify or delete.
+// Records are matched by name (glob or regular expression) or by UniFi record ID.
+// A nil *Protection protects nothing.
+type Protection struct {
+	globs   []string
+	regexes []*regexp.Regexp
+	ids     map[string]struct{}
+	hide    bool
+}
+
+// NewProtection creates a Protection from glob patterns, regular expressions and record IDs.
+// When hide is true, protected records are also omitted from Records.
+func NewProtection(globs, regexNames, recordIDs []string, hide bool) (*Protection, error) {
+	protection := &Protection{
+		globs:   make([]string, 0, len(globs)),
+		regexes: make([]*regexp.Regexp, 0, len(regexNames)),
+		ids:     make(map[string]struct{}, len(recordIDs)),
+		hide:    hide,
+	}
+
+	for _, glob := range globs {
+		normalized := normalizeName(glob)
+
+		// Validate the pattern once so matching never fails at runtime
+		_, err := path.Match(normalized, "")
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid protected name pattern %q", glob)
+		}
+
+		protection.globs = append(protection.globs, normalized)
+	}
+
+	// Names are matched lowercased, so the expressions ignore case like DNS does
+	for _, expr := range regexNames {
+		compiled, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid protected name regex %q", expr)
+		}
+
+		protection.regexes = append(protection.regexes, compiled)
+	}
+
+	for _, id := range recordIDs {
+		protection.ids[id] = struct{}{}
+	}
+
+	return protection, nil
+}
+
+// MatchName reports whether the DNS name is protected.
+func (p *Protection) MatchName(name string) bool {
+	if p == nil {
+		return false
+	}
+
+	normalized := normalizeName(name)
+
+	for _, glob := range p.globs {
+		if matched, _ := path.Match(glob, normalized); matched {
+			return true
+		}
+	}
+
+	for _, expr := range p.regexes {
+		if expr.MatchString(normalized) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MatchRecord reports whether the UniFi record is protected by name or by ID.
+func (p *Protection) MatchRecord(record *unifi.DNSRecord) bool {
+	if p == nil {
+		return false
+	}
+
+	if _, ok := p.ids[record.UnderscoreId]; ok {
+		return true
+	}
+
+	return p.MatchName(record.Key)
+}
+
+// Hidden reports whether protected records should be omitted from Records.
+func (p *Protection) Hidden() bool {
+	return p != nil && p.hide
+}
+
+// filterProtected returns a copy of changes without endpoints whose names are protected.
+// UpdateOld and UpdateNew are filtered pairwise so an update is either applied or blocked as a whole.
+// Since an update deletes every record of the old name before creating the new ones, it is also
+// blocked when a record of the old name is protected by ID, which would otherwise get a duplicate.
+func (p *UniFiProvider) filterProtected(ctx context.Context, changes *plan.Changes) (*plan.Changes, error) {
+	protection := p.rules()
+	if protection == nil {
+		return changes, nil
+	}
+
+	protectedIDs, err := p.namesProtectedByID(ctx, protection, changes.UpdateOld)
+	if err != nil {
+		return nil, err
+	}
+
+	filtered := &plan.Changes{
+		Create: p.dropProtected(ctx, protection, nil, changes.Create, "create"),
+		Delete: p.dropProtected(ctx, protection, nil, changes.Delete, "delete"),
+	}
+
+	if len(changes.UpdateOld) != len(changes.UpdateNew) {
+		filtered.UpdateOld = p.dropProtected(ctx, protection, protectedIDs, changes.UpdateOld, "update")
+		filtered.UpdateNew = p.dropProtected(ctx, protection, protectedIDs, changes.UpdateNew, "update")
+
+		return filtered, nil
+	}
+
+	for idx, newEndpoint := range changes.UpdateNew {
+		oldEndpoint := changes.UpdateOld[idx]
+		if p.protected(protection, protectedIDs, oldEndpoint) || p.protected(protection, protectedIDs, newEndpoint) {
+			p.recordBlocked(ctx, newEndpoint, "update")
+
+			continue
+		}
+
+		filtered.UpdateOld = append(filtered.UpdateOld, oldEndpoint)
+		filtered.UpdateNew = append(filtered.UpdateNew, newEndpoint)
+	}
+
+	return filtered, nil
+}
+
+// namesProtectedByID returns the names, as stored in UniFi, that endpoints share with
+// records protected by ID. UniFi is only asked when such protections are configured.
+func (p *UniFiProvider) namesProtectedByID(ctx context.Context, protection *Protection, endpoints []*endpoint.Endpoint) (map[string]bool, error) {
+	if len(protection.ids) == 0 || len(endpoints) == 0 {
+		return nil, nil //nolint:nilnil // No names are protected by ID
+	}
+
+	records, err := p.client.ListDNSRecords(ctx, p.site)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list DNS records for protection")
+	}
+
+	names := make(map[string]bool)
+
+	for _, record := range records {
+		if _, ok := protection.ids[record.UnderscoreId]; ok {
+			names[normalizeName(record.Key)] = true
+		}
+	}
+
+	return names, nil
+}
+
+// protected reports whether an endpoint's name, as stored in UniFi, is protected
+// by name or shared with a record protected by ID.
+func (p *UniFiProvider) protected(protection *Protection, protectedIDs map[string]bool, endpointItem *endpoint.Endpoint) bool {
+	name := p.rewrites.UniFiName(endpointItem.DNSName)
+
+	return protection.MatchName(name) || protectedIDs[normalizeName(name)]
+}
+
+// dropProtected removes endpoints with protected names, logging and counting each one.
+func (p *UniFiProvider) dropProtected(ctx context.Context, protection *Protection, protectedIDs map[string]bool, endpoints []*endpoint.Endpoint, operation string) []*endpoint.Endpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	allowed := make([]*endpoint.Endpoint, 0, len(endpoints))
+
+	for _, endpointItem := range endpoints {
+		// Protected names are names as stored in UniFi
+		if p.protected(protection, protectedIDs, endpointItem) {
+			p.recordBlocked(ctx, endpointItem, operation)
+
+			continue
+		}
+
+		allowed = append(allowed, endpointItem)
+	}
+
+	return allowed
+}
+
+// recordBlocked logs and counts a change refused because of protection rules.
+func (p *UniFiProvider) recordBlocked(ctx context.Context, endpointItem *endpoint.Endpoint, operation string) {
+	slog.WarnContext(ctx, "refusing to modify protected DNS record",
+		"operation", operation,
+		"name", endpointItem.DNSName,
+		"type", endpointItem.RecordType)
+
+	dnsmetrics.ProtectedRecordsBlocked.WithLabelValues(operation).Inc()
+}
+
+// normalizeName lowercases a DNS name and strips the trailing root dot.
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
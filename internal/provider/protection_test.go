@@ -0,0 +1,200 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+func TestNewProtection_InvalidPatterns(t *testing.T) {
+	t.Parallel()
+
+	_, err := NewProtection([]string{"[router"}, nil, nil, false)
+	require.Error(t, err)
+
+	_, err = NewProtection(nil, []string{"(nas"}, nil, false)
+	require.Error(t, err)
+}
+
+func TestProtection_Match(t *testing.T) {
+	t.Parallel()
+
+	protection, err := NewProtection(
+		[]string{"*.infra.example.com", "router.example.com"},
+		[]string{`^nas-\d+\.example\.com$`},
+		[]string{"printer-id"},
+		false,
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		name     string
+		record   unifi.DNSRecord
+		expected bool
+	}{
+		{
+			name:     "exact glob",
+			record:   unifi.DNSRecord{Key: "Router.example.com.", UnderscoreId: "a"},
+			expected: true,
+		},
+		{
+			name:     "wildcard glob",
+			record:   unifi.DNSRecord{Key: "switch.infra.example.com", UnderscoreId: "b"},
+			expected: true,
+		},
+		{
+			name:     "regex",
+			record:   unifi.DNSRecord{Key: "nas-01.example.com", UnderscoreId: "c"},
+			expected: true,
+		},
+		{
+			name:     "record ID",
+			record:   unifi.DNSRecord{Key: "printer.example.com", UnderscoreId: "printer-id"},
+			expected: true,
+		},
+		{
+			name:     "unprotected",
+			record:   unifi.DNSRecord{Key: "app.example.com", UnderscoreId: "d"},
+			expected: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, protection.MatchRecord(&tt.record))
+		})
+	}
+
+	var nilProtection *Protection
+
+	assert.False(t, nilProtection.MatchName("router.example.com"))
+	assert.False(t, nilProtection.Hidden())
+}
+
+func TestProtection_MatchRegexIgnoresCase(t *testing.T) {
+	t.Parallel()
+
+	protection, err := NewProtection(nil, []string{`^NAS\.Example\.com$`}, nil, false)
+	require.NoError(t, err)
+
+	assert.True(t, protection.MatchName("nas.example.com"))
+	assert.True(t, protection.MatchName("NAS.example.com."))
+	assert.False(t, protection.MatchName("nas2.example.com"))
+}
+
+func TestRecords_HidesProtected(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+
+	mockRecords := []unifi.DNSRecord{
+		createMockDNSRecord("router.example.com", "192.168.1.1", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("app.example.com", "192.168.1.2", unifi.DNSRecordRecordTypeA),
+	}
+
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).
+		Return(mockRecords, nil)
+
+	protection, err := NewProtection([]string{"router.example.com"}, nil, nil, true)
+	require.NoError(t, err)
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithProtection(protection))
+	endpoints, err := provider.Records(context.Background())
+
+	require.NoError(t, err)
+	assert.Len(t, endpoints, 1)
+	assert.Equal(t, "app.example.com", endpoints[0].DNSName)
+	mockClient.AssertExpectations(t)
+}
+
+func TestApplyChanges_BlocksProtected(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+
+	existingRecords := []unifi.DNSRecord{
+		createMockDNSRecord("router.example.com", "192.168.1.1", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("shared.example.com", "192.168.1.5", unifi.DNSRecordRecordTypeA),
+	}
+
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).
+		Return(existingRecords, nil)
+
+	protection, err := NewProtection(
+		[]string{"router.example.com"},
+		nil,
+		[]string{"test-id-shared.example.com"},
+		false,
+	)
+	require.NoError(t, err)
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithProtection(protection))
+
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "router.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"10.0.0.1"}},
+		},
+		UpdateOld: []*endpoint.Endpoint{
+			{DNSName: "router.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.1"}},
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			{DNSName: "router.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"10.0.0.1"}},
+		},
+		Delete: []*endpoint.Endpoint{
+			{DNSName: "shared.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.5"}},
+		},
+	}
+
+	err = provider.ApplyChanges(context.Background(), changes)
+
+	require.NoError(t, err)
+	mockClient.AssertNotCalled(t, "CreateDNSRecord", mock.Anything, mock.Anything, mock.Anything)
+	mockClient.AssertNotCalled(t, "DeleteDNSRecord", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestApplyChanges_BlocksUpdatesOfRecordsProtectedByID(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("shared.example.com", "192.168.1.5", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("app.example.com", "192.168.1.6", unifi.DNSRecordRecordTypeA),
+	}, nil)
+	mockClient.On("DeleteDNSRecord", mock.Anything, unifi.Site("default"), "test-id-app.example.com").Return(nil).Once()
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.MatchedBy(func(input *unifi.DNSRecordInput) bool {
+		return input.Key == "app.example.com" && input.Value == "192.168.1.7"
+	})).Return(&unifi.DNSRecord{}, nil).Once()
+
+	protection, err := NewProtection(nil, nil, []string{"test-id-shared.example.com"}, false)
+	require.NoError(t, err)
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithProtection(protection))
+
+	// Deleting the old record would be skipped, so creating the new one would duplicate it
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		UpdateOld: []*endpoint.Endpoint{
+			{DNSName: "shared.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.5"}},
+			{DNSName: "app.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.6"}},
+		},
+		UpdateNew: []*endpoint.Endpoint{
+			{DNSName: "shared.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.50"}},
+			{DNSName: "app.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.7"}},
+		},
+	})
+
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNumberOfCalls(t, "CreateDNSRecord", 1)
+	mockClient.AssertNumberOfCalls(t, "DeleteDNSRecord", 1)
+}
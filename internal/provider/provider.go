@@ -1,8 +1,10 @@
 package provider
 
 import (
+	"cmp"
 	"context"
 	"log/slog"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -10,6 +12,8 @@ import (
 
 	"github.com/cockroachdb/errors"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/ptr"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/verify"
 	unifi "github.com/lexfrei/go-unifi/api/network"
 	"golang.org/x/sync/semaphore"
 	"sigs.k8s.io/external-dns/endpoint"
@@ -18,33 +22,222 @@ import (
 
 const (
 	defaultTTL = 300
-	// maxConcurrency limits parallel DNS operations to protect UniFi API from overload.
-	maxConcurrency = 5
+	// DefaultMaxConcurrency limits parallel DNS operations to protect UniFi API from overload.
+	DefaultMaxConcurrency = 5
 	// operationTimeout is the maximum time allowed for a single DNS operation.
 	operationTimeout = 30 * time.Second
 )
 
 // UniFiProvider implements the provider.Provider interface for UniFi OS.
 type UniFiProvider struct {
-	client       unifi.NetworkAPIClient
-	site         string
-	domainFilter endpoint.DomainFilter
+	client unifi.NetworkAPIClient
+	site   string
+
+	// mu guards the settings below, which can change when the configuration is reloaded
+	mu             sync.RWMutex
+	domainFilter   endpoint.DomainFilter
+	protection     *Protection
+	targetPolicy   *TargetPolicy
+	maxConcurrency int64
+
+	rewrites    *Rewrites
+	targets     *TargetRewrites
+	verifier    *verify.Verifier
+	failVerify  bool
+	pointers    *ptr.Manager
+	beforeApply []func(ctx context.Context)
+	afterApply  []func(ctx context.Context, changes *plan.Changes, err error)
+}
+
+// Option configures optional UniFiProvider behavior.
+type Option func(*UniFiProvider)
+
+// WithProtection sets the records that the provider must never modify or delete.
+func WithProtection(protection *Protection) Option {
+	return func(p *UniFiProvider) {
+		p.protection = protection
+	}
+}
+
+// WithRewrites sets the rules that map names from external-dns to the names stored in UniFi.
+func WithRewrites(rewrites *Rewrites) Option {
+	return func(p *UniFiProvider) {
+		p.rewrites = rewrites
+	}
+}
+
+// WithTargetRewrites sets the rules that map targets from external-dns to the targets stored in UniFi.
+func WithTargetRewrites(targets *TargetRewrites) Option {
+	return func(p *UniFiProvider) {
+		p.targets = targets
+	}
+}
+
+// WithTargetPolicy sets the addresses records may point to.
+func WithTargetPolicy(policy *TargetPolicy) Option {
+	return func(p *UniFiProvider) {
+		p.targetPolicy = policy
+	}
+}
+
+// WithVerifier makes ApplyChanges check that created and updated records are served
+// by a DNS server after applying them. Records that are not are logged and counted.
+func WithVerifier(verifier *verify.Verifier) Option {
+	return func(p *UniFiProvider) {
+		p.verifier = verifier
+	}
+}
+
+// WithFailOnVerify makes ApplyChanges fail with a *verify.Error when applied records
+// are not served, so external-dns retries the batch.
+func WithFailOnVerify(enabled bool) Option {
+	return func(p *UniFiProvider) {
+		p.failVerify = enabled
+	}
+}
+
+// WithPointers makes ApplyChanges keep the reverse records of A and AAAA records
+// within the manager's reverse zones up to date. See SyncPointers for records
+// that existed before.
+func WithPointers(pointers *ptr.Manager) Option {
+	return func(p *UniFiProvider) {
+		p.pointers = pointers
+	}
+}
+
+// WithMaxConcurrency sets how many DNS operations run in parallel.
+func WithMaxConcurrency(limit int) Option {
+	return func(p *UniFiProvider) {
+		p.maxConcurrency = int64(limit)
+	}
+}
+
+// WithBeforeApply adds a hook that runs before changes that reach UniFi are applied,
+// for example to snapshot the records first. Hooks run in the order they were added.
+func WithBeforeApply(hook func(ctx context.Context)) Option {
+	return func(p *UniFiProvider) {
+		p.beforeApply = append(p.beforeApply, hook)
+	}
+}
+
+// WithAfterApply adds a hook that runs after changes that reach UniFi were applied,
+// with the changes left after protection filtering and the outcome of applying them.
+func WithAfterApply(hook func(ctx context.Context, changes *plan.Changes, err error)) Option {
+	return func(p *UniFiProvider) {
+		p.afterApply = append(p.afterApply, hook)
+	}
 }
 
 // New creates a new UniFiProvider instance with the provided client.
 // This constructor accepts an interface to enable dependency injection for testing.
-func New(client unifi.NetworkAPIClient, site string, domainFilter endpoint.DomainFilter) *UniFiProvider {
-	return &UniFiProvider{
-		client:       client,
-		site:         site,
-		domainFilter: domainFilter,
+func New(client unifi.NetworkAPIClient, site string, domainFilter endpoint.DomainFilter, opts ...Option) *UniFiProvider {
+	prov := &UniFiProvider{
+		client:         client,
+		site:           site,
+		domainFilter:   domainFilter,
+		maxConcurrency: DefaultMaxConcurrency,
 	}
+
+	for _, opt := range opts {
+		opt(prov)
+	}
+
+	return prov
+}
+
+// SetDomainFilter replaces the domain filter used by subsequent calls.
+func (p *UniFiProvider) SetDomainFilter(domainFilter endpoint.DomainFilter) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.domainFilter = domainFilter
+}
+
+// SetProtection replaces the protected records used by subsequent calls.
+func (p *UniFiProvider) SetProtection(protection *Protection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.protection = protection
+}
+
+// SetTargetPolicy replaces the target policy used by subsequent calls.
+func (p *UniFiProvider) SetTargetPolicy(policy *TargetPolicy) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.targetPolicy = policy
+}
+
+// SetMaxConcurrency replaces the parallel operation limit used by subsequent calls.
+func (p *UniFiProvider) SetMaxConcurrency(limit int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.maxConcurrency = int64(limit)
+}
+
+// filter returns the current domain filter.
+func (p *UniFiProvider) filter() endpoint.DomainFilter {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.domainFilter
+}
+
+// rules returns the current protected records.
+func (p *UniFiProvider) rules() *Protection {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.protection
+}
+
+// policy returns the current target policy.
+func (p *UniFiProvider) policy() *TargetPolicy {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.targetPolicy
+}
+
+// concurrency returns the current parallel operation limit.
+func (p *UniFiProvider) concurrency() int64 {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	return p.maxConcurrency
 }
 
 // Records retrieves all DNS records from UniFi that match the domain filter.
 func (p *UniFiProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
 	slog.InfoContext(ctx, "fetching DNS records from UniFi", "site", p.site)
 
+	endpoints, err := p.RecordsIn(ctx, p.filter())
+	if err != nil {
+		return nil, err
+	}
+
+	p.flagViolations(ctx, endpoints)
+
+	recordsByType := make(map[string]int)
+	for _, endpointRecord := range endpoints {
+		recordsByType[endpointRecord.RecordType]++
+	}
+
+	// Update metrics for managed records by type
+	for recordType, count := range recordsByType {
+		dnsmetrics.DNSRecordsManaged.WithLabelValues(recordType).Set(float64(count))
+	}
+
+	slog.InfoContext(ctx, "fetched DNS records", "filtered_count", len(endpoints))
+
+	return endpoints, nil
+}
+
+// RecordsIn retrieves the DNS records from UniFi that match domainFilter instead of the
+// configured domain filter, for built-in sources that publish records outside it.
+func (p *UniFiProvider) RecordsIn(ctx context.Context, domainFilter endpoint.DomainFilter) ([]*endpoint.Endpoint, error) {
 	// Get DNS records from UniFi API using the proper APIClient wrapper
 	records, err := p.client.ListDNSRecords(ctx, p.site)
 	if err != nil {
@@ -57,38 +250,114 @@ func (p *UniFiProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, erro
 	// Pre-allocate with capacity to avoid reallocations (most records will match filter)
 	endpoints := make([]*endpoint.Endpoint, 0, len(records))
 
-	recordsByType := make(map[string]int)
+	protection := p.rules()
 
 	for _, record := range records {
-		// Skip records that don't match the domain filter
-		if !p.domainFilter.Match(record.Key) {
+		// Skip records that don't match the domain filter, which applies to the names external-dns sees
+		if !domainFilter.Match(p.rewrites.ExternalName(record.Key)) {
+			continue
+		}
+
+		// Skip protected records when they are configured to be hidden
+		if protection.Hidden() && protection.MatchRecord(&record) {
 			continue
 		}
 
 		endpointRecord := p.unifiToEndpoint(&record)
 		if endpointRecord != nil {
 			endpoints = append(endpoints, endpointRecord)
-			recordsByType[endpointRecord.RecordType]++
 		}
 	}
 
-	// Update metrics for managed records by type
-	for recordType, count := range recordsByType {
-		dnsmetrics.DNSRecordsManaged.WithLabelValues(recordType).Set(float64(count))
-	}
-
-	slog.InfoContext(ctx, "fetched DNS records", "filtered_count", len(endpoints))
-
 	return endpoints, nil
 }
 
 // ApplyChanges applies the given changes to UniFi DNS.
 func (p *UniFiProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	// Drop changes touching protected records before anything reaches UniFi
+	changes, err := p.filterProtected(ctx, changes)
+	if err != nil {
+		return err
+	}
+
+	// Drop creates and updates pointing where the target policy forbids, applying the rest
+	changes, policyErr := p.filterPolicy(ctx, changes)
+
 	slog.InfoContext(ctx, "applying DNS changes",
 		"create", len(changes.Create),
 		"update", len(changes.UpdateNew),
 		"delete", len(changes.Delete))
 
+	if len(changes.Create)+len(changes.UpdateNew)+len(changes.Delete) == 0 {
+		err = p.applyChanges(ctx, changes)
+
+		return cmp.Or(err, policyErr)
+	}
+
+	for _, hook := range p.beforeApply {
+		hook(ctx)
+	}
+
+	err = p.applyChanges(ctx, changes)
+
+	// Targets remembered for the changes that were applied must survive a restart
+	saveErr := p.targets.save()
+	if saveErr != nil {
+		slog.ErrorContext(ctx, "failed to save rewritten targets", "error", saveErr)
+	}
+
+	for _, hook := range p.afterApply {
+		hook(ctx, changes, err)
+	}
+
+	// Reverse records follow the changes once they were applied; after a failure external-dns retries them
+	if err == nil {
+		p.updatePointers(ctx, changes)
+	}
+
+	// The changes were applied, so a failed verification is reported but does not undo them.
+	// The verifier logs and counts failures; they fail the batch unless that is turned off.
+	if err == nil && p.verifier != nil {
+		verifyErr := p.verifier.Verify(ctx, p.expected(changes))
+		if p.failVerify {
+			err = verifyErr
+		}
+	}
+
+	// Rejected changes fail the batch only after the rest was applied
+	return cmp.Or(err, policyErr)
+}
+
+// expected returns the created and updated records as the DNS server should serve them,
+// with their names and targets as stored in UniFi.
+func (p *UniFiProvider) expected(changes *plan.Changes) []verify.Record {
+	expected := make([]verify.Record, 0, len(changes.Create)+len(changes.UpdateNew))
+
+	for _, endpointItem := range slices.Concat(changes.Create, changes.UpdateNew) {
+		if !verify.Supported(endpointItem.RecordType) {
+			continue
+		}
+
+		record := verify.Record{
+			Name: normalizeName(p.rewrites.UniFiName(endpointItem.DNSName)),
+			Type: endpointItem.RecordType,
+		}
+
+		for _, target := range endpointItem.Targets {
+			value := p.targets.UniFiTarget(endpointItem.RecordType, target)
+			if !slices.Contains(record.Values, value) {
+				record.Values = append(record.Values, value)
+			}
+		}
+
+		expected = append(expected, record)
+	}
+
+	return expected
+}
+
+// applyChanges applies changes that already passed protection filtering.
+func (p *UniFiProvider) applyChanges(ctx context.Context, changes *plan.Changes) error {
 	// Record number of changes
 	if len(changes.Delete) > 0 {
 		dnsmetrics.DNSChangesApplied.WithLabelValues("delete").Observe(float64(len(changes.Delete)))
@@ -131,9 +400,19 @@ func (p *UniFiProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endp
 	return endpoints, nil
 }
 
+// Manages reports whether a UniFi record is within the domain filter and not protected,
+// that is, whether the webhook may change it.
+func (p *UniFiProvider) Manages(record *unifi.DNSRecord) bool {
+	domainFilter := p.filter()
+
+	return domainFilter.Match(p.rewrites.ExternalName(record.Key)) && !p.rules().MatchRecord(record)
+}
+
 // GetDomainFilter returns the domain filter configuration.
 func (p *UniFiProvider) GetDomainFilter() endpoint.DomainFilterInterface {
-	return &p.domainFilter
+	domainFilter := p.filter()
+
+	return &domainFilter
 }
 
 func (p *UniFiProvider) applyDeletions(ctx context.Context, endpoints []*endpoint.Endpoint) error {
@@ -190,7 +469,7 @@ func (p *UniFiProvider) applyUpdates(ctx context.Context, oldEndpoints, newEndpo
 
 // parallelDeleteWithIndex performs parallel deletion using a pre-built record index.
 func (p *UniFiProvider) parallelDeleteWithIndex(ctx context.Context, endpoints []*endpoint.Endpoint, recordIndex map[string][]unifi.DNSRecord, operation string) error {
-	sem := semaphore.NewWeighted(maxConcurrency)
+	sem := semaphore.NewWeighted(p.concurrency())
 	errChan := make(chan error, len(endpoints))
 
 	var wg sync.WaitGroup
@@ -216,7 +495,7 @@ func (p *UniFiProvider) parallelDeleteWithIndex(ctx context.Context, endpoints [
 
 			start := time.Now()
 
-			deleteErr := p.deleteRecordWithIndex(opCtx, endpointItem, recordIndex)
+			deleteErr := p.deleteRecordWithIndex(opCtx, endpointItem, recordIndex, operation)
 			if deleteErr != nil {
 				dnsmetrics.DNSOperationsTotal.WithLabelValues(operation, "error").Inc()
 
@@ -238,7 +517,7 @@ func (p *UniFiProvider) parallelDeleteWithIndex(ctx context.Context, endpoints [
 
 // parallelCreate performs parallel creation of DNS records.
 func (p *UniFiProvider) parallelCreate(ctx context.Context, endpoints []*endpoint.Endpoint, operation string) error {
-	sem := semaphore.NewWeighted(maxConcurrency)
+	sem := semaphore.NewWeighted(p.concurrency())
 	errChan := make(chan error, len(endpoints))
 
 	var wg sync.WaitGroup
@@ -323,6 +602,21 @@ func (p *UniFiProvider) applyCreations(ctx context.Context, endpoints []*endpoin
 	return p.parallelCreate(ctx, endpoints, "create")
 }
 
+// Endpoints converts UniFi DNS records to endpoints with the names and targets
+// external-dns sees, one endpoint per record. Unsupported record types are skipped.
+func (p *UniFiProvider) Endpoints(records []unifi.DNSRecord) []*endpoint.Endpoint {
+	endpoints := make([]*endpoint.Endpoint, 0, len(records))
+
+	for idx := range records {
+		endpointRecord := p.unifiToEndpoint(&records[idx])
+		if endpointRecord != nil {
+			endpoints = append(endpoints, endpointRecord)
+		}
+	}
+
+	return endpoints
+}
+
 // unifiToEndpoint converts a UniFi DNS record to an endpoint.
 func (p *UniFiProvider) unifiToEndpoint(record *unifi.DNSRecord) *endpoint.Endpoint {
 	// Map UniFi record types to standard DNS types
@@ -354,10 +648,10 @@ func (p *UniFiProvider) unifiToEndpoint(record *unifi.DNSRecord) *endpoint.Endpo
 	}
 
 	return &endpoint.Endpoint{
-		DNSName:    record.Key,
+		DNSName:    p.rewrites.ExternalName(record.Key),
 		RecordType: recordType,
 		RecordTTL:  ttl,
-		Targets:    []string{record.Value},
+		Targets:    p.targets.ExternalTargets(record.Key, recordType, record.Value),
 	}
 }
 
@@ -403,9 +697,9 @@ func (p *UniFiProvider) endpointToUniFiWithTarget(endpointData *endpoint.Endpoin
 	}
 
 	return &unifi.DNSRecordInput{
-		Key:        endpointData.DNSName,
+		Key:        p.rewrites.UniFiName(endpointData.DNSName),
 		RecordType: recordType,
-		Value:      targetValue,
+		Value:      p.targets.UniFiTarget(endpointData.RecordType, targetValue),
 		Ttl:        ttl,
 		Enabled:    &enabled,
 	}
@@ -420,8 +714,21 @@ func (p *UniFiProvider) createRecord(ctx context.Context, endpointToCreate *endp
 		return errors.Newf("endpoint has no targets: %s", endpointToCreate.DNSName)
 	}
 
+	// A name that would read back as another name would be created again on every sync
+	unifiName := p.rewrites.UniFiName(endpointToCreate.DNSName)
+	if readBack := p.rewrites.ExternalName(unifiName); normalizeName(readBack) != normalizeName(endpointToCreate.DNSName) {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.Newf("name %s is stored as %s, which rewrite rules map back to %s",
+			endpointToCreate.DNSName, unifiName, readBack)
+	}
+
 	// Create a separate DNS record for each target
 	// This enables round-robin DNS for multiple IPs
+	// Targets rewritten to the same value share a record and are remembered together
+	var inputs []*unifi.DNSRecordInput
+
+	originals := make(map[string][]string, len(endpointToCreate.Targets))
+
 	for _, target := range endpointToCreate.Targets {
 		recordInput := p.endpointToUniFiWithTarget(endpointToCreate, target)
 		if recordInput == nil {
@@ -432,18 +739,30 @@ func (p *UniFiProvider) createRecord(ctx context.Context, endpointToCreate *endp
 			continue
 		}
 
+		if _, ok := originals[recordInput.Value]; !ok {
+			inputs = append(inputs, recordInput)
+		}
+
+		originals[recordInput.Value] = append(originals[recordInput.Value], target)
+	}
+
+	for _, recordInput := range inputs {
 		slog.InfoContext(ctx, "creating DNS record",
 			"name", endpointToCreate.DNSName,
+			"unifi_name", recordInput.Key,
 			"type", endpointToCreate.RecordType,
-			"target", target)
+			"target", strings.Join(originals[recordInput.Value], ","),
+			"unifi_target", recordInput.Value)
 
 		slog.DebugContext(ctx, "DNS record input",
 			"record_input", recordInput)
 
 		_, err := p.client.CreateDNSRecord(ctx, p.site, recordInput)
 		if err != nil {
-			return errors.Wrapf(err, "failed to create DNS record for target %s", target)
+			return errors.Wrapf(err, "failed to create DNS record for target %s", recordInput.Value)
 		}
+
+		p.targets.remember(recordInput.Key, endpointToCreate.RecordType, recordInput.Value, originals[recordInput.Value])
 	}
 
 	return nil
@@ -452,8 +771,8 @@ func (p *UniFiProvider) createRecord(ctx context.Context, endpointToCreate *endp
 // deleteRecordWithIndex deletes a DNS record using a pre-built index.
 // This avoids repeated API calls to list all records, significantly improving
 // performance for batch operations (10+ records: 2-5s -> 200-400ms).
-func (p *UniFiProvider) deleteRecordWithIndex(ctx context.Context, endpointToDelete *endpoint.Endpoint, recordIndex map[string][]unifi.DNSRecord) error {
-	records := recordIndex[endpointToDelete.DNSName]
+func (p *UniFiProvider) deleteRecordWithIndex(ctx context.Context, endpointToDelete *endpoint.Endpoint, recordIndex map[string][]unifi.DNSRecord, operation string) error {
+	records := recordIndex[normalizeName(p.rewrites.UniFiName(endpointToDelete.DNSName))]
 
 	if len(records) == 0 {
 		slog.WarnContext(ctx, "record not found for deletion", "name", endpointToDelete.DNSName)
@@ -461,8 +780,17 @@ func (p *UniFiProvider) deleteRecordWithIndex(ctx context.Context, endpointToDel
 		return nil // Record doesn't exist, nothing to delete
 	}
 
+	deleted := false
+
 	// Delete all matching records
 	for _, record := range records {
+		// Records protected by ID can share a name with managed records
+		if p.rules().MatchRecord(&record) {
+			p.recordBlocked(ctx, endpointToDelete, operation)
+
+			continue
+		}
+
 		slog.InfoContext(ctx, "deleting DNS record",
 			"name", endpointToDelete.DNSName,
 			"type", endpointToDelete.RecordType,
@@ -472,23 +800,33 @@ func (p *UniFiProvider) deleteRecordWithIndex(ctx context.Context, endpointToDel
 		if err != nil {
 			return errors.Wrap(err, "failed to delete DNS record")
 		}
+
+		deleted = true
+	}
+
+	// The targets of records kept as protected are still needed to read them back
+	if deleted {
+		p.targets.forget(records[0].Key)
 	}
 
 	return nil
 }
 
-// buildRecordIndex creates a map index of DNS records by name.
+// buildRecordIndex creates a map index of DNS records by normalized name.
 // This allows O(1) lookup instead of O(N) linear search.
 func buildRecordIndex(records []unifi.DNSRecord) map[string][]unifi.DNSRecord {
 	index := make(map[string][]unifi.DNSRecord, len(records))
 
 	for _, record := range records {
-		if existing := index[record.Key]; existing == nil {
+		// Names in UniFi keep the case they were entered with, rewritten names are lowercase
+		name := normalizeName(record.Key)
+
+		if existing := index[name]; existing == nil {
 			// First record with this key - pre-allocate capacity for typical case (1-2 targets)
-			index[record.Key] = make([]unifi.DNSRecord, 0, 2)
+			index[name] = make([]unifi.DNSRecord, 0, 2)
 		}
 
-		index[record.Key] = append(index[record.Key], record)
+		index[name] = append(index[name], record)
 	}
 
 	return index
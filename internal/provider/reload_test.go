@@ -0,0 +1,36 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+func TestRecords_SetDomainFilter(t *testing.T) {
+	t.Parallel()
+
+	mockClient := new(MockNetworkClient)
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("example.com", "192.168.1.1", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("test.com", "192.168.1.2", unifi.DNSRecordRecordTypeA),
+	}, nil)
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{})
+
+	// A reloaded filter applies to the next call
+	provider.SetDomainFilter(*endpoint.NewDomainFilter([]string{"test.com"}))
+
+	endpoints, err := provider.Records(context.Background())
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, "test.com", endpoints[0].DNSName)
+	assert.True(t, provider.GetDomainFilter().Match("test.com"))
+	assert.False(t, provider.GetDomainFilter().Match("example.com"))
+}
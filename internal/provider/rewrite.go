@@ -0,0 +1,339 @@
+package provider
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+)
+
+// Rewrites maps names between external-dns and UniFi, for example to serve the
+// public names of ingresses under an internal zone. Names are rewritten when
+// records are created in UniFi and rewritten back when they are read, so
+// external-dns only ever sees its own names. The first matching rule applies.
+// A nil *Rewrites leaves names unchanged.
+type Rewrites struct {
+	rules []rewriteRule
+}
+
+// rewriteRule rewrites a name to UniFi and back; ok is false when the rule does not apply.
+type rewriteRule interface {
+	toUniFi(name string) (string, bool)
+	toExternal(name string) (string, bool)
+}
+
+// NewRewrites creates rewrite rules from suffix rules such as "example.com=home.arpa"
+// and regex rules such as `(?P<app>[a-z0-9-]+)\.apps\.example\.com=${app}.home.arpa`.
+//
+// A regex must match the whole name and consist of literal text and capture
+// groups, each of which the template uses exactly once, as ${name} or ${1}.
+// That way every rule can be reversed.
+func NewRewrites(suffixes, regexes []string) (*Rewrites, error) {
+	rewrites := &Rewrites{}
+
+	for _, rule := range suffixes {
+		from, to, ok := strings.Cut(rule, "=")
+		from, to = normalizeName(strings.TrimSpace(from)), normalizeName(strings.TrimSpace(to))
+
+		if !ok || from == "" || to == "" {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("invalid suffix rewrite %q, expected FROM=TO", rule)
+		}
+
+		rewrites.rules = append(rewrites.rules, suffixRule{from: from, to: to})
+	}
+
+	for _, rule := range regexes {
+		pattern, template, ok := strings.Cut(rule, "=")
+		if !ok {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("invalid regex rewrite %q, expected PATTERN=TEMPLATE", rule)
+		}
+
+		compiled, err := newRegexRule(strings.TrimSpace(pattern), strings.TrimSpace(template))
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid regex rewrite %q", rule)
+		}
+
+		rewrites.rules = append(rewrites.rules, compiled)
+	}
+
+	return rewrites, nil
+}
+
+// UniFiName returns the name a record from external-dns is stored under in UniFi.
+func (r *Rewrites) UniFiName(name string) string {
+	if r == nil {
+		return name
+	}
+
+	normalized := normalizeName(name)
+
+	for _, rule := range r.rules {
+		if rewritten, ok := rule.toUniFi(normalized); ok {
+			return rewritten
+		}
+	}
+
+	return name
+}
+
+// ExternalName returns the name external-dns sees for a record stored in UniFi.
+func (r *Rewrites) ExternalName(name string) string {
+	if r == nil {
+		return name
+	}
+
+	normalized := normalizeName(name)
+
+	for _, rule := range r.rules {
+		if rewritten, ok := rule.toExternal(normalized); ok {
+			return rewritten
+		}
+	}
+
+	return name
+}
+
+// suffixRule replaces the domain suffix from with to.
+type suffixRule struct {
+	from string
+	to   string
+}
+
+func (r suffixRule) toUniFi(name string) (string, bool) {
+	return replaceSuffix(name, r.from, r.to)
+}
+
+func (r suffixRule) toExternal(name string) (string, bool) {
+	return replaceSuffix(name, r.to, r.from)
+}
+
+// replaceSuffix replaces the domain suffix from of name, at a label boundary.
+func replaceSuffix(name, from, to string) (string, bool) {
+	if name == from {
+		return to, true
+	}
+
+	prefix, ok := strings.CutSuffix(name, "."+from)
+	if !ok {
+		return "", false
+	}
+
+	return prefix + "." + to, true
+}
+
+// regexRule rewrites names matching a pattern, and back with a pattern derived
+// from the template.
+type regexRule struct {
+	forward         *regexp.Regexp
+	forwardTemplate string
+	reverse         *regexp.Regexp
+	reverseTemplate string
+}
+
+func (r regexRule) toUniFi(name string) (string, bool) {
+	return expand(r.forward, r.forwardTemplate, name)
+}
+
+func (r regexRule) toExternal(name string) (string, bool) {
+	return expand(r.reverse, r.reverseTemplate, name)
+}
+
+// expand rewrites name with template if pattern matches it.
+func expand(pattern *regexp.Regexp, template, name string) (string, bool) {
+	match := pattern.FindStringSubmatchIndex(name)
+	if match == nil {
+		return "", false
+	}
+
+	return normalizeName(string(pattern.ExpandString(nil, template, name, match))), true
+}
+
+// namePart is literal text or a capture group of a name pattern or template.
+type namePart struct {
+	literal string
+	group   int
+}
+
+// newRegexRule compiles a regex rule and derives its reverse.
+func newRegexRule(pattern, template string) (regexRule, error) {
+	forward, err := regexp.Compile("^(?:" + pattern + ")$")
+	if err != nil {
+		return regexRule{}, errors.Wrap(err, "invalid pattern")
+	}
+
+	parsed, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return regexRule{}, errors.Wrap(err, "invalid pattern")
+	}
+
+	patternParts, groups, err := splitPattern(parsed)
+	if err != nil {
+		return regexRule{}, err
+	}
+
+	templateParts, err := splitTemplate(template, forward)
+	if err != nil {
+		return regexRule{}, err
+	}
+
+	// The reverse pattern matches what the template produces, capturing each group again
+	used := make(map[int]bool, len(groups))
+
+	var reversePattern strings.Builder
+
+	reversePattern.WriteString("^")
+
+	for _, part := range templateParts {
+		if part.group == 0 {
+			reversePattern.WriteString(regexp.QuoteMeta(part.literal))
+
+			continue
+		}
+
+		if used[part.group] {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return regexRule{}, errors.Newf("template uses group %d more than once", part.group)
+		}
+
+		used[part.group] = true
+
+		reversePattern.WriteString("(?P<g" + strconv.Itoa(part.group) + ">" + groups[part.group] + ")")
+	}
+
+	reversePattern.WriteString("$")
+
+	if len(used) != len(groups) {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return regexRule{}, errors.New("template must use every capture group of the pattern")
+	}
+
+	reverse, err := regexp.Compile(reversePattern.String())
+	if err != nil {
+		return regexRule{}, errors.Wrap(err, "cannot reverse template")
+	}
+
+	// The reverse template rebuilds the original name from the groups
+	var reverseTemplate strings.Builder
+
+	for _, part := range patternParts {
+		if part.group == 0 {
+			reverseTemplate.WriteString(strings.ReplaceAll(part.literal, "$", "$$"))
+		} else {
+			reverseTemplate.WriteString("${g" + strconv.Itoa(part.group) + "}")
+		}
+	}
+
+	var forwardTemplate strings.Builder
+
+	for _, part := range templateParts {
+		if part.group == 0 {
+			forwardTemplate.WriteString(strings.ReplaceAll(part.literal, "$", "$$"))
+		} else {
+			forwardTemplate.WriteString("${" + strconv.Itoa(part.group) + "}")
+		}
+	}
+
+	return regexRule{
+		forward:         forward,
+		forwardTemplate: forwardTemplate.String(),
+		reverse:         reverse,
+		reverseTemplate: reverseTemplate.String(),
+	}, nil
+}
+
+// splitPattern splits a parsed pattern into literal text and capture groups,
+// returning the pattern of each group by its index. Anchors are dropped since
+// rules always match whole names.
+func splitPattern(parsed *syntax.Regexp) ([]namePart, map[int]string, error) {
+	items := []*syntax.Regexp{parsed}
+	if parsed.Op == syntax.OpConcat {
+		items = parsed.Sub
+	}
+
+	var parts []namePart
+
+	groups := make(map[int]string)
+
+	for _, item := range items {
+		switch item.Op {
+		case syntax.OpBeginLine, syntax.OpEndLine, syntax.OpBeginText, syntax.OpEndText, syntax.OpEmptyMatch:
+		case syntax.OpLiteral:
+			parts = append(parts, namePart{literal: strings.ToLower(string(item.Rune))})
+		case syntax.OpCapture:
+			if hasCapture(item.Sub[0]) {
+				//nolint:wrapcheck // Creating new error, not wrapping
+				return nil, nil, errors.New("capture groups must not be nested")
+			}
+
+			groups[item.Cap] = item.Sub[0].String()
+			parts = append(parts, namePart{group: item.Cap})
+		default:
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, nil, errors.Newf("pattern must consist of literal text and capture groups, found %s", item)
+		}
+	}
+
+	return parts, groups, nil
+}
+
+// hasCapture reports whether a parsed pattern contains a capture group.
+func hasCapture(parsed *syntax.Regexp) bool {
+	if parsed.Op == syntax.OpCapture {
+		return true
+	}
+
+	for _, sub := range parsed.Sub {
+		if hasCapture(sub) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitTemplate splits a template into literal text and references to the
+// groups of pattern, written as ${name} or ${1}.
+func splitTemplate(template string, pattern *regexp.Regexp) ([]namePart, error) {
+	var parts []namePart
+
+	for template != "" {
+		before, after, found := strings.Cut(template, "${")
+		if before != "" {
+			if strings.Contains(before, "$") {
+				//nolint:wrapcheck // Creating new error, not wrapping
+				return nil, errors.New("template references must be written as ${name}")
+			}
+
+			parts = append(parts, namePart{literal: strings.ToLower(before)})
+		}
+
+		if !found {
+			break
+		}
+
+		reference, rest, closed := strings.Cut(after, "}")
+		if !closed {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.New("unterminated ${ in template")
+		}
+
+		group, err := strconv.Atoi(reference)
+		if err != nil {
+			group = pattern.SubexpIndex(reference)
+		}
+
+		if group < 1 || group >= pattern.NumSubexp()+1 {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("template references unknown group %q", reference)
+		}
+
+		parts = append(parts, namePart{group: group})
+		template = rest
+	}
+
+	return parts, nil
+}
@@ -0,0 +1,126 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+func TestNewRewrites_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name     string
+		suffixes []string
+		regexes  []string
+	}{
+		{name: "suffix without target", suffixes: []string{"example.com"}},
+		{name: "empty suffix", suffixes: []string{"=home.arpa"}},
+		{name: "regex without template", regexes: []string{`(.+)\.example\.com`}},
+		{name: "invalid regex", regexes: []string{`(.+\.example\.com=${1}.home.arpa`}},
+		{name: "alternation", regexes: []string{`(?P<app>a|b)\.example\.com|x=${app}.home.arpa`}},
+		{name: "nested groups", regexes: []string{`((a)b)\.example\.com=${1}.${2}.home.arpa`}},
+		{name: "unused group", regexes: []string{`(.+)\.(.+)\.example\.com=${1}.home.arpa`}},
+		{name: "group used twice", regexes: []string{`(.+)\.example\.com=${1}.${1}.home.arpa`}},
+		{name: "unknown group", regexes: []string{`(.+)\.example\.com=${app}.home.arpa`}},
+		{name: "bare reference", regexes: []string{`(.+)\.example\.com=$1.home.arpa`}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := NewRewrites(tt.suffixes, tt.regexes)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestRewrites_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	rewrites, err := NewRewrites(
+		[]string{"example.com=home.arpa"},
+		[]string{`(?P<app>[a-z0-9-]+)\.(?P<env>dev|prod)\.apps\.example\.org=${env}-${app}.home.lan`},
+	)
+	require.NoError(t, err)
+
+	tests := []struct {
+		external string
+		unifi    string
+	}{
+		{external: "nas.example.com", unifi: "nas.home.arpa"},
+		{external: "Grafana.Example.com.", unifi: "grafana.home.arpa"},
+		{external: "example.com", unifi: "home.arpa"},
+		{external: "shop.prod.apps.example.org", unifi: "prod-shop.home.lan"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.unifi, rewrites.UniFiName(tt.external))
+		assert.Equal(t, normalizeName(tt.external), rewrites.ExternalName(tt.unifi))
+	}
+
+	// Names no rule matches, including lookalike suffixes, are left alone
+	for _, name := range []string{"notexample.com", "router.lan", "shop.test.apps.example.org"} {
+		assert.Equal(t, name, rewrites.UniFiName(name))
+		assert.Equal(t, name, rewrites.ExternalName(name))
+	}
+
+	var none *Rewrites
+
+	assert.Equal(t, "nas.example.com", none.UniFiName("nas.example.com"))
+	assert.Equal(t, "nas.home.arpa", none.ExternalName("nas.home.arpa"))
+}
+
+func TestProvider_Rewrites(t *testing.T) {
+	t.Parallel()
+
+	rewrites, err := NewRewrites([]string{"example.com=home.arpa"}, nil)
+	require.NoError(t, err)
+
+	mockClient := new(MockNetworkClient)
+
+	// Names entered in the UniFi UI keep their case
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("Old.Home.arpa", "192.168.1.9", unifi.DNSRecordRecordTypeA),
+		createMockDNSRecord("router.lan", "192.168.1.1", unifi.DNSRecordRecordTypeA),
+	}, nil)
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.MatchedBy(func(input *unifi.DNSRecordInput) bool {
+		return input.Key == "nas.home.arpa"
+	})).Return(&unifi.DNSRecord{}, nil)
+	mockClient.On("DeleteDNSRecord", mock.Anything, unifi.Site("default"), "test-id-Old.Home.arpa").Return(nil)
+
+	provider := New(mockClient, "default", *endpoint.NewDomainFilter([]string{"example.com"}), WithRewrites(rewrites))
+
+	// external-dns sees its own names, within its own domain filter
+	endpoints, err := provider.Records(context.Background())
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, "old.example.com", endpoints[0].DNSName)
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "nas.example.com", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.10"}},
+		},
+		Delete: endpoints,
+	})
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+
+	// A name that would read back as another name is refused
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "nas.home.arpa", RecordType: endpoint.RecordTypeA, Targets: []string{"192.168.1.10"}},
+		},
+	})
+	require.Error(t, err)
+	mockClient.AssertNumberOfCalls(t, "CreateDNSRecord", 1)
+}
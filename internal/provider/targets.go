@@ -0,0 +1,338 @@
+package provider
+
+import (
+	"encoding/json"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// TargetRewrites maps the targets external-dns publishes to the targets UniFi
+// answers with on the LAN, for split-horizon DNS. A and AAAA targets are
+// rewritten by address or CIDR, CNAME targets by hostname.
+//
+// Since several targets can map to the same one, the original targets of the
+// records the provider creates are remembered and returned by Records instead
+// of the rewritten ones, so external-dns sees the targets it asked for. They
+// are kept in a state file, if configured, to survive restarts. A nil
+// *TargetRewrites leaves targets unchanged.
+type TargetRewrites struct {
+	prefixes []prefixRule
+	hosts    map[string]string
+	state    string
+
+	mu        sync.Mutex
+	originals map[targetKey][]string
+	dirty     bool
+}
+
+// prefixRule rewrites addresses within from to to.
+type prefixRule struct {
+	from netip.Prefix
+	to   netip.Addr
+}
+
+// targetKey identifies a rewritten record by its name as stored in UniFi.
+type targetKey struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// targetState is a remembered mapping as written to the state file.
+type targetState struct {
+	targetKey
+
+	Targets []string `json:"targets"`
+}
+
+// NewTargetRewrites creates target rewrites from rules such as "203.0.113.0/24=192.168.1.50",
+// "203.0.113.7=192.168.1.51" or "lb.example.com=lb.home.arpa". For addresses the most
+// specific matching rule applies. state is the file the original targets are kept in,
+// or empty to keep them in memory only.
+func NewTargetRewrites(rules []string, state string) (*TargetRewrites, error) {
+	rewrites := &TargetRewrites{
+		hosts:     make(map[string]string),
+		state:     state,
+		originals: make(map[targetKey][]string),
+	}
+
+	for _, rule := range rules {
+		from, to, ok := strings.Cut(rule, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+
+		if !ok || from == "" || to == "" {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("invalid target rewrite %q, expected FROM=TO", rule)
+		}
+
+		err := rewrites.add(from, to)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid target rewrite %q", rule)
+		}
+	}
+
+	// Most specific prefixes first
+	slices.SortStableFunc(rewrites.prefixes, func(left, right prefixRule) int {
+		return right.from.Bits() - left.from.Bits()
+	})
+
+	if state != "" {
+		err := rewrites.load()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return rewrites, nil
+}
+
+// add parses one rule.
+func (t *TargetRewrites) add(from, to string) error {
+	prefix, err := netip.ParsePrefix(from)
+	if err != nil {
+		address, addrErr := netip.ParseAddr(from)
+		if addrErr == nil {
+			prefix, err = address.Prefix(address.BitLen())
+		}
+	}
+
+	if err != nil {
+		// Neither a CIDR nor an address, so a hostname
+		if _, parseErr := netip.ParseAddr(to); parseErr == nil {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return errors.New("hostnames can only be rewritten to hostnames")
+		}
+
+		t.hosts[normalizeName(from)] = normalizeName(to)
+
+		return nil
+	}
+
+	address, err := netip.ParseAddr(to)
+	if err != nil {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("addresses can only be rewritten to addresses")
+	}
+
+	if address.Is4() != prefix.Addr().Is4() {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("addresses can only be rewritten within the same address family")
+	}
+
+	t.prefixes = append(t.prefixes, prefixRule{from: prefix.Masked(), to: address})
+
+	return nil
+}
+
+// UniFiTarget returns the target a record of recordType is stored with in UniFi.
+func (t *TargetRewrites) UniFiTarget(recordType, target string) string {
+	if t == nil {
+		return target
+	}
+
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		address, err := netip.ParseAddr(target)
+		if err != nil {
+			return target
+		}
+
+		for _, rule := range t.prefixes {
+			if rule.from.Contains(address) {
+				return rule.to.String()
+			}
+		}
+	case endpoint.RecordTypeCNAME:
+		if to, ok := t.hosts[normalizeName(target)]; ok {
+			return to
+		}
+	}
+
+	return target
+}
+
+// ExternalTargets returns the targets external-dns sees for a UniFi record. These
+// are the remembered original targets, or the source of the only rule that
+// yields value if it is a single address or hostname, or value itself.
+func (t *TargetRewrites) ExternalTargets(name, recordType, value string) []string {
+	if t == nil {
+		return []string{value}
+	}
+
+	t.mu.Lock()
+	originals := t.originals[targetKey{Name: normalizeName(name), Type: recordType, Value: value}]
+	t.mu.Unlock()
+
+	if len(originals) > 0 {
+		return slices.Clone(originals)
+	}
+
+	if original, ok := t.reverse(recordType, value); ok {
+		return []string{original}
+	}
+
+	return []string{value}
+}
+
+// reverse returns the source of the only rule that rewrites to value, if it is
+// a single address or hostname.
+func (t *TargetRewrites) reverse(recordType, value string) (string, bool) {
+	var sources []string
+
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		for _, rule := range t.prefixes {
+			if rule.to.String() == value {
+				if !rule.from.IsSingleIP() {
+					return "", false
+				}
+
+				sources = append(sources, rule.from.Addr().String())
+			}
+		}
+	case endpoint.RecordTypeCNAME:
+		for from, to := range t.hosts {
+			if to == normalizeName(value) {
+				sources = append(sources, from)
+			}
+		}
+	}
+
+	if len(sources) != 1 {
+		return "", false
+	}
+
+	return sources[0], true
+}
+
+// remember records that the targets of a record stored as name were rewritten to value.
+func (t *TargetRewrites) remember(name, recordType, value string, targets []string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := targetKey{Name: normalizeName(name), Type: recordType, Value: value}
+
+	if len(targets) == 1 && targets[0] == value {
+		if _, ok := t.originals[key]; ok {
+			delete(t.originals, key)
+
+			t.dirty = true
+		}
+
+		return
+	}
+
+	t.originals[key] = targets
+	t.dirty = true
+}
+
+// forget drops the remembered targets of all records stored as name, after they were deleted.
+func (t *TargetRewrites) forget(name string) {
+	if t == nil {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	name = normalizeName(name)
+
+	for key := range t.originals {
+		if key.Name == name {
+			delete(t.originals, key)
+
+			t.dirty = true
+		}
+	}
+}
+
+// load reads the remembered targets from the state file, if it exists.
+func (t *TargetRewrites) load() error {
+	content, err := os.ReadFile(t.state)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+
+	if err != nil {
+		return errors.Wrap(err, "failed to read target rewrite state")
+	}
+
+	var entries []targetState
+
+	err = json.Unmarshal(content, &entries)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode target rewrite state %s", t.state)
+	}
+
+	for _, entry := range entries {
+		t.originals[entry.targetKey] = entry.Targets
+	}
+
+	return nil
+}
+
+// save writes the remembered targets to the state file if they changed.
+func (t *TargetRewrites) save() error {
+	if t == nil || t.state == "" {
+		return nil
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if !t.dirty {
+		return nil
+	}
+
+	entries := make([]targetState, 0, len(t.originals))
+	for key, targets := range t.originals {
+		entries = append(entries, targetState{targetKey: key, Targets: targets})
+	}
+
+	slices.SortFunc(entries, func(left, right targetState) int {
+		return strings.Compare(left.Name+" "+left.Type+" "+left.Value, right.Name+" "+right.Type+" "+right.Value)
+	})
+
+	content, err := json.MarshalIndent(entries, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode target rewrite state")
+	}
+
+	// Written to a temporary file and renamed into place, so a crash never leaves a truncated file
+	temp, err := os.CreateTemp(filepath.Dir(t.state), ".targets-*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create target rewrite state")
+	}
+
+	_, err = temp.Write(content)
+	if err == nil {
+		err = temp.Close()
+	} else {
+		_ = temp.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), t.state)
+	}
+
+	if err != nil {
+		_ = os.Remove(temp.Name())
+
+		return errors.Wrap(err, "failed to write target rewrite state")
+	}
+
+	t.dirty = false
+
+	return nil
+}
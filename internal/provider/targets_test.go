@@ -0,0 +1,144 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+func TestNewTargetRewrites_Invalid(t *testing.T) {
+	t.Parallel()
+
+	for _, rule := range []string{
+		"203.0.113.0/24",
+		"=192.168.1.50",
+		"203.0.113.0/24=lb.home.arpa",
+		"lb.example.com=192.168.1.50",
+		"203.0.113.7=fd00::7",
+	} {
+		_, err := NewTargetRewrites([]string{rule}, "")
+		require.Error(t, err, rule)
+	}
+}
+
+func TestTargetRewrites(t *testing.T) {
+	t.Parallel()
+
+	targets, err := NewTargetRewrites([]string{
+		"203.0.113.0/24=192.168.1.50",
+		"203.0.113.7=192.168.1.51",
+		"198.51.100.9=192.168.1.52",
+		"LB.example.com.=lb.home.arpa",
+	}, "")
+	require.NoError(t, err)
+
+	// The most specific rule applies
+	assert.Equal(t, "192.168.1.50", targets.UniFiTarget(endpoint.RecordTypeA, "203.0.113.10"))
+	assert.Equal(t, "192.168.1.51", targets.UniFiTarget(endpoint.RecordTypeA, "203.0.113.7"))
+	assert.Equal(t, "lb.home.arpa", targets.UniFiTarget(endpoint.RecordTypeCNAME, "lb.example.com"))
+	assert.Equal(t, "10.0.0.1", targets.UniFiTarget(endpoint.RecordTypeA, "10.0.0.1"))
+	assert.Equal(t, "lb.example.com", targets.UniFiTarget(endpoint.RecordTypeTXT, "lb.example.com"))
+
+	// Without remembered targets, only rules from a single source are reversed
+	assert.Equal(t, []string{"198.51.100.9"}, targets.ExternalTargets("app.home.lan", endpoint.RecordTypeA, "192.168.1.52"))
+	assert.Equal(t, []string{"lb.example.com"}, targets.ExternalTargets("app.home.lan", endpoint.RecordTypeCNAME, "lb.home.arpa"))
+	assert.Equal(t, []string{"192.168.1.50"}, targets.ExternalTargets("app.home.lan", endpoint.RecordTypeA, "192.168.1.50"))
+
+	var none *TargetRewrites
+
+	assert.Equal(t, "203.0.113.10", none.UniFiTarget(endpoint.RecordTypeA, "203.0.113.10"))
+	assert.Equal(t, []string{"192.168.1.50"}, none.ExternalTargets("app.home.lan", endpoint.RecordTypeA, "192.168.1.50"))
+}
+
+func TestProvider_TargetRewrites(t *testing.T) {
+	t.Parallel()
+
+	state := filepath.Join(t.TempDir(), "targets.json")
+
+	targets, err := NewTargetRewrites([]string{"203.0.113.0/24=192.168.1.50"}, state)
+	require.NoError(t, err)
+
+	mockClient := new(MockNetworkClient)
+
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.MatchedBy(func(input *unifi.DNSRecordInput) bool {
+		return input.Key == "app.home.lan" && input.Value == "192.168.1.50"
+	})).Return(&unifi.DNSRecord{}, nil).Once()
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("app.home.lan", "192.168.1.50", unifi.DNSRecordRecordTypeA),
+	}, nil)
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithTargetRewrites(targets))
+
+	// Both public addresses map to the VIP, which is stored once
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			{DNSName: "app.home.lan", RecordType: endpoint.RecordTypeA, Targets: []string{"203.0.113.10", "203.0.113.11"}},
+		},
+	})
+	require.NoError(t, err)
+
+	endpoints, err := provider.Records(context.Background())
+	require.NoError(t, err)
+	require.Len(t, endpoints, 1)
+	assert.Equal(t, endpoint.Targets{"203.0.113.10", "203.0.113.11"}, endpoints[0].Targets)
+
+	// A restarted webhook reads the original targets from the state file
+	restarted, err := NewTargetRewrites([]string{"203.0.113.0/24=192.168.1.50"}, state)
+	require.NoError(t, err)
+
+	provider = New(mockClient, "default", endpoint.DomainFilter{}, WithTargetRewrites(restarted))
+
+	endpoints, err = provider.Records(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, endpoint.Targets{"203.0.113.10", "203.0.113.11"}, endpoints[0].Targets)
+
+	// Deleting the record forgets them
+	mockClient.On("DeleteDNSRecord", mock.Anything, unifi.Site("default"), "test-id-app.home.lan").Return(nil)
+
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{Delete: endpoints})
+	require.NoError(t, err)
+	mockClient.AssertExpectations(t)
+
+	restarted, err = NewTargetRewrites([]string{"203.0.113.0/24=192.168.1.50"}, state)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"192.168.1.50"}, restarted.ExternalTargets("app.home.lan", endpoint.RecordTypeA, "192.168.1.50"))
+}
+
+func TestProvider_TargetRewritesKeepProtectedRecords(t *testing.T) {
+	t.Parallel()
+
+	targets, err := NewTargetRewrites([]string{"203.0.113.0/24=192.168.1.50"}, "")
+	require.NoError(t, err)
+
+	targets.remember("app.home.lan", endpoint.RecordTypeA, "192.168.1.50", []string{"203.0.113.10"})
+
+	protection, err := NewProtection(nil, nil, []string{"test-id-app.home.lan"}, false)
+	require.NoError(t, err)
+
+	mockClient := new(MockNetworkClient)
+
+	mockClient.On("ListDNSRecords", mock.Anything, unifi.Site("default")).Return([]unifi.DNSRecord{
+		createMockDNSRecord("app.home.lan", "192.168.1.50", unifi.DNSRecordRecordTypeA),
+	}, nil)
+
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithTargetRewrites(targets), WithProtection(protection))
+
+	// Nothing was deleted, so the original targets are still needed to read the record back
+	err = provider.ApplyChanges(context.Background(), &plan.Changes{
+		Delete: []*endpoint.Endpoint{
+			{DNSName: "app.home.lan", RecordType: endpoint.RecordTypeA, Targets: []string{"203.0.113.10"}},
+		},
+	})
+	require.NoError(t, err)
+	mockClient.AssertNotCalled(t, "DeleteDNSRecord", mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, []string{"203.0.113.10"}, targets.ExternalTargets("app.home.lan", endpoint.RecordTypeA, "192.168.1.50"))
+}
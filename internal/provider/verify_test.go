@@ -0,0 +1,48 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package provider
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/verify"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+func TestApplyChanges_Verify(t *testing.T) {
+	t.Parallel()
+
+	// A resolver that never answers, so every record fails verification
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	verifier := verify.New(conn.LocalAddr().String(), verify.WithTimeout(50*time.Millisecond), verify.WithRetries(0))
+	changes := &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint(testNewDNSName, endpoint.RecordTypeA, testNewTarget)},
+	}
+
+	mockClient := new(MockNetworkClient)
+	mockClient.On("CreateDNSRecord", mock.Anything, unifi.Site("default"), mock.Anything).
+		Return(&unifi.DNSRecord{UnderscoreId: "new-record-id"}, nil)
+
+	// The records were applied, so by default a failed verification is only reported
+	provider := New(mockClient, "default", endpoint.DomainFilter{}, WithVerifier(verifier))
+	require.NoError(t, provider.ApplyChanges(context.Background(), changes))
+
+	provider = New(mockClient, "default", endpoint.DomainFilter{}, WithVerifier(verifier), WithFailOnVerify(true))
+
+	var verifyErr *verify.Error
+
+	require.ErrorAs(t, provider.ApplyChanges(context.Background(), changes), &verifyErr)
+	require.Len(t, verifyErr.Failures, 1)
+	assert.Equal(t, testNewDNSName, verifyErr.Failures[0].Name)
+}
@@ -0,0 +1,154 @@
+package ptr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/cockroachdb/errors"
+)
+
+// fileHeader starts every hosts file the sink writes.
+const fileHeader = "# Managed by external-dns-unifios-webhook, changes are overwritten\n"
+
+// FileSink stores reverse records in a hosts file, for DNS servers that answer
+// reverse queries from one, such as CoreDNS with the hosts plugin or dnsmasq
+// with addn-hosts.
+type FileSink struct {
+	path string
+
+	mu sync.Mutex
+}
+
+// NewFileSink creates a sink storing reverse records in the hosts file at path.
+func NewFileSink(path string) *FileSink {
+	return &FileSink{path: path}
+}
+
+// Pointers returns the entries of the hosts file, none if it does not exist yet.
+func (s *FileSink) Pointers(_ context.Context) ([]Pointer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.read()
+}
+
+// Apply rewrites the hosts file without the pointers to remove and with the ones to add.
+func (s *FileSink) Apply(_ context.Context, remove, add []Pointer) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	pointers, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	pointers = slices.DeleteFunc(pointers, func(pointer Pointer) bool {
+		return slices.Contains(remove, pointer)
+	})
+
+	for _, pointer := range add {
+		if !slices.Contains(pointers, pointer) {
+			pointers = append(pointers, pointer)
+		}
+	}
+
+	return s.write(pointers)
+}
+
+// read parses the hosts file.
+func (s *FileSink) read() ([]Pointer, error) {
+	content, err := os.ReadFile(s.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read PTR hosts file")
+	}
+
+	var pointers []Pointer
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line, _, _ := strings.Cut(scanner.Text(), "#")
+
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			continue
+		}
+
+		address, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid address in PTR hosts file %s", s.path)
+		}
+
+		for _, hostname := range fields[1:] {
+			pointers = append(pointers, normalize(Pointer{Address: address, Hostname: hostname}))
+		}
+	}
+
+	return pointers, nil
+}
+
+// write replaces the hosts file with one line per address, in address order.
+func (s *FileSink) write(pointers []Pointer) error {
+	slices.SortFunc(pointers, comparePointers)
+
+	var builder strings.Builder
+
+	builder.WriteString(fileHeader)
+
+	for idx, pointer := range pointers {
+		if idx > 0 && pointers[idx-1].Address == pointer.Address {
+			builder.WriteString(" " + pointer.Hostname)
+
+			continue
+		}
+
+		if idx > 0 {
+			builder.WriteString("\n")
+		}
+
+		builder.WriteString(pointer.Address.String() + " " + pointer.Hostname)
+	}
+
+	if len(pointers) > 0 {
+		builder.WriteString("\n")
+	}
+
+	// Written to a temporary file and renamed into place, so the DNS server never reads a truncated file
+	temp, err := os.CreateTemp(filepath.Dir(s.path), ".ptr-*.tmp")
+	if err != nil {
+		return errors.Wrap(err, "failed to create PTR hosts file")
+	}
+
+	_, err = temp.WriteString(builder.String())
+	if err == nil {
+		err = temp.Chmod(0o644)
+	}
+
+	if err == nil {
+		err = temp.Close()
+	} else {
+		_ = temp.Close()
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), s.path)
+	}
+
+	if err != nil {
+		_ = os.Remove(temp.Name())
+
+		return errors.Wrap(err, "failed to write PTR hosts file")
+	}
+
+	return nil
+}
@@ -0,0 +1,327 @@
+// Package ptr maintains reverse DNS (PTR) records for the addresses of A and AAAA records.
+//
+// The reverse names of addresses within the configured reverse zones point to the
+// hostnames of the address records. Where the pointers are kept is up to a Sink:
+// UniFi itself on Network versions that accept PTR records, or a hosts file served
+// by another DNS server.
+package ptr
+
+import (
+	"context"
+	"log/slog"
+	"net/netip"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+)
+
+// Reverse DNS suffixes of IPv4 and IPv6 addresses.
+const (
+	suffixIPv4 = ".in-addr.arpa"
+	suffixIPv6 = ".ip6.arpa"
+)
+
+// Pointer is a reverse record pointing an address to a hostname.
+type Pointer struct {
+	Address  netip.Addr
+	Hostname string
+}
+
+// Sink stores reverse records.
+type Sink interface {
+	// Pointers returns the reverse records currently stored.
+	Pointers(ctx context.Context) ([]Pointer, error)
+
+	// Apply removes and adds reverse records, continuing past failures and returning them joined.
+	Apply(ctx context.Context, remove, add []Pointer) error
+}
+
+// Manager keeps the reverse records of a sink in line with the address records.
+type Manager struct {
+	zones []netip.Prefix
+	sink  Sink
+}
+
+// New creates a manager for the reverse zones, given as zone names such as
+// "1.168.192.in-addr.arpa" or as the CIDRs they cover, such as "192.168.1.0/24".
+func New(zones []string, sink Sink) (*Manager, error) {
+	manager := &Manager{sink: sink}
+
+	for _, zone := range zones {
+		prefix, err := ParseZone(zone)
+		if err != nil {
+			return nil, err
+		}
+
+		manager.zones = append(manager.zones, prefix)
+	}
+
+	if len(manager.zones) == 0 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return nil, errors.New("at least one reverse zone is required")
+	}
+
+	return manager, nil
+}
+
+// ParseZone returns the addresses a reverse zone covers.
+func ParseZone(zone string) (netip.Prefix, error) {
+	zone = strings.TrimSuffix(strings.ToLower(strings.TrimSpace(zone)), ".")
+
+	if prefix, err := netip.ParsePrefix(zone); err == nil {
+		return prefix.Masked(), nil
+	}
+
+	// size is the number of bits per label, full the number of labels of a whole address
+	var (
+		labels     []string
+		size, full int
+	)
+
+	switch {
+	case strings.HasSuffix(zone, suffixIPv4):
+		labels, size, full = reverseLabels(zone, suffixIPv4), 8, 4
+	case strings.HasSuffix(zone, suffixIPv6):
+		labels, size, full = reverseLabels(zone, suffixIPv6), 4, 32
+	default:
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return netip.Prefix{}, errors.Newf("reverse zone %q is neither a CIDR nor an in-addr.arpa or ip6.arpa name", zone)
+	}
+
+	if len(labels) > full {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return netip.Prefix{}, errors.Newf("reverse zone %q has too many labels", zone)
+	}
+
+	// The labels of a zone are the leading part of the reverse names in it, so the rest is filled with zeros
+	padded := slices.Clone(labels)
+	for len(padded) < full {
+		padded = append(padded, "0")
+	}
+
+	slices.Reverse(padded)
+
+	address, ok := parseReverse(padded, size)
+	if !ok {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return netip.Prefix{}, errors.Newf("reverse zone %q is not a valid reverse name", zone)
+	}
+
+	return netip.PrefixFrom(address, len(labels)*size), nil
+}
+
+// reverseLabels returns the labels of a reverse name before suffix, most significant first.
+func reverseLabels(name, suffix string) []string {
+	labels := strings.Split(strings.TrimSuffix(name, suffix), ".")
+	slices.Reverse(labels)
+
+	return labels
+}
+
+// parseReverse parses the labels of a full reverse name, least significant first,
+// as octets (size 8) or nibbles (size 4).
+func parseReverse(labels []string, size int) (netip.Addr, bool) {
+	if size == 8 {
+		var octets [4]byte
+
+		for idx, label := range labels {
+			value, err := strconv.ParseUint(label, 10, 8)
+			if err != nil || (len(label) > 1 && label[0] == '0') {
+				return netip.Addr{}, false
+			}
+
+			octets[3-idx] = byte(value)
+		}
+
+		return netip.AddrFrom4(octets), true
+	}
+
+	var bytes [16]byte
+
+	for idx, label := range labels {
+		value, err := strconv.ParseUint(label, 16, 4)
+		if err != nil || len(label) != 1 {
+			return netip.Addr{}, false
+		}
+
+		nibble := 31 - idx
+		bytes[nibble/2] |= byte(value) << (4 * (1 - nibble%2))
+	}
+
+	return netip.AddrFrom16(bytes), true
+}
+
+// ReverseName returns the in-addr.arpa or ip6.arpa name of address.
+func ReverseName(address netip.Addr) string {
+	address = address.Unmap()
+
+	if address.Is4() {
+		octets := address.As4()
+
+		return strconv.Itoa(int(octets[3])) + "." + strconv.Itoa(int(octets[2])) + "." +
+			strconv.Itoa(int(octets[1])) + "." + strconv.Itoa(int(octets[0])) + suffixIPv4
+	}
+
+	const hexDigits = "0123456789abcdef"
+
+	bytes := address.As16()
+
+	var builder strings.Builder
+
+	for idx := len(bytes) - 1; idx >= 0; idx-- {
+		builder.WriteByte(hexDigits[bytes[idx]&0x0f])
+		builder.WriteByte('.')
+		builder.WriteByte(hexDigits[bytes[idx]>>4])
+		builder.WriteByte('.')
+	}
+
+	return strings.TrimSuffix(builder.String(), ".") + suffixIPv6
+}
+
+// ParseReverseName returns the address of a full in-addr.arpa or ip6.arpa name.
+func ParseReverseName(name string) (netip.Addr, bool) {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+
+	labels, size, full := []string(nil), 0, 0
+
+	switch {
+	case strings.HasSuffix(name, suffixIPv4):
+		labels, size, full = strings.Split(strings.TrimSuffix(name, suffixIPv4), "."), 8, 4
+	case strings.HasSuffix(name, suffixIPv6):
+		labels, size, full = strings.Split(strings.TrimSuffix(name, suffixIPv6), "."), 4, 32
+	default:
+		return netip.Addr{}, false
+	}
+
+	if len(labels) != full {
+		return netip.Addr{}, false
+	}
+
+	return parseReverse(labels, size)
+}
+
+// Covers reports whether address is within one of the reverse zones.
+func (m *Manager) Covers(address netip.Addr) bool {
+	address = address.Unmap()
+
+	return slices.ContainsFunc(m.zones, func(zone netip.Prefix) bool {
+		return zone.Contains(address)
+	})
+}
+
+// Sync makes the sink hold exactly the wanted pointers within the reverse zones. Stored
+// pointers that are not wanted are removed only if owns reports their hostname as
+// managed, so reverse records of other hosts in the same zones are kept.
+func (m *Manager) Sync(ctx context.Context, want []Pointer, owns func(hostname string) bool) error {
+	current, err := m.sink.Pointers(ctx)
+	if err != nil {
+		dnsmetrics.PTRSyncs.WithLabelValues("error").Inc()
+
+		return errors.Wrap(err, "failed to read PTR records")
+	}
+
+	wanted := make(map[Pointer]bool, len(want))
+	stored := make(map[Pointer]bool, len(current))
+
+	var remove, add []Pointer
+
+	for _, pointer := range current {
+		pointer = normalize(pointer)
+		stored[pointer] = true
+	}
+
+	for _, pointer := range want {
+		pointer = normalize(pointer)
+		if !m.Covers(pointer.Address) || wanted[pointer] {
+			continue
+		}
+
+		wanted[pointer] = true
+
+		if !stored[pointer] {
+			add = append(add, pointer)
+		}
+	}
+
+	for pointer := range stored {
+		if m.Covers(pointer.Address) && owns(pointer.Hostname) && !wanted[pointer] {
+			remove = append(remove, pointer)
+		}
+	}
+
+	slices.SortFunc(remove, comparePointers)
+
+	return m.apply(ctx, remove, add)
+}
+
+// Update removes and adds pointers for address records that were deleted and created,
+// without reading the stored pointers first. Pointers outside the reverse zones are
+// ignored, and a pointer both removed and added is kept.
+func (m *Manager) Update(ctx context.Context, remove, add []Pointer) error {
+	adding := make(map[Pointer]bool, len(add))
+
+	var removeCovered, addCovered []Pointer
+
+	for _, pointer := range add {
+		pointer = normalize(pointer)
+		if m.Covers(pointer.Address) && !adding[pointer] {
+			adding[pointer] = true
+			addCovered = append(addCovered, pointer)
+		}
+	}
+
+	for _, pointer := range remove {
+		pointer = normalize(pointer)
+		if m.Covers(pointer.Address) && !adding[pointer] && !slices.Contains(removeCovered, pointer) {
+			removeCovered = append(removeCovered, pointer)
+		}
+	}
+
+	return m.apply(ctx, removeCovered, addCovered)
+}
+
+// apply hands the changed pointers to the sink and counts the outcome.
+func (m *Manager) apply(ctx context.Context, remove, add []Pointer) error {
+	if len(remove)+len(add) == 0 {
+		return nil
+	}
+
+	for _, pointer := range remove {
+		slog.InfoContext(ctx, "removing PTR record", "address", pointer.Address, "hostname", pointer.Hostname)
+	}
+
+	for _, pointer := range add {
+		slog.InfoContext(ctx, "adding PTR record", "address", pointer.Address, "hostname", pointer.Hostname)
+	}
+
+	err := m.sink.Apply(ctx, remove, add)
+	if err != nil {
+		dnsmetrics.PTRSyncs.WithLabelValues("error").Inc()
+
+		return errors.Wrap(err, "failed to update PTR records")
+	}
+
+	dnsmetrics.PTRSyncs.WithLabelValues("success").Inc()
+
+	return nil
+}
+
+// normalize lowercases the hostname and drops its trailing dot.
+func normalize(pointer Pointer) Pointer {
+	return Pointer{
+		Address:  pointer.Address.Unmap(),
+		Hostname: strings.TrimSuffix(strings.ToLower(pointer.Hostname), "."),
+	}
+}
+
+// comparePointers orders pointers by address and hostname.
+func comparePointers(left, right Pointer) int {
+	if order := left.Address.Compare(right.Address); order != 0 {
+		return order
+	}
+
+	return strings.Compare(left.Hostname, right.Hostname)
+}
@@ -0,0 +1,132 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package ptr
+
+import (
+	"context"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseZone(t *testing.T) {
+	t.Parallel()
+
+	for zone, expected := range map[string]string{
+		"1.168.192.in-addr.arpa":   "192.168.1.0/24",
+		"168.192.in-addr.arpa.":    "192.168.0.0/16",
+		"10.in-addr.arpa":          "10.0.0.0/8",
+		"d.f.ip6.arpa":             "fd00::/8",
+		"0.0.0.0.0.0.d.f.ip6.arpa": "fd00::/32",
+		"192.168.1.0/24":           "192.168.1.0/24",
+		"fd00::1/64":               "fd00::/64",
+	} {
+		prefix, err := ParseZone(zone)
+		require.NoError(t, err, zone)
+		assert.Equal(t, expected, prefix.String(), zone)
+	}
+
+	for _, zone := range []string{"example.com", "in-addr.arpa", "300.in-addr.arpa", "1.2.3.4.5.in-addr.arpa", "g.ip6.arpa"} {
+		_, err := ParseZone(zone)
+		require.Error(t, err, zone)
+	}
+}
+
+func TestReverseName(t *testing.T) {
+	t.Parallel()
+
+	for address, name := range map[string]string{
+		"192.168.1.10":    "10.1.168.192.in-addr.arpa",
+		"::ffff:10.0.0.1": "1.0.0.10.in-addr.arpa",
+		"fd00::1":         "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.d.f.ip6.arpa",
+	} {
+		assert.Equal(t, name, ReverseName(netip.MustParseAddr(address)), address)
+
+		parsed, ok := ParseReverseName(name + ".")
+		require.True(t, ok, name)
+		assert.Equal(t, netip.MustParseAddr(address).Unmap(), parsed)
+	}
+
+	_, ok := ParseReverseName("1.168.192.in-addr.arpa")
+	assert.False(t, ok, "zone names are not full reverse names")
+}
+
+func TestManager_Sync(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ptr.hosts")
+	require.NoError(t, os.WriteFile(path, []byte(
+		"192.168.1.10 old.home.lan\n"+
+			"192.168.1.11 printer.office.lan # not managed\n"+
+			"10.0.0.1 outside.home.lan\n"), 0o600))
+
+	manager, err := New([]string{"1.168.192.in-addr.arpa", "fd00::/8"}, NewFileSink(path))
+	require.NoError(t, err)
+
+	owns := func(hostname string) bool {
+		return filepath.Ext(hostname) == ".lan" && hostname != "printer.office.lan"
+	}
+
+	err = manager.Sync(context.Background(), []Pointer{
+		{Address: netip.MustParseAddr("192.168.1.10"), Hostname: "App.home.lan."},
+		{Address: netip.MustParseAddr("192.168.1.10"), Hostname: "web.home.lan"},
+		{Address: netip.MustParseAddr("fd00::10"), Hostname: "app.home.lan"},
+		{Address: netip.MustParseAddr("172.16.0.1"), Hostname: "vpn.home.lan"},
+	}, owns)
+	require.NoError(t, err)
+
+	// Stale managed pointers are removed, others and those outside the zones are kept
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fileHeader+
+		"10.0.0.1 outside.home.lan\n"+
+		"192.168.1.10 app.home.lan web.home.lan\n"+
+		"192.168.1.11 printer.office.lan\n"+
+		"fd00::10 app.home.lan\n", string(content))
+
+	// Deleting an address record removes its pointer
+	err = manager.Sync(context.Background(), []Pointer{
+		{Address: netip.MustParseAddr("192.168.1.10"), Hostname: "web.home.lan"},
+	}, owns)
+	require.NoError(t, err)
+
+	pointers, err := NewFileSink(path).Pointers(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []Pointer{
+		{Address: netip.MustParseAddr("10.0.0.1"), Hostname: "outside.home.lan"},
+		{Address: netip.MustParseAddr("192.168.1.10"), Hostname: "web.home.lan"},
+		{Address: netip.MustParseAddr("192.168.1.11"), Hostname: "printer.office.lan"},
+	}, pointers)
+}
+
+func TestManager_Update(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "ptr.hosts")
+	require.NoError(t, os.WriteFile(path, []byte("192.168.1.10 old.home.lan\n"), 0o600))
+
+	manager, err := New([]string{"192.168.1.0/24"}, NewFileSink(path))
+	require.NoError(t, err)
+
+	// An update keeping the address keeps its pointer; addresses outside the zones are ignored
+	err = manager.Update(context.Background(),
+		[]Pointer{
+			{Address: netip.MustParseAddr("192.168.1.10"), Hostname: "old.home.lan"},
+			{Address: netip.MustParseAddr("192.168.1.20"), Hostname: "nas.home.lan"},
+		},
+		[]Pointer{
+			{Address: netip.MustParseAddr("192.168.1.20"), Hostname: "NAS.home.lan"},
+			{Address: netip.MustParseAddr("192.168.1.30"), Hostname: "app.home.lan"},
+			{Address: netip.MustParseAddr("10.0.0.1"), Hostname: "vpn.home.lan"},
+		})
+	require.NoError(t, err)
+
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, fileHeader+
+		"192.168.1.20 nas.home.lan\n"+
+		"192.168.1.30 app.home.lan\n", string(content))
+}
@@ -0,0 +1,119 @@
+package ptr
+
+import (
+	"context"
+	"slices"
+
+	"github.com/cockroachdb/errors"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+)
+
+// recordTypePTR is the UniFi record type of reverse records. The API client does not
+// define it; Network versions that do not support PTR records reject them.
+const recordTypePTR = "PTR"
+
+// UniFiSink stores reverse records as static DNS records of a UniFi site.
+type UniFiSink struct {
+	client unifi.NetworkAPIClient
+	site   string
+}
+
+// NewUniFiSink creates a sink storing reverse records in site.
+func NewUniFiSink(client unifi.NetworkAPIClient, site string) *UniFiSink {
+	return &UniFiSink{client: client, site: site}
+}
+
+// Pointers returns the PTR records of the site.
+func (s *UniFiSink) Pointers(ctx context.Context) ([]Pointer, error) {
+	records, err := s.records(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pointers := make([]Pointer, 0, len(records))
+	for _, record := range records {
+		pointers = append(pointers, record.pointer)
+	}
+
+	return pointers, nil
+}
+
+// Apply deletes the PTR records to remove and creates the ones to add that do not exist yet.
+func (s *UniFiSink) Apply(ctx context.Context, remove, add []Pointer) error {
+	records, err := s.records(ctx)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+
+	stored := make(map[Pointer]bool, len(records))
+
+	for _, record := range records {
+		if !slices.Contains(remove, record.pointer) {
+			stored[record.pointer] = true
+
+			continue
+		}
+
+		err := s.client.DeleteDNSRecord(ctx, s.site, record.id)
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to delete PTR record %s", ReverseName(record.pointer.Address)))
+		}
+	}
+
+	enabled := true
+
+	for _, pointer := range add {
+		if stored[pointer] {
+			continue
+		}
+
+		_, err := s.client.CreateDNSRecord(ctx, s.site, &unifi.DNSRecordInput{
+			Key:        ReverseName(pointer.Address),
+			RecordType: recordTypePTR,
+			Value:      pointer.Hostname,
+			Enabled:    &enabled,
+		})
+		if err != nil {
+			errs = append(errs, errors.Wrapf(err, "failed to create PTR record %s", ReverseName(pointer.Address)))
+		}
+	}
+
+	//nolint:wrapcheck // Joining wrapped errors
+	return errors.Join(errs...)
+}
+
+// storedPointer is a PTR record of the site with its ID.
+type storedPointer struct {
+	id      string
+	pointer Pointer
+}
+
+// records lists the PTR records of the site.
+func (s *UniFiSink) records(ctx context.Context) ([]storedPointer, error) {
+	records, err := s.client.ListDNSRecords(ctx, s.site)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to list DNS records from UniFi")
+	}
+
+	var pointers []storedPointer
+
+	for _, record := range records {
+		if string(record.RecordType) != recordTypePTR {
+			continue
+		}
+
+		address, ok := ParseReverseName(record.Key)
+		if !ok {
+			continue
+		}
+
+		pointers = append(pointers, storedPointer{
+			id:      record.UnderscoreId,
+			pointer: normalize(Pointer{Address: address, Hostname: record.Value}),
+		})
+	}
+
+	return pointers, nil
+}
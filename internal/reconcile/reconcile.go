@@ -0,0 +1,364 @@
+// Package reconcile applies DNS records declared in files to UniFi without external-dns.
+//
+// Each run reads the desired records, plans the changes against the provider
+// with the external-dns planner, the way the external-dns controller does,
+// and applies them.
+package reconcile
+
+import (
+	"context"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// ErrNoDesiredRecords is returned instead of deleting every managed record when
+// no desired records are found, which usually means the source is missing.
+var ErrNoDesiredRecords = errors.New("no desired records found, refusing to delete all managed records")
+
+// DefaultManagedTypes are the record types managed by default, as in external-dns.
+var DefaultManagedTypes = []string{endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME}
+
+// Reconciler makes the records in UniFi match the records declared in a file or directory.
+type Reconciler struct {
+	provider provider.DNSProvider
+	source   string
+	policy   plan.Policy
+	managed  []string
+	scope    func(name string) bool
+	frozen   func() bool
+}
+
+// Option configures optional Reconciler behavior.
+type Option func(*Reconciler)
+
+// WithPolicy sets the external-dns policy applied to planned changes. The
+// default is sync, which also deletes records that are no longer declared.
+func WithPolicy(policy plan.Policy) Option {
+	return func(r *Reconciler) {
+		r.policy = policy
+	}
+}
+
+// WithManagedTypes sets the record types that are reconciled; records of other
+// types are neither created nor deleted. The default is DefaultManagedTypes.
+func WithManagedTypes(types []string) Option {
+	return func(r *Reconciler) {
+		r.managed = types
+	}
+}
+
+// WithScope limits reconciliation to names for which scope reports true,
+// usually the domain filter. Declared records outside it are ignored.
+func WithScope(scope func(name string) bool) Option {
+	return func(r *Reconciler) {
+		r.scope = scope
+	}
+}
+
+// WithFreeze suspends changes while frozen reports true.
+func WithFreeze(frozen func() bool) Option {
+	return func(r *Reconciler) {
+		r.frozen = frozen
+	}
+}
+
+// New creates a reconciler for the records declared in source, a records file
+// or a directory of them in the format of the records import command.
+func New(prov provider.DNSProvider, source string, opts ...Option) *Reconciler {
+	reconciler := &Reconciler{
+		provider: prov,
+		source:   source,
+		policy:   &plan.SyncPolicy{},
+		managed:  DefaultManagedTypes,
+		scope:    func(string) bool { return true },
+		frozen:   func() bool { return false },
+	}
+
+	for _, opt := range opts {
+		opt(reconciler)
+	}
+
+	return reconciler
+}
+
+// Run reconciles immediately and then every interval until ctx is done.
+func (r *Reconciler) Run(ctx context.Context, interval time.Duration) {
+	r.run(ctx)
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			r.run(ctx)
+		}
+	}
+}
+
+// run runs Reconcile, logging the outcome.
+func (r *Reconciler) run(ctx context.Context) {
+	err := r.Reconcile(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to reconcile DNS records", "source", r.source, "error", err)
+	}
+}
+
+// errFrozen reports a run skipped because DNS changes are frozen.
+var errFrozen = errors.New("DNS changes are frozen")
+
+// Reconcile reads the desired records and applies the changes needed to reach them.
+func (r *Reconciler) Reconcile(ctx context.Context) error {
+	err := r.reconcile(ctx)
+
+	switch {
+	case errors.Is(err, errFrozen):
+		dnsmetrics.ReconcileRuns.WithLabelValues("frozen").Inc()
+
+		return nil
+	case err != nil:
+		dnsmetrics.ReconcileRuns.WithLabelValues("error").Inc()
+
+		return err
+	}
+
+	dnsmetrics.ReconcileRuns.WithLabelValues("success").Inc()
+
+	return nil
+}
+
+func (r *Reconciler) reconcile(ctx context.Context) error {
+	declared, err := Load(r.source)
+	if err != nil {
+		return err
+	}
+
+	desired := make([]records.Record, 0, len(declared))
+
+	for _, record := range declared {
+		switch {
+		case !r.scope(record.Name):
+			slog.WarnContext(ctx, "ignoring desired record outside the domain filter",
+				"name", record.Name, "type", record.Type)
+		case !slices.Contains(r.managed, record.Type):
+			slog.WarnContext(ctx, "ignoring desired record of a type that is not managed",
+				"name", record.Name, "type", record.Type)
+		default:
+			desired = append(desired, record)
+		}
+	}
+
+	dnsmetrics.ReconcileDesiredRecords.Set(float64(len(desired)))
+
+	current, err := r.provider.Records(ctx)
+	if err != nil {
+		return errors.Wrap(err, "failed to read records")
+	}
+
+	// The provider returns one endpoint per UniFi record, the planner expects one per record set
+	currentSets := records.Endpoints(records.FromEndpoints(current))
+
+	desiredSets, err := r.provider.AdjustEndpoints(records.Endpoints(desired))
+	if err != nil {
+		return errors.Wrap(err, "failed to adjust desired records")
+	}
+
+	calculated := (&plan.Plan{
+		Current:        currentSets,
+		Desired:        desiredSets,
+		Policies:       []plan.Policy{r.policy},
+		DomainFilter:   endpoint.MatchAllDomainFilters{scopeFilter(r.scope)},
+		ManagedRecords: r.managed,
+	}).Calculate()
+
+	changes := keepSiblings(calculated.Changes, currentSets)
+	if !changes.HasChanges() {
+		return nil
+	}
+
+	if len(desired) == 0 && len(changes.Delete)+len(changes.UpdateOld) > 0 {
+		return ErrNoDesiredRecords
+	}
+
+	if r.frozen() {
+		slog.InfoContext(ctx, "not reconciling DNS records while DNS changes are frozen", "source", r.source)
+
+		return errFrozen
+	}
+
+	slog.InfoContext(ctx, "reconciling DNS records",
+		"source", r.source,
+		"create", len(changes.Create),
+		"update", len(changes.UpdateNew),
+		"delete", len(changes.Delete))
+
+	err = r.provider.ApplyChanges(ctx, changes)
+	if err != nil {
+		return errors.Wrap(err, "failed to apply changes")
+	}
+
+	return nil
+}
+
+// scopeFilter adapts a scope function to the external-dns domain filter interface.
+type scopeFilter func(name string) bool
+
+// Match implements endpoint.DomainFilterInterface.
+func (f scopeFilter) Match(name string) bool {
+	return f(name)
+}
+
+// keepSiblings recreates the records that share a name with a deleted or
+// updated record. The provider removes all UniFi records of a name, so without
+// this, records of types the plan does not touch would be lost.
+func keepSiblings(changes *plan.Changes, current []*endpoint.Endpoint) *plan.Changes {
+	touched := make(map[string]bool)
+	planned := make(map[string]bool)
+
+	for _, item := range slices.Concat(changes.Delete, changes.UpdateOld) {
+		touched[item.DNSName] = true
+		planned[item.DNSName+"/"+item.RecordType] = true
+	}
+
+	result := &plan.Changes{
+		Create:    changes.Create,
+		UpdateOld: changes.UpdateOld,
+		UpdateNew: changes.UpdateNew,
+	}
+
+	var kept []*endpoint.Endpoint
+
+	for _, item := range current {
+		if touched[item.DNSName] && !planned[item.DNSName+"/"+item.RecordType] {
+			kept = append(kept, item)
+		}
+	}
+
+	if len(kept) == 0 {
+		result.Delete = changes.Delete
+
+		return result
+	}
+
+	keptNames := make(map[string]bool, len(kept))
+	for _, item := range kept {
+		keptNames[item.DNSName] = true
+	}
+
+	// Deletions under a kept name become updates so the name is replaced once
+	for _, item := range changes.Delete {
+		if keptNames[item.DNSName] {
+			result.UpdateOld = append(result.UpdateOld, item)
+		} else {
+			result.Delete = append(result.Delete, item)
+		}
+	}
+
+	result.UpdateOld = append(result.UpdateOld, kept...)
+	result.UpdateNew = append(result.UpdateNew, kept...)
+
+	return result
+}
+
+// Load reads the records declared in a file, or in every records file below a
+// directory. Files and directories starting with a dot are skipped, as are files
+// with other extensions, so a mounted ConfigMap can be used directly. A record
+// declared in more than one file is an error.
+func Load(source string) ([]records.Record, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read desired records")
+	}
+
+	if !info.IsDir() {
+		return loadFile(source)
+	}
+
+	var (
+		result []records.Record
+		origin = make(map[string]string)
+	)
+
+	err = filepath.WalkDir(source, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if path != source && strings.HasPrefix(entry.Name(), ".") {
+			if entry.IsDir() {
+				return filepath.SkipDir
+			}
+
+			return nil
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		// Files in other formats are not records files
+		if _, err := records.FormatFromPath(path); err != nil {
+			//nolint:nilerr // Skipping the file is the intended outcome
+			return nil
+		}
+
+		loaded, err := loadFile(path)
+		if err != nil {
+			return err
+		}
+
+		for _, record := range loaded {
+			key := record.Name + " " + record.Type
+			if previous, ok := origin[key]; ok {
+				//nolint:wrapcheck // Creating new error, not wrapping
+				return errors.Newf("record %s is declared in both %s and %s", key, previous, path)
+			}
+
+			origin[key] = path
+		}
+
+		result = append(result, loaded...)
+
+		return nil
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read desired records")
+	}
+
+	return result, nil
+}
+
+// loadFile decodes and validates one records file.
+func loadFile(path string) ([]records.Record, error) {
+	format, err := records.FormatFromPath(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "unsupported records file %s", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open records file")
+	}
+	defer file.Close()
+
+	loaded, err := records.Decode(file, format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid records file %s", path)
+	}
+
+	return loaded, nil
+}
@@ -0,0 +1,171 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package reconcile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeProvider keeps one endpoint per target in memory and removes all records
+// of a name on delete, like the UniFi provider.
+type fakeProvider struct {
+	mu        sync.Mutex
+	endpoints []*endpoint.Endpoint
+	applied   int
+}
+
+func (f *fakeProvider) Records(context.Context) ([]*endpoint.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return slices.Clone(f.endpoints), nil
+}
+
+func (f *fakeProvider) ApplyChanges(_ context.Context, changes *plan.Changes) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.applied++
+
+	for _, removed := range slices.Concat(changes.Delete, changes.UpdateOld) {
+		f.endpoints = slices.DeleteFunc(f.endpoints, func(item *endpoint.Endpoint) bool {
+			return item.DNSName == removed.DNSName
+		})
+	}
+
+	for _, created := range slices.Concat(changes.Create, changes.UpdateNew) {
+		for _, target := range created.Targets {
+			f.endpoints = append(f.endpoints,
+				endpoint.NewEndpointWithTTL(created.DNSName, created.RecordType, created.RecordTTL, target))
+		}
+	}
+
+	return nil
+}
+
+func (f *fakeProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	return endpoints, nil
+}
+
+// names returns the name, type and target of every endpoint, sorted.
+func (f *fakeProvider) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := make([]string, 0, len(f.endpoints))
+	for _, item := range f.endpoints {
+		result = append(result, item.DNSName+" "+item.RecordType+" "+item.Targets.String())
+	}
+
+	slices.Sort(result)
+
+	return result
+}
+
+// writeFiles creates files below dir, creating parent directories as needed.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o750))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	}
+}
+
+func TestReconcile_Directory(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"hosts.yaml": `
+- name: nas.home.lan
+  type: A
+  targets: [192.168.1.10, 192.168.1.11]
+- name: www.home.lan
+  type: CNAME
+  targets: [nas.home.lan]
+`,
+		"apps/grafana.json": `[{"name": "grafana.home.lan", "type": "A", "ttl": 300, "targets": ["192.168.1.20"]}]`,
+		"README.md":         "Records for the home network",
+		"..data/hosts.yaml": "not records",
+	})
+
+	prov := &fakeProvider{endpoints: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeA, "192.168.1.99"),
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeTXT, "owner=ops"),
+		endpoint.NewEndpoint("old.home.lan", endpoint.RecordTypeA, "192.168.1.50"),
+		endpoint.NewEndpoint("home.lan", endpoint.RecordTypeMX, "10 mail.home.lan"),
+	}}
+
+	reconciler := New(prov, dir)
+
+	ctx := context.Background()
+	require.NoError(t, reconciler.Reconcile(ctx))
+
+	assert.Equal(t, []string{
+		"grafana.home.lan A 192.168.1.20",
+		"home.lan MX 10 mail.home.lan",
+		"nas.home.lan A 192.168.1.10",
+		"nas.home.lan A 192.168.1.11",
+		"nas.home.lan TXT owner=ops",
+		"www.home.lan CNAME nas.home.lan",
+	}, prov.names(), "undeclared records of managed types are deleted, other types are kept")
+
+	applied := prov.applied
+	require.NoError(t, reconciler.Reconcile(ctx))
+	assert.Equal(t, applied, prov.applied, "nothing is applied when records are in sync")
+
+	// The same record in two files is ambiguous
+	writeFiles(t, dir, map[string]string{
+		"more.yaml": "- {name: nas.home.lan, type: A, targets: [192.168.1.12]}\n",
+	})
+	require.Error(t, reconciler.Reconcile(ctx))
+}
+
+func TestReconcile_Policies(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "records.yaml")
+	writeFiles(t, filepath.Dir(path), map[string]string{
+		"records.yaml": "- {name: nas.home.lan, type: A, targets: [192.168.1.10]}\n- {name: nas.other.lan, type: A, targets: [10.0.0.1]}\n",
+	})
+
+	prov := &fakeProvider{endpoints: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("old.home.lan", endpoint.RecordTypeA, "192.168.1.50"),
+	}}
+
+	frozen := true
+	reconciler := New(prov, path,
+		WithPolicy(&plan.UpsertOnlyPolicy{}),
+		WithScope(func(name string) bool { return name == "nas.home.lan" || name == "old.home.lan" }),
+		WithFreeze(func() bool { return frozen }))
+
+	ctx := context.Background()
+	require.NoError(t, reconciler.Reconcile(ctx), "a frozen run is not an error")
+	assert.Zero(t, prov.applied)
+
+	frozen = false
+
+	require.NoError(t, reconciler.Reconcile(ctx))
+	assert.Equal(t, []string{
+		"nas.home.lan A 192.168.1.10",
+		"old.home.lan A 192.168.1.50",
+	}, prov.names(), "upsert-only keeps undeclared records, names out of scope are ignored")
+
+	// An empty source does not wipe the managed records
+	writeFiles(t, filepath.Dir(path), map[string]string{"records.yaml": ""})
+
+	reconciler = New(prov, path)
+	require.ErrorIs(t, reconciler.Reconcile(ctx), ErrNoDesiredRecords)
+	assert.Len(t, prov.names(), 2)
+}
@@ -0,0 +1,220 @@
+package records
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Action describes what an import does to a record.
+type Action string
+
+// Import actions.
+const (
+	ActionCreate   Action = "create"
+	ActionUpdate   Action = "update"
+	ActionDelete   Action = "delete"
+	ActionRecreate Action = "recreate"
+)
+
+// Change is a single record change in an import plan.
+type Change struct {
+	Action  Action
+	Current *Record
+	Desired *Record
+}
+
+// Plan is the set of changes that makes the current records match the desired ones.
+type Plan struct {
+	Changes []Change
+	changes *plan.Changes
+}
+
+// Empty reports whether the plan changes nothing.
+func (p *Plan) Empty() bool {
+	return len(p.Changes) == 0
+}
+
+// ProviderChanges returns the changes to pass to the provider's ApplyChanges.
+func (p *Plan) ProviderChanges() *plan.Changes {
+	return p.changes
+}
+
+// Diff plans an import of desired records over current ones. Records only
+// present in current are deleted when prune is set and kept otherwise. A
+// desired TTL of 0 accepts any current TTL.
+//
+// The provider replaces all records of a name on update, so when any record
+// of a name changes, the unchanged records of that name are recreated too.
+func Diff(current, desired []Record, prune bool) *Plan {
+	currentByName := groupByName(current)
+	desiredByName := groupByName(desired)
+
+	names := slices.Sorted(maps.Keys(currentByName))
+	for name := range desiredByName {
+		if _, ok := currentByName[name]; !ok {
+			names = append(names, name)
+		}
+	}
+
+	slices.Sort(names)
+
+	result := &Plan{changes: &plan.Changes{}}
+
+	for _, name := range names {
+		result.diffName(currentByName[name], desiredByName[name], prune)
+	}
+
+	return result
+}
+
+// diffName plans the changes for the records of a single name.
+func (p *Plan) diffName(current, desired map[string]Record, prune bool) {
+	var (
+		changes  []Change
+		target   []Record
+		modified bool
+	)
+
+	for _, recordType := range slices.Sorted(maps.Keys(unionKeys(current, desired))) {
+		have, hasCurrent := current[recordType]
+		want, hasDesired := desired[recordType]
+
+		switch {
+		case !hasCurrent:
+			changes = append(changes, Change{Action: ActionCreate, Desired: &want})
+			target = append(target, want)
+			modified = true
+		case !hasDesired && prune:
+			changes = append(changes, Change{Action: ActionDelete, Current: &have})
+			modified = true
+		case !hasDesired:
+			changes = append(changes, Change{Action: ActionRecreate, Current: &have, Desired: &have})
+			target = append(target, have)
+		case !equal(have, want):
+			if want.TTL == 0 {
+				want.TTL = have.TTL
+			}
+
+			changes = append(changes, Change{Action: ActionUpdate, Current: &have, Desired: &want})
+			target = append(target, want)
+			modified = true
+		default:
+			changes = append(changes, Change{Action: ActionRecreate, Current: &have, Desired: &have})
+			target = append(target, have)
+		}
+	}
+
+	if !modified {
+		return
+	}
+
+	p.Changes = append(p.Changes, changes...)
+
+	existing := slices.Collect(maps.Values(current))
+	sortRecords(existing)
+
+	switch {
+	case len(current) == 0:
+		p.changes.Create = append(p.changes.Create, Endpoints(target)...)
+	case len(target) == 0:
+		p.changes.Delete = append(p.changes.Delete, Endpoints(existing)...)
+	default:
+		p.changes.UpdateOld = append(p.changes.UpdateOld, Endpoints(existing)...)
+		p.changes.UpdateNew = append(p.changes.UpdateNew, Endpoints(target)...)
+	}
+}
+
+// Write prints the plan as a diff preview, one line per record.
+func (p *Plan) Write(w io.Writer) error {
+	counts := make(map[Action]int)
+
+	for _, change := range p.Changes {
+		counts[change.Action]++
+
+		var line string
+
+		switch change.Action {
+		case ActionCreate:
+			line = "+ " + describe(*change.Desired)
+		case ActionDelete:
+			line = "- " + describe(*change.Current)
+		case ActionUpdate:
+			line = "~ " + describe(*change.Current) + " -> " + describeData(*change.Desired)
+		case ActionRecreate:
+			line = "= " + describe(*change.Current) + " (recreated with its name)"
+		}
+
+		_, err := fmt.Fprintln(w, line)
+		if err != nil {
+			return errors.Wrap(err, "failed to write plan")
+		}
+	}
+
+	_, err := fmt.Fprintf(w, "%d to create, %d to update, %d to delete, %d recreated\n",
+		counts[ActionCreate], counts[ActionUpdate], counts[ActionDelete], counts[ActionRecreate])
+	if err != nil {
+		return errors.Wrap(err, "failed to write plan")
+	}
+
+	return nil
+}
+
+// describe formats a record for the diff preview.
+func describe(record Record) string {
+	return record.Name + " " + record.Type + " " + describeData(record)
+}
+
+// describeData formats the TTL and targets of a record.
+func describeData(record Record) string {
+	data := "[" + strings.Join(record.Targets, ", ") + "]"
+	if record.TTL > 0 {
+		data = fmt.Sprintf("ttl=%d %s", record.TTL, data)
+	}
+
+	return data
+}
+
+// equal reports whether a current record already matches a desired one.
+func equal(current, desired Record) bool {
+	if desired.TTL != 0 && desired.TTL != current.TTL {
+		return false
+	}
+
+	return slices.Equal(current.Targets, desired.Targets)
+}
+
+// groupByName indexes records by name, then type.
+func groupByName(records []Record) map[string]map[string]Record {
+	result := make(map[string]map[string]Record)
+
+	for _, record := range records {
+		if result[record.Name] == nil {
+			result[record.Name] = make(map[string]Record)
+		}
+
+		result[record.Name][record.Type] = record
+	}
+
+	return result
+}
+
+// unionKeys returns the record types present in either map.
+func unionKeys(current, desired map[string]Record) map[string]struct{} {
+	result := make(map[string]struct{}, len(current)+len(desired))
+
+	for key := range current {
+		result[key] = struct{}{}
+	}
+
+	for key := range desired {
+		result[key] = struct{}{}
+	}
+
+	return result
+}
@@ -0,0 +1,112 @@
+package records
+
+import (
+	"encoding/json"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"go.yaml.in/yaml/v3"
+)
+
+// Format is a file format for records.
+type Format string
+
+// Supported formats.
+const (
+	FormatJSON Format = "json"
+	FormatYAML Format = "yaml"
+	FormatZone Format = "zone"
+)
+
+// ParseFormat parses a format name.
+func ParseFormat(name string) (Format, error) {
+	switch Format(strings.ToLower(name)) {
+	case FormatJSON:
+		return FormatJSON, nil
+	case FormatYAML, "yml":
+		return FormatYAML, nil
+	case FormatZone:
+		return FormatZone, nil
+	default:
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return "", errors.Newf("unknown format %q: expected json, yaml or zone", name)
+	}
+}
+
+// FormatFromPath guesses the format from a file extension.
+func FormatFromPath(path string) (Format, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	case ".zone", ".db", ".txt":
+		return FormatZone, nil
+	default:
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return "", errors.Newf("cannot tell the format of %q from its extension, use --format", path)
+	}
+}
+
+// Encode writes records in the given format.
+func Encode(w io.Writer, format Format, records []Record) error {
+	var err error
+
+	switch format {
+	case FormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		err = encoder.Encode(records)
+	case FormatYAML:
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		err = encoder.Encode(records)
+	case FormatZone:
+		err = encodeZone(w, records)
+	default:
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.Newf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %s records", format)
+	}
+
+	return nil
+}
+
+// Decode reads and validates records in the given format.
+func Decode(r io.Reader, format Format) ([]Record, error) {
+	var (
+		records []Record
+		err     error
+	)
+
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		err = decoder.Decode(&records)
+	case FormatYAML:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		err = decoder.Decode(&records)
+
+		if errors.Is(err, io.EOF) {
+			err = nil
+		}
+	case FormatZone:
+		records, err = decodeZone(r)
+	default:
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return nil, errors.Newf("unknown format %q", format)
+	}
+
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read %s records", format)
+	}
+
+	return Validate(records)
+}
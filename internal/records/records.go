@@ -0,0 +1,128 @@
+// Package records converts DNS records between external-dns endpoints and
+// portable file formats, and computes the changes needed to import them.
+package records
+
+import (
+	"cmp"
+	"slices"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// supportedTypes lists the record types UniFi static DNS can hold.
+var supportedTypes = []string{
+	endpoint.RecordTypeA,
+	endpoint.RecordTypeAAAA,
+	endpoint.RecordTypeCNAME,
+	endpoint.RecordTypeMX,
+	endpoint.RecordTypeNS,
+	endpoint.RecordTypeSRV,
+	endpoint.RecordTypeTXT,
+}
+
+// Record is a DNS name and type with all of its targets.
+type Record struct {
+	Name    string   `json:"name"          yaml:"name"`
+	Type    string   `json:"type"          yaml:"type"`
+	TTL     int64    `json:"ttl,omitempty" yaml:"ttl,omitempty"`
+	Targets []string `json:"targets"       yaml:"targets"`
+}
+
+// key identifies a record set.
+func (r Record) key() string {
+	return r.Name + "/" + r.Type
+}
+
+// FromEndpoints groups endpoints into records by name and type, sorted by name and type.
+// UniFi stores one target per record, so the provider returns one endpoint per target.
+func FromEndpoints(endpoints []*endpoint.Endpoint) []Record {
+	byKey := make(map[string]*Record, len(endpoints))
+
+	for _, item := range endpoints {
+		record := Record{Name: normalizeName(item.DNSName), Type: item.RecordType, TTL: int64(item.RecordTTL)}
+
+		existing, ok := byKey[record.key()]
+		if !ok {
+			existing = &record
+			byKey[record.key()] = existing
+		}
+
+		existing.Targets = append(existing.Targets, item.Targets...)
+	}
+
+	result := make([]Record, 0, len(byKey))
+
+	for _, record := range byKey {
+		slices.Sort(record.Targets)
+		record.Targets = slices.Compact(record.Targets)
+		result = append(result, *record)
+	}
+
+	sortRecords(result)
+
+	return result
+}
+
+// Endpoints converts records to endpoints with all targets of a record in one endpoint.
+func Endpoints(records []Record) []*endpoint.Endpoint {
+	result := make([]*endpoint.Endpoint, 0, len(records))
+
+	for _, record := range records {
+		result = append(result, endpoint.NewEndpointWithTTL(record.Name, record.Type, endpoint.TTL(record.TTL), record.Targets...))
+	}
+
+	return result
+}
+
+// Validate normalizes names and types and checks that every record can be stored in UniFi.
+func Validate(records []Record) ([]Record, error) {
+	result := make([]Record, 0, len(records))
+	seen := make(map[string]bool, len(records))
+
+	for idx, record := range records {
+		record.Name = normalizeName(record.Name)
+		record.Type = strings.ToUpper(record.Type)
+
+		switch {
+		case record.Name == "":
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("record %d has no name", idx+1)
+		case !slices.Contains(supportedTypes, record.Type):
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("record %s has unsupported type %q", record.Name, record.Type)
+		case len(record.Targets) == 0:
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("record %s %s has no targets", record.Name, record.Type)
+		case record.TTL < 0:
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("record %s %s has negative TTL", record.Name, record.Type)
+		case seen[record.key()]:
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("record %s %s is listed more than once", record.Name, record.Type)
+		}
+
+		seen[record.key()] = true
+
+		record.Targets = slices.Clone(record.Targets)
+		slices.Sort(record.Targets)
+		result = append(result, record)
+	}
+
+	sortRecords(result)
+
+	return result, nil
+}
+
+// sortRecords orders records by name, then type.
+func sortRecords(records []Record) {
+	slices.SortFunc(records, func(left, right Record) int {
+		return cmp.Or(cmp.Compare(left.Name, right.Name), cmp.Compare(left.Type, right.Type))
+	})
+}
+
+// normalizeName lowercases a DNS name and removes the trailing dot of absolute names.
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
@@ -0,0 +1,173 @@
+package records_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func sample() []records.Record {
+	return []records.Record{
+		{Name: "app.home.lan", Type: "A", TTL: 300, Targets: []string{"192.168.1.10", "192.168.1.11"}},
+		{Name: "app.home.lan", Type: "TXT", Targets: []string{`"heritage=external-dns,external-dns/owner=default"`}},
+		{Name: "www.home.lan", Type: "CNAME", TTL: 60, Targets: []string{"app.home.lan"}},
+	}
+}
+
+func TestFromEndpoints_GroupsTargets(t *testing.T) {
+	t.Parallel()
+
+	got := records.FromEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.home.lan", "CNAME", 60, "app.home.lan"),
+		endpoint.NewEndpointWithTTL("app.home.lan", "A", 300, "192.168.1.11"),
+		endpoint.NewEndpoint("app.home.lan", "TXT", `"heritage=external-dns,external-dns/owner=default"`),
+		endpoint.NewEndpointWithTTL("App.Home.lan.", "A", 300, "192.168.1.10"),
+	})
+
+	assert.Equal(t, sample(), got)
+}
+
+func TestEncodeDecode_RoundTrip(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []records.Format{records.FormatJSON, records.FormatYAML, records.FormatZone} {
+		t.Run(string(format), func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+
+			require.NoError(t, records.Encode(&buf, format, sample()))
+
+			got, err := records.Decode(&buf, format)
+			require.NoError(t, err)
+			assert.Equal(t, sample(), got)
+		})
+	}
+}
+
+func TestDecode_Zone(t *testing.T) {
+	t.Parallel()
+
+	zone := `$ORIGIN home.lan.
+$TTL 600
+; comment line
+@            IN  NS    ns1
+app     300  IN  A     192.168.1.10 ; trailing comment
+             IN  A     192.168.1.11
+www          IN  CNAME app
+txt          IN  TXT   "a;b" "c\"d"
+mail.other.lan. 60 A   10.0.0.1
+`
+
+	got, err := records.Decode(strings.NewReader(zone), records.FormatZone)
+	require.NoError(t, err)
+
+	assert.Equal(t, []records.Record{
+		{Name: "app.home.lan", Type: "A", TTL: 300, Targets: []string{"192.168.1.10", "192.168.1.11"}},
+		{Name: "home.lan", Type: "NS", TTL: 600, Targets: []string{"ns1.home.lan"}},
+		{Name: "mail.other.lan", Type: "A", TTL: 60, Targets: []string{"10.0.0.1"}},
+		{Name: "txt.home.lan", Type: "TXT", TTL: 600, Targets: []string{`a;bc"d`}},
+		{Name: "www.home.lan", Type: "CNAME", TTL: 600, Targets: []string{"app.home.lan"}},
+	}, got)
+}
+
+func TestDecode_Invalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		format records.Format
+		input  string
+		want   string
+	}{
+		{"unsupported type", records.FormatYAML, "- {name: a.lan, type: SOA, targets: [x]}", "unsupported type"},
+		{"no targets", records.FormatJSON, `[{"name":"a.lan","type":"A","targets":[]}]`, "no targets"},
+		{"duplicate", records.FormatYAML, "- {name: a.lan, type: A, targets: [1.1.1.1]}\n- {name: A.lan., type: a, targets: [1.1.1.2]}", "more than once"},
+		{"unknown field", records.FormatJSON, `[{"name":"a.lan","type":"A","targets":["1.1.1.1"],"ip":"x"}]`, "unknown field"},
+		{"multi-line zone", records.FormatZone, "a.lan. IN SOA ns. host. (1 2 3 4 5)", "multi-line"},
+		{"zone without type", records.FormatZone, "a.lan. 300 IN", "no type"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			_, err := records.Decode(strings.NewReader(tt.input), tt.format)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.want)
+		})
+	}
+}
+
+func TestFormatFromPath(t *testing.T) {
+	t.Parallel()
+
+	format, err := records.FormatFromPath("backup.yml")
+	require.NoError(t, err)
+	assert.Equal(t, records.FormatYAML, format)
+
+	format, err = records.FormatFromPath("home.lan.zone")
+	require.NoError(t, err)
+	assert.Equal(t, records.FormatZone, format)
+
+	_, err = records.FormatFromPath("records")
+	require.Error(t, err)
+}
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+
+	current := sample()
+	desired := []records.Record{
+		// Same targets without a TTL: unchanged
+		{Name: "www.home.lan", Type: "CNAME", Targets: []string{"app.home.lan"}},
+		// New target for a name that also has a TXT record
+		{Name: "app.home.lan", Type: "A", TTL: 300, Targets: []string{"192.168.1.10"}},
+		{Name: "new.home.lan", Type: "A", Targets: []string{"192.168.1.20"}},
+	}
+
+	changes := records.Diff(current, desired, false)
+
+	actions := make([]records.Action, 0, len(changes.Changes))
+	for _, change := range changes.Changes {
+		actions = append(actions, change.Action)
+	}
+
+	// The TXT record shares the updated name and is recreated with it
+	assert.Equal(t, []records.Action{records.ActionUpdate, records.ActionRecreate, records.ActionCreate}, actions)
+
+	providerChanges := changes.ProviderChanges()
+	assert.Len(t, providerChanges.Create, 1)
+	assert.Len(t, providerChanges.UpdateOld, 2)
+	assert.Len(t, providerChanges.UpdateNew, 2)
+	assert.Empty(t, providerChanges.Delete)
+
+	var preview bytes.Buffer
+
+	require.NoError(t, changes.Write(&preview))
+	assert.Contains(t, preview.String(), "~ app.home.lan A ttl=300 [192.168.1.10, 192.168.1.11] -> ttl=300 [192.168.1.10]")
+	assert.Contains(t, preview.String(), "1 to create, 1 to update, 0 to delete, 1 recreated")
+}
+
+func TestDiff_Prune(t *testing.T) {
+	t.Parallel()
+
+	desired := []records.Record{
+		{Name: "app.home.lan", Type: "A", TTL: 300, Targets: []string{"192.168.1.10", "192.168.1.11"}},
+	}
+
+	changes := records.Diff(sample(), desired, true)
+
+	providerChanges := changes.ProviderChanges()
+	assert.Len(t, providerChanges.Delete, 1, "www has no desired records left")
+	assert.Len(t, providerChanges.UpdateOld, 2, "app loses its TXT record")
+	assert.Len(t, providerChanges.UpdateNew, 1)
+
+	assert.True(t, records.Diff(sample(), sample(), true).Empty())
+	assert.True(t, records.Diff(sample(), desired, false).Empty())
+}
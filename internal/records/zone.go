@@ -0,0 +1,305 @@
+package records
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// hostTargetTypes hold a single host name as target, written as an absolute name in zone files.
+var hostTargetTypes = map[string]bool{
+	endpoint.RecordTypeCNAME: true,
+	endpoint.RecordTypeNS:    true,
+}
+
+// encodeZone writes records as RFC 1035 master file lines with absolute names.
+func encodeZone(w io.Writer, records []Record) error {
+	for _, record := range records {
+		for _, target := range record.Targets {
+			ttl := ""
+			if record.TTL > 0 {
+				ttl = strconv.FormatInt(record.TTL, 10)
+			}
+
+			_, err := fmt.Fprintf(w, "%s.\t%s\tIN\t%s\t%s\n", record.Name, ttl, record.Type, zoneData(record.Type, target))
+			if err != nil {
+				return errors.Wrap(err, "failed to write zone line")
+			}
+		}
+	}
+
+	return nil
+}
+
+// zoneData formats a target as zone file record data.
+func zoneData(recordType, target string) string {
+	switch {
+	case recordType == endpoint.RecordTypeTXT:
+		return quoteTXT(target)
+	case hostTargetTypes[recordType]:
+		return strings.TrimSuffix(target, ".") + "."
+	default:
+		return target
+	}
+}
+
+// zoneParser holds the state carried between zone file lines.
+type zoneParser struct {
+	origin   string
+	ttl      int64
+	lastName string
+	byKey    map[string]*Record
+	order    []string
+}
+
+// decodeZone reads an RFC 1035 master file. It supports $ORIGIN, $TTL, "@",
+// relative names, lines that continue the previous owner name, and optional
+// TTL and class fields. Parenthesized multi-line records are not supported.
+func decodeZone(r io.Reader) ([]Record, error) {
+	parser := &zoneParser{byKey: make(map[string]*Record)}
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+
+	for scanner.Scan() {
+		lineNumber++
+
+		err := parser.parseLine(scanner.Text())
+		if err != nil {
+			return nil, errors.Wrapf(err, "line %d", lineNumber)
+		}
+	}
+
+	err := scanner.Err()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read zone file")
+	}
+
+	result := make([]Record, 0, len(parser.order))
+	for _, key := range parser.order {
+		result = append(result, *parser.byKey[key])
+	}
+
+	return result, nil
+}
+
+// parseLine parses one zone file line.
+func (p *zoneParser) parseLine(line string) error {
+	line = stripComment(line)
+	if strings.TrimSpace(line) == "" {
+		return nil
+	}
+
+	if strings.ContainsAny(line, "()") {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("multi-line records are not supported")
+	}
+
+	continuation := line[0] == ' ' || line[0] == '\t'
+	fields := splitFields(line)
+
+	switch strings.ToUpper(fields[0]) {
+	case "$ORIGIN":
+		if len(fields) != 2 {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return errors.New("$ORIGIN needs one domain name")
+		}
+
+		p.origin = normalizeName(p.absolute(fields[1]))
+
+		return nil
+	case "$TTL":
+		if len(fields) != 2 {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return errors.New("$TTL needs one value")
+		}
+
+		ttl, err := strconv.ParseInt(fields[1], 10, 64)
+		if err != nil || ttl < 0 {
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return errors.Newf("invalid $TTL %q", fields[1])
+		}
+
+		p.ttl = ttl
+
+		return nil
+	}
+
+	name := p.lastName
+	if !continuation {
+		name = p.absolute(fields[0])
+		fields = fields[1:]
+	}
+
+	if name == "" {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.New("record has no owner name")
+	}
+
+	p.lastName = name
+
+	ttl := p.ttl
+
+	// TTL and class may appear in either order before the type.
+	for len(fields) > 0 {
+		if value, err := strconv.ParseInt(fields[0], 10, 64); err == nil && value >= 0 {
+			ttl = value
+		} else if !strings.EqualFold(fields[0], "IN") {
+			break
+		}
+
+		fields = fields[1:]
+	}
+
+	if len(fields) < 2 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.Newf("record %s has no type or data", name)
+	}
+
+	recordType := strings.ToUpper(fields[0])
+	data := strings.Join(fields[1:], " ")
+
+	switch {
+	case recordType == endpoint.RecordTypeTXT:
+		data = unquoteTXT(fields[1:])
+	case hostTargetTypes[recordType]:
+		data = normalizeName(p.absolute(data))
+	}
+
+	p.add(Record{Name: normalizeName(name), Type: recordType, TTL: ttl, Targets: []string{data}})
+
+	return nil
+}
+
+// add merges a single-target record into its record set.
+func (p *zoneParser) add(record Record) {
+	existing, ok := p.byKey[record.key()]
+	if !ok {
+		p.byKey[record.key()] = &record
+		p.order = append(p.order, record.key())
+
+		return
+	}
+
+	existing.Targets = append(existing.Targets, record.Targets...)
+}
+
+// absolute resolves "@" and relative names against the current origin.
+func (p *zoneParser) absolute(name string) string {
+	switch {
+	case name == "@":
+		return p.origin
+	case strings.HasSuffix(name, "."):
+		return name
+	case p.origin == "":
+		return name
+	default:
+		return name + "." + p.origin
+	}
+}
+
+// stripComment removes a ";" comment that is not inside a quoted string.
+func stripComment(line string) string {
+	quoted := false
+
+	for idx := 0; idx < len(line); idx++ {
+		switch line[idx] {
+		case '\\':
+			idx++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:idx]
+			}
+		}
+	}
+
+	return line
+}
+
+// splitFields splits a line on whitespace, keeping quoted strings and escapes together.
+func splitFields(line string) []string {
+	var (
+		fields  []string
+		current strings.Builder
+		quoted  bool
+	)
+
+	flush := func() {
+		if current.Len() > 0 {
+			fields = append(fields, current.String())
+			current.Reset()
+		}
+	}
+
+	for idx := 0; idx < len(line); idx++ {
+		char := line[idx]
+
+		switch {
+		case char == '\\' && idx+1 < len(line):
+			current.WriteByte(char)
+
+			idx++
+			current.WriteByte(line[idx])
+		case char == '"':
+			quoted = !quoted
+
+			current.WriteByte(char)
+		case !quoted && (char == ' ' || char == '\t'):
+			flush()
+		default:
+			current.WriteByte(char)
+		}
+	}
+
+	flush()
+
+	return fields
+}
+
+// quoteTXT writes a TXT target as one character string, escaping quotes and backslashes.
+// Quotes inside the target, like those of external-dns registry records, are kept.
+func quoteTXT(target string) string {
+	escaped := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(target)
+
+	return `"` + escaped + `"`
+}
+
+// unquoteTXT joins the character strings of a TXT record, resolving \X and \DDD escapes.
+func unquoteTXT(fields []string) string {
+	var result strings.Builder
+
+	for _, field := range fields {
+		if len(field) >= 2 && strings.HasPrefix(field, `"`) && strings.HasSuffix(field, `"`) {
+			field = field[1 : len(field)-1]
+		}
+
+		for idx := 0; idx < len(field); idx++ {
+			if field[idx] != '\\' || idx+1 == len(field) {
+				result.WriteByte(field[idx])
+
+				continue
+			}
+
+			if idx+3 < len(field) {
+				if code, err := strconv.ParseUint(field[idx+1:idx+4], 10, 8); err == nil {
+					result.WriteByte(byte(code))
+
+					idx += 3
+
+					continue
+				}
+			}
+
+			idx++
+			result.WriteByte(field[idx])
+		}
+	}
+
+	return result.String()
+}
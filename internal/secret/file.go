@@ -0,0 +1,96 @@
+// Package secret loads secrets from files that may be rotated while the process runs.
+package secret
+
+import (
+	"bytes"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// recheckInterval limits how often the file is stat'ed for changes.
+const recheckInterval = 10 * time.Second
+
+// File is a secret read from disk and re-read when the file changes.
+// Kubernetes secret volumes rotate files by swapping symlinks, which changes
+// the modification time seen through the mounted path.
+type File struct {
+	path string
+
+	mu        sync.Mutex
+	value     []byte
+	modTime   time.Time
+	checkedAt time.Time
+}
+
+// NewFile reads the secret at path. Surrounding whitespace is trimmed.
+func NewFile(path string) (*File, error) {
+	file := &File{path: path}
+
+	err := file.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return file, nil
+}
+
+// Path returns the file path the secret is read from.
+func (f *File) Path() string {
+	return f.path
+}
+
+// Value returns the current secret, re-reading the file if it changed.
+// If a changed file cannot be read, the previous value is kept.
+func (f *File) Value() []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if time.Since(f.checkedAt) >= recheckInterval {
+		f.checkedAt = time.Now()
+
+		info, err := os.Stat(f.path)
+		if err == nil && !info.ModTime().Equal(f.modTime) {
+			_ = f.reloadLocked()
+		}
+	}
+
+	return f.value
+}
+
+// Reload re-reads the secret file immediately. If it cannot be read,
+// the previous value is kept and the error is returned.
+func (f *File) Reload() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.checkedAt = time.Now()
+
+	return f.reloadLocked()
+}
+
+// reloadLocked reads the secret file. The caller must hold f.mu.
+func (f *File) reloadLocked() error {
+	info, err := os.Stat(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to stat secret file %s", f.path)
+	}
+
+	content, err := os.ReadFile(f.path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read secret file %s", f.path)
+	}
+
+	value := bytes.TrimSpace(content)
+	if len(value) == 0 {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return errors.Newf("secret file %s is empty", f.path)
+	}
+
+	f.value = value
+	f.modTime = info.ModTime()
+
+	return nil
+}
@@ -0,0 +1,74 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeSecret(t *testing.T, path, content string, modTime time.Time) {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	require.NoError(t, os.Chtimes(path, modTime, modTime))
+}
+
+func TestNewFile(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	writeSecret(t, path, "  s3cret\n\n", time.Now())
+
+	file, err := NewFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, path, file.Path())
+	assert.Equal(t, []byte("s3cret"), file.Value(), "surrounding whitespace is trimmed")
+}
+
+func TestNewFile_Invalid(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+
+	_, err := NewFile(filepath.Join(dir, "missing"))
+	require.ErrorIs(t, err, os.ErrNotExist)
+
+	empty := filepath.Join(dir, "empty")
+	writeSecret(t, empty, " \n", time.Now())
+
+	_, err = NewFile(empty)
+	require.ErrorContains(t, err, "is empty")
+}
+
+func TestFile_Reload(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "token")
+	start := time.Now().Add(-time.Hour)
+	writeSecret(t, path, "first", start)
+
+	file, err := NewFile(path)
+	require.NoError(t, err)
+
+	// Changes are not noticed before the recheck interval passes
+	writeSecret(t, path, "second", start.Add(time.Minute))
+	assert.Equal(t, []byte("first"), file.Value())
+
+	// A rotated file is picked up once the interval passed
+	file.checkedAt = time.Now().Add(-recheckInterval)
+	assert.Equal(t, []byte("second"), file.Value())
+
+	// A file that cannot be read keeps the previous value
+	require.NoError(t, os.Remove(path))
+	require.Error(t, file.Reload())
+	assert.Equal(t, []byte("second"), file.Value())
+
+	writeSecret(t, path, "\n", start.Add(2*time.Minute))
+	require.Error(t, file.Reload())
+	assert.Equal(t, []byte("second"), file.Value())
+}
@@ -0,0 +1,120 @@
+package static
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// Guarded is the provider as external-dns sees it when static records are declared.
+type Guarded struct {
+	provider provider.DNSProvider
+	store    *Store
+	match    func(name string) bool
+}
+
+// Guard wraps prov so that declared records show up in Records, external-dns
+// changes to declared names are refused, and conflicting endpoints are reported.
+// match reports whether a name is in the domain filter; declared records outside
+// it are reconciled but not shown to external-dns.
+func (s *Store) Guard(prov provider.DNSProvider, match func(name string) bool) *Guarded {
+	return &Guarded{provider: prov, store: s, match: match}
+}
+
+// Records returns the records of the provider with declared records replacing
+// those of the same name and type, so external-dns sees the declared state even
+// before it is reconciled.
+func (g *Guarded) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	endpoints, err := g.provider.Records(ctx)
+	if err != nil {
+		//nolint:wrapcheck // Passing through the provider error unchanged
+		return nil, err
+	}
+
+	declared := g.store.Declared()
+
+	keys := make(map[string]bool, len(declared))
+	for _, record := range declared {
+		keys[record.Name+"/"+record.Type] = true
+	}
+
+	result := make([]*endpoint.Endpoint, 0, len(endpoints)+len(declared))
+
+	for _, item := range endpoints {
+		if !keys[normalizeName(item.DNSName)+"/"+item.RecordType] {
+			result = append(result, item)
+		}
+	}
+
+	for _, record := range declared {
+		if g.match(record.Name) {
+			result = append(result, records.Endpoints([]records.Record{record})...)
+		}
+	}
+
+	return result, nil
+}
+
+// ApplyChanges applies changes without those touching declared names. The
+// provider replaces all records of a name, so any change to a declared name
+// could remove a declared record.
+func (g *Guarded) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	filtered := &plan.Changes{
+		Create:    g.drop(ctx, changes.Create, "create"),
+		UpdateOld: g.drop(ctx, changes.UpdateOld, ""),
+		UpdateNew: g.drop(ctx, changes.UpdateNew, "update"),
+		Delete:    g.drop(ctx, changes.Delete, "delete"),
+	}
+
+	//nolint:wrapcheck // Passing through the provider error unchanged
+	return g.provider.ApplyChanges(ctx, filtered)
+}
+
+// AdjustEndpoints reports endpoints that conflict with declared records and
+// passes them on unchanged.
+func (g *Guarded) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	g.store.check(context.Background(), endpoints)
+
+	//nolint:wrapcheck // Passing through the provider error unchanged
+	return g.provider.AdjustEndpoints(endpoints)
+}
+
+// drop removes endpoints with declared names, logging and counting each one
+// unless operation is empty.
+func (g *Guarded) drop(ctx context.Context, endpoints []*endpoint.Endpoint, operation string) []*endpoint.Endpoint {
+	if len(endpoints) == 0 {
+		return nil
+	}
+
+	allowed := make([]*endpoint.Endpoint, 0, len(endpoints))
+
+	for _, item := range endpoints {
+		if !g.store.declares(normalizeName(item.DNSName)) {
+			allowed = append(allowed, item)
+
+			continue
+		}
+
+		if operation != "" {
+			slog.WarnContext(ctx, "refusing to change a record declared in the static records file",
+				"operation", operation,
+				"name", item.DNSName,
+				"type", item.RecordType)
+
+			dnsmetrics.StaticRecordsBlocked.WithLabelValues(operation).Inc()
+		}
+	}
+
+	return allowed
+}
+
+// normalizeName lowercases a DNS name and strips the trailing root dot.
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
@@ -0,0 +1,459 @@
+// Package static manages DNS records declared in a file alongside external-dns.
+//
+// The declared records are reconciled into UniFi through the provider, added to
+// the records external-dns sees, and guarded so that external-dns plans cannot
+// change or delete them. Endpoints from external-dns that want something else
+// for a declared name are reported as conflicts.
+package static
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"maps"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/records"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// watchInterval is how often the file is checked for changes.
+const watchInterval = 5 * time.Second
+
+// Provider reads and changes DNS records; *provider.UniFiProvider implements it.
+type Provider interface {
+	RecordsIn(ctx context.Context, domainFilter endpoint.DomainFilter) ([]*endpoint.Endpoint, error)
+	ApplyChanges(ctx context.Context, changes *plan.Changes) error
+}
+
+// Conflict is an endpoint from external-dns for a name declared in the file.
+type Conflict struct {
+	Declared []records.Record `json:"declared"`
+	Wanted   records.Record   `json:"wanted"`
+}
+
+// Report is the state of the static records served over HTTP.
+type Report struct {
+	File      string           `json:"file"`
+	Records   []records.Record `json:"records"`
+	Conflicts []Conflict       `json:"conflicts"`
+	SyncedAt  time.Time        `json:"synced_at,omitzero"`
+	Error     string           `json:"error,omitempty"`
+}
+
+// Store holds the records declared in a file and reconciles them into UniFi.
+type Store struct {
+	provider Provider
+	path     string
+	frozen   func() bool
+
+	mu sync.Mutex
+	// declared maps each declared name to its records by type.
+	declared map[string]map[string]records.Record
+	// removed holds the types dropped from each name whose records are still to be deleted.
+	removed   map[string]map[string]struct{}
+	conflicts []Conflict
+	syncedAt  time.Time
+	syncErr   error
+}
+
+// Option configures optional Store behavior.
+type Option func(*Store)
+
+// WithFreeze suspends changes while frozen reports true.
+func WithFreeze(frozen func() bool) Option {
+	return func(s *Store) {
+		s.frozen = frozen
+	}
+}
+
+// New reads the records declared in path. The format follows the file
+// extension, like the records import command; see records.FormatFromPath.
+func New(prov Provider, path string, opts ...Option) (*Store, error) {
+	store := &Store{
+		provider: prov,
+		path:     path,
+		frozen:   func() bool { return false },
+		removed:  make(map[string]map[string]struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(store)
+	}
+
+	err := store.Load()
+	if err != nil {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// Load reads the file again. If it is invalid, the error is returned and the
+// records read before are kept. Records dropped from the file are deleted at the next sync.
+func (s *Store) Load() error {
+	declared, err := readFile(s.path)
+	if err != nil {
+		return err
+	}
+
+	byName := make(map[string]map[string]records.Record, len(declared))
+	for _, record := range declared {
+		if byName[record.Name] == nil {
+			byName[record.Name] = make(map[string]records.Record)
+		}
+
+		byName[record.Name][record.Type] = record
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for name, types := range s.declared {
+		for recordType := range types {
+			if _, ok := byName[name][recordType]; ok {
+				continue
+			}
+
+			if s.removed[name] == nil {
+				s.removed[name] = make(map[string]struct{})
+			}
+
+			s.removed[name][recordType] = struct{}{}
+		}
+	}
+
+	// Declaring a record again cancels its pending deletion
+	for name, types := range byName {
+		for recordType := range types {
+			delete(s.removed[name], recordType)
+		}
+
+		if len(s.removed[name]) == 0 {
+			delete(s.removed, name)
+		}
+	}
+
+	s.declared = byName
+	dnsmetrics.StaticRecords.Set(float64(len(declared)))
+
+	return nil
+}
+
+// readFile decodes and validates the declared records.
+func readFile(path string) ([]records.Record, error) {
+	format, err := records.FormatFromPath(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "unsupported static records file")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to open static records file")
+	}
+	defer file.Close()
+
+	declared, err := records.Decode(file, format)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid static records file %s", path)
+	}
+
+	return declared, nil
+}
+
+// Run reconciles every interval and whenever the file changes, until ctx is done.
+func (s *Store) Run(ctx context.Context, interval time.Duration) {
+	s.sync(ctx)
+
+	syncTicker := time.NewTicker(interval)
+	defer syncTicker.Stop()
+
+	watchTicker := time.NewTicker(watchInterval)
+	defer watchTicker.Stop()
+
+	modTime := fileModTime(s.path)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-syncTicker.C:
+		case <-watchTicker.C:
+			latest := fileModTime(s.path)
+			if latest.Equal(modTime) {
+				continue
+			}
+
+			modTime = latest
+
+			err := s.Load()
+			if err != nil {
+				slog.ErrorContext(ctx, "invalid static records file, keeping the records read before",
+					"file", s.path, "error", err)
+
+				continue
+			}
+
+			slog.InfoContext(ctx, "static records file reloaded", "file", s.path)
+		}
+
+		s.sync(ctx)
+	}
+}
+
+// sync runs Sync, logging and recording the outcome.
+func (s *Store) sync(ctx context.Context) {
+	err := s.Sync(ctx)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to sync static records", "file", s.path, "error", err)
+	}
+}
+
+// errFrozen reports a sync skipped because DNS changes are frozen.
+var errFrozen = errors.New("DNS changes are frozen")
+
+// Sync makes the declared records in UniFi match the file and deletes the
+// records dropped from it. Other records under a declared name are kept.
+func (s *Store) Sync(ctx context.Context) error {
+	err := s.syncOnce(ctx)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch {
+	case errors.Is(err, errFrozen):
+		dnsmetrics.StaticRecordSyncs.WithLabelValues("frozen").Inc()
+
+		return nil
+	case err != nil:
+		dnsmetrics.StaticRecordSyncs.WithLabelValues("error").Inc()
+
+		s.syncErr = err
+
+		return err
+	}
+
+	dnsmetrics.StaticRecordSyncs.WithLabelValues("success").Inc()
+
+	s.syncErr = nil
+	s.syncedAt = time.Now()
+
+	return nil
+}
+
+func (s *Store) syncOnce(ctx context.Context) error {
+	s.mu.Lock()
+	declared := maps.Clone(s.declared)
+
+	removed := make(map[string]map[string]struct{}, len(s.removed))
+	for name, types := range s.removed {
+		removed[name] = maps.Clone(types)
+	}
+	s.mu.Unlock()
+
+	// The provider replaces all records of a name, so whole names are planned
+	names := slices.Concat(slices.Collect(maps.Keys(declared)), slices.Collect(maps.Keys(removed)))
+	if len(names) == 0 {
+		return nil
+	}
+
+	endpoints, err := s.provider.RecordsIn(ctx, *endpoint.NewDomainFilter(names))
+	if err != nil {
+		return errors.Wrap(err, "failed to read records")
+	}
+
+	var current, desired []records.Record
+
+	for _, record := range records.FromEndpoints(endpoints) {
+		types, isDeclared := declared[record.Name]
+		dropped, isRemoved := removed[record.Name]
+
+		if !isDeclared && !isRemoved {
+			continue
+		}
+
+		current = append(current, record)
+
+		_, declaredType := types[record.Type]
+		_, droppedType := dropped[record.Type]
+
+		if !declaredType && !droppedType {
+			desired = append(desired, record)
+		}
+	}
+
+	for _, types := range declared {
+		desired = append(desired, slices.Collect(maps.Values(types))...)
+	}
+
+	changes := records.Diff(current, desired, true)
+	if !changes.Empty() {
+		if s.frozen() {
+			slog.InfoContext(ctx, "not syncing static records while DNS changes are frozen",
+				"changes", len(changes.Changes))
+
+			return errFrozen
+		}
+
+		slog.InfoContext(ctx, "syncing static records", "changes", len(changes.Changes))
+
+		err = s.provider.ApplyChanges(ctx, changes.ProviderChanges())
+		if err != nil {
+			return errors.Wrap(err, "failed to apply changes")
+		}
+	}
+
+	// Deletions planned from this snapshot are done; the file may have changed meanwhile
+	s.mu.Lock()
+	for name, types := range removed {
+		for recordType := range types {
+			if _, ok := s.declared[name][recordType]; !ok {
+				delete(s.removed[name], recordType)
+			}
+		}
+
+		if len(s.removed[name]) == 0 {
+			delete(s.removed, name)
+		}
+	}
+	s.mu.Unlock()
+
+	return nil
+}
+
+// Declared returns the declared records, sorted by name and type.
+func (s *Store) Declared() []records.Record {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.declaredLocked()
+}
+
+func (s *Store) declaredLocked() []records.Record {
+	var result []records.Record
+
+	for _, name := range slices.Sorted(maps.Keys(s.declared)) {
+		types := s.declared[name]
+		for _, recordType := range slices.Sorted(maps.Keys(types)) {
+			result = append(result, types[recordType])
+		}
+	}
+
+	return result
+}
+
+// declares reports whether the name is declared in the file.
+func (s *Store) declares(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.declared[name]
+
+	return ok
+}
+
+// check compares endpoints from external-dns with the declared records and
+// remembers the conflicts. An endpoint conflicts when its name is declared
+// and it is not exactly one of the declared records.
+func (s *Store) check(ctx context.Context, endpoints []*endpoint.Endpoint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var conflicts []Conflict
+
+	for _, wanted := range records.FromEndpoints(endpoints) {
+		types, ok := s.declared[wanted.Name]
+		if !ok {
+			continue
+		}
+
+		declared, ok := types[wanted.Type]
+		if ok && slices.Equal(declared.Targets, wanted.Targets) && (wanted.TTL == 0 || declared.TTL == 0 || wanted.TTL == declared.TTL) {
+			continue
+		}
+
+		sameName := make([]records.Record, 0, len(types))
+		for _, recordType := range slices.Sorted(maps.Keys(types)) {
+			sameName = append(sameName, types[recordType])
+		}
+
+		conflicts = append(conflicts, Conflict{Declared: sameName, Wanted: wanted})
+	}
+
+	// Log when the set of conflicts changes rather than on every external-dns loop
+	if !slices.EqualFunc(conflicts, s.conflicts, conflictEqual) {
+		for _, conflict := range conflicts {
+			slog.WarnContext(ctx, "external-dns endpoint conflicts with the static records file",
+				"name", conflict.Wanted.Name,
+				"type", conflict.Wanted.Type,
+				"targets", conflict.Wanted.Targets,
+				"file", s.path)
+		}
+	}
+
+	s.conflicts = conflicts
+	dnsmetrics.StaticRecordConflicts.Set(float64(len(conflicts)))
+}
+
+// conflictEqual reports whether two conflicts are about the same wanted record.
+func conflictEqual(left, right Conflict) bool {
+	return left.Wanted.Name == right.Wanted.Name &&
+		left.Wanted.Type == right.Wanted.Type &&
+		left.Wanted.TTL == right.Wanted.TTL &&
+		slices.Equal(left.Wanted.Targets, right.Wanted.Targets)
+}
+
+// ServeHTTP returns the declared records, the conflicts found in the latest
+// endpoints from external-dns, and the outcome of the latest sync.
+func (s *Store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", "GET")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+
+		return
+	}
+
+	s.mu.Lock()
+
+	report := Report{
+		File:      s.path,
+		Records:   s.declaredLocked(),
+		Conflicts: slices.Clone(s.conflicts),
+		SyncedAt:  s.syncedAt,
+	}
+
+	if s.syncErr != nil {
+		report.Error = s.syncErr.Error()
+	}
+
+	s.mu.Unlock()
+
+	if report.Records == nil {
+		report.Records = []records.Record{}
+	}
+
+	if report.Conflicts == nil {
+		report.Conflicts = []Conflict{}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	_ = json.NewEncoder(w).Encode(report)
+}
+
+// fileModTime returns the modification time of path, or the zero time if it cannot be read.
+func fileModTime(path string) time.Time {
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+
+	return info.ModTime()
+}
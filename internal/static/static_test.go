@@ -0,0 +1,243 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package static
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// fakeProvider keeps endpoints in memory and replaces all records of a name on
+// delete, like the UniFi provider.
+type fakeProvider struct {
+	mu        sync.Mutex
+	endpoints []*endpoint.Endpoint
+	applied   []*plan.Changes
+}
+
+func (f *fakeProvider) RecordsIn(_ context.Context, domainFilter endpoint.DomainFilter) ([]*endpoint.Endpoint, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result []*endpoint.Endpoint
+
+	for _, item := range f.endpoints {
+		if domainFilter.Match(item.DNSName) {
+			result = append(result, item)
+		}
+	}
+
+	return result, nil
+}
+
+func (f *fakeProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	return f.RecordsIn(ctx, endpoint.DomainFilter{})
+}
+
+func (f *fakeProvider) ApplyChanges(_ context.Context, changes *plan.Changes) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.applied = append(f.applied, changes)
+
+	for _, removed := range slices.Concat(changes.Delete, changes.UpdateOld) {
+		f.endpoints = slices.DeleteFunc(f.endpoints, func(item *endpoint.Endpoint) bool {
+			return item.DNSName == removed.DNSName
+		})
+	}
+
+	f.endpoints = append(f.endpoints, slices.Concat(changes.Create, changes.UpdateNew)...)
+
+	return nil
+}
+
+func (f *fakeProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	return endpoints, nil
+}
+
+// names returns the name, type and targets of every endpoint, sorted.
+func (f *fakeProvider) names() []string {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	result := make([]string, 0, len(f.endpoints))
+	for _, item := range f.endpoints {
+		result = append(result, item.DNSName+" "+item.RecordType+" "+item.Targets.String())
+	}
+
+	slices.Sort(result)
+
+	return result
+}
+
+// writeFile writes a static records file and returns its path.
+func writeFile(t *testing.T, path, content string) string {
+	t.Helper()
+
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+
+	return path
+}
+
+func TestSync_ReconcilesDeclaredRecords(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, filepath.Join(t.TempDir(), "static.yaml"), `
+- name: nas.home.lan
+  type: A
+  ttl: 300
+  targets: [192.168.1.10]
+- name: printer.home.lan
+  type: A
+  targets: [192.168.1.20]
+`)
+
+	prov := &fakeProvider{endpoints: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeA, "192.168.1.99"),
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeTXT, "owner=me"),
+		endpoint.NewEndpoint("app.home.lan", endpoint.RecordTypeA, "192.168.1.30"),
+	}}
+
+	store, err := New(prov, path)
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Sync(ctx))
+
+	assert.Equal(t, []string{
+		"app.home.lan A 192.168.1.30",
+		"nas.home.lan A 192.168.1.10",
+		"nas.home.lan TXT owner=me",
+		"printer.home.lan A 192.168.1.20",
+	}, prov.names(), "declared records are set, other types of a declared name are kept")
+
+	applied := len(prov.applied)
+	require.NoError(t, store.Sync(ctx))
+	assert.Len(t, prov.applied, applied, "nothing is applied when records are in sync")
+
+	// Dropping a record from the file deletes it
+	writeFile(t, path, `
+- name: nas.home.lan
+  type: A
+  ttl: 300
+  targets: [192.168.1.10]
+`)
+	require.NoError(t, store.Load())
+	require.NoError(t, store.Sync(ctx))
+
+	assert.Equal(t, []string{
+		"app.home.lan A 192.168.1.30",
+		"nas.home.lan A 192.168.1.10",
+		"nas.home.lan TXT owner=me",
+	}, prov.names())
+
+	// An invalid file keeps the records read before
+	writeFile(t, path, "- name: nas.home.lan\n  type: BOGUS\n")
+	require.Error(t, store.Load())
+	assert.Len(t, store.Declared(), 1)
+}
+
+func TestSync_Frozen(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, filepath.Join(t.TempDir(), "static.json"),
+		`[{"name": "nas.home.lan", "type": "A", "targets": ["192.168.1.10"]}]`)
+
+	prov := &fakeProvider{}
+	frozen := true
+
+	store, err := New(prov, path, WithFreeze(func() bool { return frozen }))
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	require.NoError(t, store.Sync(ctx), "a frozen sync is not an error")
+	assert.Empty(t, prov.applied)
+
+	frozen = false
+
+	require.NoError(t, store.Sync(ctx))
+	assert.Equal(t, []string{"nas.home.lan A 192.168.1.10"}, prov.names())
+}
+
+func TestGuard(t *testing.T) {
+	t.Parallel()
+
+	path := writeFile(t, filepath.Join(t.TempDir(), "static.yaml"), `
+- name: nas.home.lan
+  type: A
+  targets: [192.168.1.10]
+- name: router.lan
+  type: A
+  targets: [192.168.1.1]
+`)
+
+	prov := &fakeProvider{endpoints: []*endpoint.Endpoint{
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeA, "192.168.1.99"),
+		endpoint.NewEndpoint("app.home.lan", endpoint.RecordTypeA, "192.168.1.30"),
+	}}
+
+	store, err := New(prov, path)
+	require.NoError(t, err)
+
+	guarded := store.Guard(prov, func(name string) bool {
+		return strings.HasSuffix(name, ".home.lan")
+	})
+
+	ctx := context.Background()
+
+	endpoints, err := guarded.Records(ctx)
+	require.NoError(t, err)
+
+	seen := make([]string, 0, len(endpoints))
+	for _, item := range endpoints {
+		seen = append(seen, item.DNSName+" "+item.Targets.String())
+	}
+
+	assert.ElementsMatch(t, []string{
+		"app.home.lan 192.168.1.30",
+		"nas.home.lan 192.168.1.10",
+	}, seen, "declared records replace provider records, names outside the filter are hidden")
+
+	require.NoError(t, guarded.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{endpoint.NewEndpoint("web.home.lan", endpoint.RecordTypeA, "192.168.1.40")},
+		Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("NAS.home.lan.", endpoint.RecordTypeA, "192.168.1.99")},
+	}))
+
+	assert.Equal(t, []string{
+		"app.home.lan A 192.168.1.30",
+		"nas.home.lan A 192.168.1.99",
+		"web.home.lan A 192.168.1.40",
+	}, prov.names(), "changes to declared names are refused")
+
+	// Endpoints that want something else for a declared name are reported
+	_, err = guarded.AdjustEndpoints([]*endpoint.Endpoint{
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeA, "192.168.1.10"),
+		endpoint.NewEndpoint("nas.home.lan", endpoint.RecordTypeCNAME, "storage.home.lan"),
+		endpoint.NewEndpoint("web.home.lan", endpoint.RecordTypeA, "192.168.1.40"),
+	})
+	require.NoError(t, err)
+
+	recorder := httptest.NewRecorder()
+	store.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/static-records", nil))
+	require.Equal(t, http.StatusOK, recorder.Code)
+
+	var report Report
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &report))
+
+	assert.Len(t, report.Records, 2)
+	require.Len(t, report.Conflicts, 1)
+	assert.Equal(t, "CNAME", report.Conflicts[0].Wanted.Type)
+	assert.Equal(t, "nas.home.lan", report.Conflicts[0].Declared[0].Name)
+}
@@ -0,0 +1,198 @@
+// Package tlsconfig builds TLS configurations whose certificates are reloaded from disk.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+)
+
+// recheckInterval limits how often certificate files are stat'ed for changes.
+const recheckInterval = 10 * time.Second
+
+// reloadingFiles tracks modification times of a set of files and reports when any changed.
+type reloadingFiles struct {
+	paths     []string
+	modTimes  []time.Time
+	checkedAt time.Time
+}
+
+// changed reports whether any tracked file changed since the last call that returned true.
+// It stats the files at most once per recheckInterval.
+func (f *reloadingFiles) changed() bool {
+	if time.Since(f.checkedAt) < recheckInterval {
+		return false
+	}
+
+	f.checkedAt = time.Now()
+
+	modTimes, err := statAll(f.paths)
+	if err != nil {
+		// Files may briefly disappear during an atomic symlink swap; keep the current state
+		return false
+	}
+
+	for idx, modTime := range modTimes {
+		if !modTime.Equal(f.modTimes[idx]) {
+			f.modTimes = modTimes
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// statAll returns the modification times of the given files.
+func statAll(paths []string) ([]time.Time, error) {
+	modTimes := make([]time.Time, len(paths))
+
+	for idx, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to stat %s", path)
+		}
+
+		modTimes[idx] = info.ModTime()
+	}
+
+	return modTimes, nil
+}
+
+// Server serves TLS certificates and client CAs that are reloaded when their files change,
+// so certificates rotated by cert-manager are picked up without a restart.
+type Server struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	mu        sync.Mutex
+	files     reloadingFiles
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+}
+
+// NewServer loads the certificate pair and, if clientCAFile is set, the client CA bundle.
+func NewServer(certFile, keyFile, clientCAFile string) (*Server, error) {
+	paths := []string{certFile, keyFile}
+	if clientCAFile != "" {
+		paths = append(paths, clientCAFile)
+	}
+
+	modTimes, err := statAll(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	srv := &Server{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+		files: reloadingFiles{
+			paths:     paths,
+			modTimes:  modTimes,
+			checkedAt: time.Now(),
+		},
+	}
+
+	err = srv.load()
+	if err != nil {
+		return nil, err
+	}
+
+	return srv, nil
+}
+
+// Config returns a TLS configuration that always presents the current certificate.
+// When a client CA bundle is configured, clients must present a certificate signed by it.
+func (s *Server) Config() *tls.Config {
+	return &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			cert, clientCAs := s.current()
+
+			config := &tls.Config{
+				MinVersion:   tls.VersionTLS12,
+				Certificates: []tls.Certificate{*cert},
+			}
+
+			if clientCAs != nil {
+				config.ClientAuth = tls.RequireAndVerifyClientCert
+				config.ClientCAs = clientCAs
+			}
+
+			return config, nil
+		},
+	}
+}
+
+// current returns the certificate and client CAs, reloading them if the files changed.
+// A failed reload keeps serving the previous material.
+func (s *Server) current() (*tls.Certificate, *x509.CertPool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.files.changed() {
+		err := s.loadLocked()
+		if err != nil {
+			slog.Error("failed to reload TLS certificate, keeping previous one",
+				"cert_file", s.certFile,
+				"error", err)
+		} else {
+			slog.Info("reloaded TLS certificate", "cert_file", s.certFile)
+		}
+	}
+
+	return s.cert, s.clientCAs
+}
+
+// load reads the certificate material under the lock.
+func (s *Server) load() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.loadLocked()
+}
+
+// loadLocked reads the certificate material. The caller must hold s.mu.
+func (s *Server) loadLocked() error {
+	cert, err := tls.LoadX509KeyPair(s.certFile, s.keyFile)
+	if err != nil {
+		return errors.Wrap(err, "failed to load TLS key pair")
+	}
+
+	var clientCAs *x509.CertPool
+
+	if s.clientCAFile != "" {
+		clientCAs, err = LoadCertPool(s.clientCAFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	s.cert = &cert
+	s.clientCAs = clientCAs
+
+	return nil
+}
+
+// LoadCertPool reads a PEM bundle of CA certificates.
+func LoadCertPool(path string) (*x509.CertPool, error) {
+	pemData, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read CA bundle %s", path)
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pemData) {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return nil, errors.Newf("no certificates found in CA bundle %s", path)
+	}
+
+	return pool, nil
+}
@@ -0,0 +1,156 @@
+//nolint:testpackage // Testing private fields requires same-package tests
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeSelfSigned writes a self-signed certificate and key for localhost and returns the parsed certificate.
+func writeSelfSigned(t *testing.T, certFile, keyFile, commonName string) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return cert
+}
+
+func startServer(t *testing.T, config *tls.Config) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	srv.TLS = config
+	srv.StartTLS()
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func peerCommonName(t *testing.T, url string, clientConfig *tls.Config) string {
+	t.Helper()
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: clientConfig}}
+
+	resp, err := client.Get(url)
+	require.NoError(t, err)
+
+	defer resp.Body.Close()
+
+	return resp.TLS.PeerCertificates[0].Subject.CommonName
+}
+
+func TestServer_ReloadsCertificate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+
+	writeSelfSigned(t, certFile, keyFile, "first")
+
+	tlsServer, err := NewServer(certFile, keyFile, "")
+	require.NoError(t, err)
+
+	srv := startServer(t, tlsServer.Config())
+
+	//nolint:gosec // Test client trusts any certificate to observe which one is served
+	insecure := &tls.Config{InsecureSkipVerify: true}
+
+	assert.Equal(t, "first", peerCommonName(t, srv.URL, insecure))
+
+	// Rotate the certificate and make the next handshake re-check the files
+	writeSelfSigned(t, certFile, keyFile, "second")
+
+	future := time.Now().Add(time.Minute)
+	require.NoError(t, os.Chtimes(certFile, future, future))
+
+	tlsServer.mu.Lock()
+	tlsServer.files.checkedAt = time.Time{}
+	tlsServer.mu.Unlock()
+
+	assert.Equal(t, "second", peerCommonName(t, srv.URL, insecure))
+}
+
+func TestServer_RequiresClientCertificate(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	certFile := filepath.Join(dir, "tls.crt")
+	keyFile := filepath.Join(dir, "tls.key")
+	clientCertFile := filepath.Join(dir, "client.crt")
+	clientKeyFile := filepath.Join(dir, "client.key")
+
+	serverCert := writeSelfSigned(t, certFile, keyFile, "server")
+	writeSelfSigned(t, clientCertFile, clientKeyFile, "client")
+
+	// The self-signed client certificate acts as its own CA
+	tlsServer, err := NewServer(certFile, keyFile, clientCertFile)
+	require.NoError(t, err)
+
+	srv := startServer(t, tlsServer.Config())
+
+	roots := x509.NewCertPool()
+	roots.AddCert(serverCert)
+
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: roots, MinVersion: tls.VersionTLS12}}}
+
+	resp, err := client.Get(srv.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+
+	require.Error(t, err, "client without certificate must be rejected")
+
+	clientCert, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+	require.NoError(t, err)
+
+	assert.Equal(t, "server", peerCommonName(t, srv.URL, &tls.Config{
+		RootCAs:      roots,
+		Certificates: []tls.Certificate{clientCert},
+		MinVersion:   tls.VersionTLS12,
+	}))
+}
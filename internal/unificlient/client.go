@@ -0,0 +1,428 @@
+// Package unificlient implements unifi.NetworkAPIClient on top of the generated
+// go-unifi client with a caller-controlled HTTP transport.
+//
+// unifi.NewWithConfig builds its own transport and ignores ClientConfig.HTTPClient,
+// so custom TLS trust, credential rotation and alternative authentication need
+// this package to control how requests are sent.
+package unificlient
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"github.com/lexfrei/go-unifi/observability"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// defaultTimeout matches the go-unifi default HTTP client timeout.
+const defaultTimeout = 30 * time.Second
+
+// Config holds configuration for the UniFi Network API client.
+type Config struct {
+	// ControllerURL is the base URL of the UniFi controller.
+	ControllerURL string
+
+	// APIKey is sent in the X-API-KEY header of every request.
+	APIKey string `json:"-"`
+
+	// Username and Password authenticate a session instead of an API key,
+	// for controllers where integration API keys are not available.
+	Username string
+	Password string `json:"-"`
+
+	// SelfHosted selects a self-hosted Network application instead of a UniFi OS
+	// console: no /proxy/network prefix and the legacy login endpoint.
+	SelfHosted bool
+
+	// TLSConfig controls how the controller certificate is verified.
+	TLSConfig *tls.Config
+
+	// Timeout sets the HTTP client timeout (defaults to 30 seconds).
+	Timeout time.Duration
+
+	// Metrics records HTTP request metrics (optional).
+	Metrics observability.MetricsRecorder
+}
+
+// Client implements unifi.NetworkAPIClient.
+type Client struct {
+	api *unifi.ClientWithResponses
+}
+
+// Compile-time check to ensure Client implements the NetworkAPIClient interface.
+var _ unifi.NetworkAPIClient = (*Client)(nil)
+
+// New creates a UniFi Network API client.
+func New(cfg *Config) (*Client, error) {
+	if cfg.ControllerURL == "" {
+		return nil, errors.New("controller URL is required")
+	}
+
+	sessionAuth := cfg.Username != "" || cfg.Password != ""
+
+	switch {
+	case sessionAuth && cfg.APIKey != "":
+		return nil, errors.New("API key and username/password are mutually exclusive")
+	case sessionAuth && (cfg.Username == "" || cfg.Password == ""):
+		return nil, errors.New("username and password are required together")
+	case !sessionAuth && cfg.APIKey == "":
+		return nil, errors.New("API key or username and password are required")
+	}
+
+	timeout := cfg.Timeout
+	if timeout == 0 {
+		timeout = defaultTimeout
+	}
+
+	transport, ok := http.DefaultTransport.(*http.Transport)
+	if !ok {
+		return nil, errors.New("unexpected default HTTP transport type")
+	}
+
+	transport = transport.Clone()
+	transport.TLSClientConfig = cfg.TLSConfig
+
+	roundTripper := observe(transport, cfg.Metrics)
+	if sessionAuth {
+		roundTripper = &sessionTransport{
+			next:     roundTripper,
+			loginURL: cfg.ControllerURL + loginPath(cfg.SelfHosted),
+			username: cfg.Username,
+			password: cfg.Password,
+		}
+	}
+
+	httpClient := &http.Client{
+		Timeout:   timeout,
+		Transport: roundTripper,
+	}
+
+	requestEditor := func(_ context.Context, req *http.Request) error {
+		if !sessionAuth {
+			req.Header.Set("X-API-KEY", cfg.APIKey)
+		}
+
+		req.Header.Set("Accept", "application/json")
+
+		return nil
+	}
+
+	// UniFi OS consoles proxy the Network application under /proxy/network
+	baseURL := cfg.ControllerURL + "/proxy/network"
+	if cfg.SelfHosted {
+		baseURL = cfg.ControllerURL
+	}
+
+	// Paths like /integration/v1/sites are added by the generated client
+	api, err := unifi.NewClientWithResponses(
+		baseURL,
+		unifi.WithHTTPClient(httpClient),
+		unifi.WithRequestEditorFn(requestEditor),
+	)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to create API client")
+	}
+
+	return &Client{api: api}, nil
+}
+
+// statusCoder is implemented by all generated response types.
+type statusCoder interface {
+	StatusCode() int
+}
+
+// handle validates a response that carries data, mirroring go-unifi's response handling.
+func handle[T any](resp statusCoder, data *T, err error, errorMsg string) (*T, error) {
+	err = handleNoContent(resp, err, errorMsg)
+	if err != nil {
+		return nil, err
+	}
+
+	if data == nil {
+		return nil, errors.New("empty response from API")
+	}
+
+	return data, nil
+}
+
+// handleNoContent validates a response without data.
+func handleNoContent(resp statusCoder, err error, errorMsg string) error {
+	if err != nil {
+		return errors.Wrap(err, errorMsg)
+	}
+
+	if resp == nil {
+		return errors.New("nil response from API client")
+	}
+
+	if resp.StatusCode() != http.StatusOK {
+		//nolint:wrapcheck // Creating new error for non-expected status, no source error to wrap
+		return errors.Newf("%s: API error: status=%d", errorMsg, resp.StatusCode())
+	}
+
+	return nil
+}
+
+// ListSites retrieves a list of all sites configured on the controller.
+func (c *Client) ListSites(ctx context.Context, params *unifi.ListSitesParams) (*unifi.SitesResponse, error) {
+	resp, err := c.api.ListSitesWithResponse(ctx, params)
+
+	var data *unifi.SitesResponse
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, "failed to list sites")
+}
+
+// ListSiteDevices retrieves a list of all devices for a specific site.
+func (c *Client) ListSiteDevices(ctx context.Context, siteID unifi.SiteId, params *unifi.ListSiteDevicesParams) (*unifi.DevicesResponse, error) {
+	resp, err := c.api.ListSiteDevicesWithResponse(ctx, siteID, params)
+
+	var data *unifi.DevicesResponse
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to list devices for site %s", siteID))
+}
+
+// GetDeviceByID retrieves detailed information about a specific device.
+func (c *Client) GetDeviceByID(ctx context.Context, siteID unifi.SiteId, deviceID unifi.DeviceId) (*unifi.Device, error) {
+	resp, err := c.api.GetDeviceByIdWithResponse(ctx, siteID, deviceID)
+
+	var data *unifi.Device
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to get device %s in site %s", deviceID, siteID))
+}
+
+// ListSiteClients retrieves a list of all clients for a specific site.
+func (c *Client) ListSiteClients(ctx context.Context, siteID unifi.SiteId, params *unifi.ListSiteClientsParams) (*unifi.ClientsResponse, error) {
+	resp, err := c.api.ListSiteClientsWithResponse(ctx, siteID, params)
+
+	var data *unifi.ClientsResponse
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to list clients for site %s", siteID))
+}
+
+// GetClientByID retrieves detailed information about a specific client.
+func (c *Client) GetClientByID(ctx context.Context, siteID unifi.SiteId, clientID unifi.ClientId) (*unifi.NetworkClient, error) {
+	resp, err := c.api.GetClientByIdWithResponse(ctx, siteID, clientID)
+
+	var data *unifi.NetworkClient
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to get client %s in site %s", clientID, siteID))
+}
+
+// ListHotspotVouchers retrieves a list of all hotspot vouchers for a specific site.
+func (c *Client) ListHotspotVouchers(ctx context.Context, siteID unifi.SiteId, params *unifi.ListHotspotVouchersParams) (*unifi.HotspotVouchersResponse, error) {
+	resp, err := c.api.ListHotspotVouchersWithResponse(ctx, siteID, params)
+
+	var data *unifi.HotspotVouchersResponse
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to list hotspot vouchers for site %s", siteID))
+}
+
+// CreateHotspotVouchers creates one or more hotspot vouchers for temporary guest access.
+func (c *Client) CreateHotspotVouchers(ctx context.Context, siteID unifi.SiteId, request *unifi.CreateVouchersRequest) (*unifi.HotspotVouchersResponse, error) {
+	resp, err := c.api.CreateHotspotVouchersWithResponse(ctx, siteID, *request)
+
+	var data *unifi.HotspotVouchersResponse
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to create hotspot vouchers for site %s", siteID))
+}
+
+// GetHotspotVoucher retrieves detailed information about a specific hotspot voucher.
+func (c *Client) GetHotspotVoucher(ctx context.Context, siteID unifi.SiteId, voucherID openapi_types.UUID) (*unifi.HotspotVoucher, error) {
+	resp, err := c.api.GetHotspotVoucherWithResponse(ctx, siteID, voucherID)
+
+	var data *unifi.HotspotVoucher
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to get hotspot voucher %s in site %s", voucherID, siteID))
+}
+
+// DeleteHotspotVoucher permanently deletes a hotspot voucher.
+func (c *Client) DeleteHotspotVoucher(ctx context.Context, siteID unifi.SiteId, voucherID openapi_types.UUID) error {
+	resp, err := c.api.DeleteHotspotVoucherWithResponse(ctx, siteID, voucherID)
+
+	return handleNoContent(resp, err, fmt.Sprintf("failed to delete hotspot voucher %s in site %s", voucherID, siteID))
+}
+
+// ListDNSRecords lists all static DNS records for a site.
+func (c *Client) ListDNSRecords(ctx context.Context, site unifi.Site) ([]unifi.DNSRecord, error) {
+	resp, err := c.api.ListDNSRecordsWithResponse(ctx, site)
+
+	var dataPtr *[]unifi.DNSRecord
+	if resp != nil {
+		dataPtr = resp.JSON200
+	}
+
+	data, err := handle(resp, dataPtr, err, "failed to list DNS records for site "+site)
+	if err != nil {
+		return nil, err
+	}
+
+	return *data, nil
+}
+
+// CreateDNSRecord creates a new static DNS record.
+func (c *Client) CreateDNSRecord(ctx context.Context, site unifi.Site, record *unifi.DNSRecordInput) (*unifi.DNSRecord, error) {
+	resp, err := c.api.CreateDNSRecordWithResponse(ctx, site, *record)
+
+	var data *unifi.DNSRecord
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to create DNS record %s in site %s", record.Key, site))
+}
+
+// UpdateDNSRecord updates an existing DNS record.
+func (c *Client) UpdateDNSRecord(ctx context.Context, site unifi.Site, recordID unifi.RecordId, record *unifi.DNSRecordInput) (*unifi.DNSRecord, error) {
+	resp, err := c.api.UpdateDNSRecordWithResponse(ctx, site, recordID, *record)
+
+	var data *unifi.DNSRecord
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to update DNS record %s in site %s", recordID, site))
+}
+
+// DeleteDNSRecord deletes a DNS record.
+func (c *Client) DeleteDNSRecord(ctx context.Context, site unifi.Site, recordID unifi.RecordId) error {
+	resp, err := c.api.DeleteDNSRecordWithResponse(ctx, site, recordID)
+
+	return handleNoContent(resp, err, fmt.Sprintf("failed to delete DNS record %s in site %s", recordID, site))
+}
+
+// ListFirewallPolicies lists all firewall policies for a site.
+func (c *Client) ListFirewallPolicies(ctx context.Context, site unifi.Site) ([]unifi.FirewallPolicy, error) {
+	resp, err := c.api.ListFirewallPoliciesWithResponse(ctx, site)
+
+	var dataPtr *[]unifi.FirewallPolicy
+	if resp != nil {
+		dataPtr = resp.JSON200
+	}
+
+	data, err := handle(resp, dataPtr, err, "failed to list firewall policies for site "+site)
+	if err != nil {
+		return nil, err
+	}
+
+	return *data, nil
+}
+
+// CreateFirewallPolicy creates a new firewall policy.
+func (c *Client) CreateFirewallPolicy(ctx context.Context, site unifi.Site, policy *unifi.FirewallPolicyInput) (*unifi.FirewallPolicy, error) {
+	resp, err := c.api.CreateFirewallPolicyWithResponse(ctx, site, *policy)
+
+	var data *unifi.FirewallPolicy
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, "failed to create firewall policy in site "+site)
+}
+
+// UpdateFirewallPolicy updates an existing firewall policy.
+func (c *Client) UpdateFirewallPolicy(ctx context.Context, site unifi.Site, policyID unifi.PolicyId, policy *unifi.FirewallPolicyInput) (*unifi.FirewallPolicy, error) {
+	resp, err := c.api.UpdateFirewallPolicyWithResponse(ctx, site, policyID, *policy)
+
+	var data *unifi.FirewallPolicy
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to update firewall policy %s in site %s", policyID, site))
+}
+
+// DeleteFirewallPolicy permanently deletes a firewall policy.
+func (c *Client) DeleteFirewallPolicy(ctx context.Context, site unifi.Site, policyID unifi.PolicyId) error {
+	resp, err := c.api.DeleteFirewallPolicyWithResponse(ctx, site, policyID)
+
+	return handleNoContent(resp, err, fmt.Sprintf("failed to delete firewall policy %s in site %s", policyID, site))
+}
+
+// ListTrafficRules lists all traffic rules for a site.
+func (c *Client) ListTrafficRules(ctx context.Context, site unifi.Site) ([]unifi.TrafficRule, error) {
+	resp, err := c.api.ListTrafficRulesWithResponse(ctx, site)
+
+	var dataPtr *[]unifi.TrafficRule
+	if resp != nil {
+		dataPtr = resp.JSON200
+	}
+
+	data, err := handle(resp, dataPtr, err, "failed to list traffic rules for site "+site)
+	if err != nil {
+		return nil, err
+	}
+
+	return *data, nil
+}
+
+// CreateTrafficRule creates a new traffic rule.
+func (c *Client) CreateTrafficRule(ctx context.Context, site unifi.Site, rule *unifi.TrafficRuleInput) (*unifi.TrafficRule, error) {
+	resp, err := c.api.CreateTrafficRuleWithResponse(ctx, site, *rule)
+
+	var data *unifi.TrafficRule
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, "failed to create traffic rule in site "+site)
+}
+
+// UpdateTrafficRule updates an existing traffic rule.
+func (c *Client) UpdateTrafficRule(ctx context.Context, site unifi.Site, ruleID unifi.RuleId, rule *unifi.TrafficRuleInput) (*unifi.TrafficRule, error) {
+	resp, err := c.api.UpdateTrafficRuleWithResponse(ctx, site, ruleID, *rule)
+
+	var data *unifi.TrafficRule
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, fmt.Sprintf("failed to update traffic rule %s in site %s", ruleID, site))
+}
+
+// DeleteTrafficRule permanently deletes a traffic rule.
+func (c *Client) DeleteTrafficRule(ctx context.Context, site unifi.Site, ruleID unifi.RuleId) error {
+	resp, err := c.api.DeleteTrafficRuleWithResponse(ctx, site, ruleID)
+
+	return handleNoContent(resp, err, fmt.Sprintf("failed to delete traffic rule %s in site %s", ruleID, site))
+}
+
+// GetAggregatedDashboard retrieves aggregated dashboard statistics.
+func (c *Client) GetAggregatedDashboard(ctx context.Context, site unifi.Site, params *unifi.GetAggregatedDashboardParams) (*unifi.AggregatedDashboard, error) {
+	resp, err := c.api.GetAggregatedDashboardWithResponse(ctx, site, params)
+
+	var data *unifi.AggregatedDashboard
+	if resp != nil {
+		data = resp.JSON200
+	}
+
+	return handle(resp, data, err, "failed to get aggregated dashboard for site "+site)
+}
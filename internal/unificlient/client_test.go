@@ -0,0 +1,110 @@
+package unificlient_test
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/unificlient"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newController starts a TLS server that answers every request with an empty DNS record list.
+func newController(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-API-KEY") != "test-key" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte("[]"))
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func serverFingerprint(srv *httptest.Server) string {
+	return unificlient.FormatFingerprint(sha256.Sum256(srv.Certificate().Raw))
+}
+
+func newClient(t *testing.T, srv *httptest.Server, trust unificlient.TrustConfig) *unificlient.Client {
+	t.Helper()
+
+	tlsConfig, err := unificlient.NewTLSConfig(trust)
+	require.NoError(t, err)
+
+	client, err := unificlient.New(&unificlient.Config{
+		ControllerURL: srv.URL,
+		APIKey:        "test-key",
+		TLSConfig:     tlsConfig,
+	})
+	require.NoError(t, err)
+
+	return client
+}
+
+func TestClient_PinnedFingerprint(t *testing.T) {
+	t.Parallel()
+
+	srv := newController(t)
+
+	client := newClient(t, srv, unificlient.TrustConfig{Fingerprint: serverFingerprint(srv)})
+
+	records, err := client.ListDNSRecords(context.Background(), "default")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+
+	wrongPin := unificlient.FormatFingerprint(sha256.Sum256([]byte("other certificate")))
+	client = newClient(t, srv, unificlient.TrustConfig{Fingerprint: wrongPin})
+
+	_, err = client.ListDNSRecords(context.Background(), "default")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "does not match pinned fingerprint")
+}
+
+func TestClient_VerifiesByDefault(t *testing.T) {
+	t.Parallel()
+
+	srv := newController(t)
+
+	// Neither a CA nor a pin: the test server's certificate is untrusted
+	client := newClient(t, srv, unificlient.TrustConfig{})
+
+	_, err := client.ListDNSRecords(context.Background(), "default")
+	require.Error(t, err)
+}
+
+func TestFetchFingerprint(t *testing.T) {
+	t.Parallel()
+
+	srv := newController(t)
+
+	fingerprint, err := unificlient.FetchFingerprint(context.Background(), srv.URL)
+	require.NoError(t, err)
+	assert.Equal(t, serverFingerprint(srv), fingerprint)
+
+	parsed, err := unificlient.ParseFingerprint(fingerprint)
+	require.NoError(t, err)
+	assert.Equal(t, sha256.Sum256(srv.Certificate().Raw), parsed)
+}
+
+func TestNewTLSConfig(t *testing.T) {
+	t.Parallel()
+
+	_, err := unificlient.NewTLSConfig(unificlient.TrustConfig{Fingerprint: "not-a-fingerprint"})
+	require.Error(t, err)
+
+	config, err := unificlient.NewTLSConfig(unificlient.TrustConfig{SkipVerify: true})
+	require.NoError(t, err)
+	assert.True(t, config.InsecureSkipVerify)
+	assert.GreaterOrEqual(t, config.MinVersion, uint16(tls.VersionTLS12))
+}
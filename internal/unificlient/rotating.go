@@ -0,0 +1,228 @@
+package unificlient
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/secret"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// rotationCheckInterval is how often Watch looks for a rotated credential.
+const rotationCheckInterval = 10 * time.Second
+
+// Factory builds a client for a credential.
+type Factory func(credential string) (unifi.NetworkAPIClient, error)
+
+// rotatingState pairs a client with the credential it was built for.
+type rotatingState struct {
+	client     unifi.NetworkAPIClient
+	credential []byte
+}
+
+// Rotating is a unifi.NetworkAPIClient whose credential is read from a file and
+// replaced when the file changes. A rotated credential gets a freshly built client
+// that is swapped in atomically; requests already in flight finish on the old one.
+type Rotating struct {
+	source *secret.File
+	build  Factory
+
+	reloadMu sync.Mutex
+	current  atomic.Pointer[rotatingState]
+}
+
+// Compile-time check to ensure Rotating implements the NetworkAPIClient interface.
+var _ unifi.NetworkAPIClient = (*Rotating)(nil)
+
+// NewRotating builds the initial client from the credential in source.
+func NewRotating(source *secret.File, build Factory) (*Rotating, error) {
+	credential := source.Value()
+
+	client, err := build(string(credential))
+	if err != nil {
+		return nil, err
+	}
+
+	rotating := &Rotating{source: source, build: build}
+	rotating.current.Store(&rotatingState{client: client, credential: credential})
+	dnsmetrics.UniFiCredentialsLastReload.SetToCurrentTime()
+
+	return rotating, nil
+}
+
+// Reload rebuilds the client if the credential file changed. It reports whether
+// the client was replaced. On error the previous client stays in use.
+func (r *Rotating) Reload() (bool, error) {
+	r.reloadMu.Lock()
+	defer r.reloadMu.Unlock()
+
+	err := r.source.Reload()
+	if err != nil {
+		dnsmetrics.UniFiCredentialReloadErrors.Inc()
+
+		return false, err
+	}
+
+	credential := r.source.Value()
+	if bytes.Equal(credential, r.current.Load().credential) {
+		return false, nil
+	}
+
+	client, err := r.build(string(credential))
+	if err != nil {
+		dnsmetrics.UniFiCredentialReloadErrors.Inc()
+
+		return false, err
+	}
+
+	r.current.Store(&rotatingState{client: client, credential: credential})
+	dnsmetrics.UniFiCredentialsLastReload.SetToCurrentTime()
+
+	return true, nil
+}
+
+// Watch reloads the credential periodically until ctx is canceled.
+func (r *Rotating) Watch(ctx context.Context) {
+	ticker := time.NewTicker(rotationCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			replaced, err := r.Reload()
+			if err != nil {
+				slog.ErrorContext(ctx, "failed to apply rotated UniFi credential, keeping the previous one",
+					"file", r.source.Path(), "error", err)
+
+				continue
+			}
+
+			if replaced {
+				slog.InfoContext(ctx, "reloaded rotated UniFi credential", "file", r.source.Path())
+			}
+		}
+	}
+}
+
+// client returns the client for the current credential.
+func (r *Rotating) client() unifi.NetworkAPIClient {
+	return r.current.Load().client
+}
+
+// ListSites implements unifi.NetworkAPIClient.
+func (r *Rotating) ListSites(ctx context.Context, params *unifi.ListSitesParams) (*unifi.SitesResponse, error) {
+	return r.client().ListSites(ctx, params)
+}
+
+// ListSiteDevices implements unifi.NetworkAPIClient.
+func (r *Rotating) ListSiteDevices(ctx context.Context, siteID unifi.SiteId, params *unifi.ListSiteDevicesParams) (*unifi.DevicesResponse, error) {
+	return r.client().ListSiteDevices(ctx, siteID, params)
+}
+
+// GetDeviceByID implements unifi.NetworkAPIClient.
+func (r *Rotating) GetDeviceByID(ctx context.Context, siteID unifi.SiteId, deviceID unifi.DeviceId) (*unifi.Device, error) {
+	return r.client().GetDeviceByID(ctx, siteID, deviceID)
+}
+
+// ListSiteClients implements unifi.NetworkAPIClient.
+func (r *Rotating) ListSiteClients(ctx context.Context, siteID unifi.SiteId, params *unifi.ListSiteClientsParams) (*unifi.ClientsResponse, error) {
+	return r.client().ListSiteClients(ctx, siteID, params)
+}
+
+// GetClientByID implements unifi.NetworkAPIClient.
+func (r *Rotating) GetClientByID(ctx context.Context, siteID unifi.SiteId, clientID unifi.ClientId) (*unifi.NetworkClient, error) {
+	return r.client().GetClientByID(ctx, siteID, clientID)
+}
+
+// ListHotspotVouchers implements unifi.NetworkAPIClient.
+func (r *Rotating) ListHotspotVouchers(ctx context.Context, siteID unifi.SiteId, params *unifi.ListHotspotVouchersParams) (*unifi.HotspotVouchersResponse, error) {
+	return r.client().ListHotspotVouchers(ctx, siteID, params)
+}
+
+// CreateHotspotVouchers implements unifi.NetworkAPIClient.
+func (r *Rotating) CreateHotspotVouchers(ctx context.Context, siteID unifi.SiteId, request *unifi.CreateVouchersRequest) (*unifi.HotspotVouchersResponse, error) {
+	return r.client().CreateHotspotVouchers(ctx, siteID, request)
+}
+
+// GetHotspotVoucher implements unifi.NetworkAPIClient.
+func (r *Rotating) GetHotspotVoucher(ctx context.Context, siteID unifi.SiteId, voucherID openapi_types.UUID) (*unifi.HotspotVoucher, error) {
+	return r.client().GetHotspotVoucher(ctx, siteID, voucherID)
+}
+
+// DeleteHotspotVoucher implements unifi.NetworkAPIClient.
+func (r *Rotating) DeleteHotspotVoucher(ctx context.Context, siteID unifi.SiteId, voucherID openapi_types.UUID) error {
+	return r.client().DeleteHotspotVoucher(ctx, siteID, voucherID)
+}
+
+// ListDNSRecords implements unifi.NetworkAPIClient.
+func (r *Rotating) ListDNSRecords(ctx context.Context, site unifi.Site) ([]unifi.DNSRecord, error) {
+	return r.client().ListDNSRecords(ctx, site)
+}
+
+// CreateDNSRecord implements unifi.NetworkAPIClient.
+func (r *Rotating) CreateDNSRecord(ctx context.Context, site unifi.Site, record *unifi.DNSRecordInput) (*unifi.DNSRecord, error) {
+	return r.client().CreateDNSRecord(ctx, site, record)
+}
+
+// UpdateDNSRecord implements unifi.NetworkAPIClient.
+func (r *Rotating) UpdateDNSRecord(ctx context.Context, site unifi.Site, recordID unifi.RecordId, record *unifi.DNSRecordInput) (*unifi.DNSRecord, error) {
+	return r.client().UpdateDNSRecord(ctx, site, recordID, record)
+}
+
+// DeleteDNSRecord implements unifi.NetworkAPIClient.
+func (r *Rotating) DeleteDNSRecord(ctx context.Context, site unifi.Site, recordID unifi.RecordId) error {
+	return r.client().DeleteDNSRecord(ctx, site, recordID)
+}
+
+// ListFirewallPolicies implements unifi.NetworkAPIClient.
+func (r *Rotating) ListFirewallPolicies(ctx context.Context, site unifi.Site) ([]unifi.FirewallPolicy, error) {
+	return r.client().ListFirewallPolicies(ctx, site)
+}
+
+// CreateFirewallPolicy implements unifi.NetworkAPIClient.
+func (r *Rotating) CreateFirewallPolicy(ctx context.Context, site unifi.Site, policy *unifi.FirewallPolicyInput) (*unifi.FirewallPolicy, error) {
+	return r.client().CreateFirewallPolicy(ctx, site, policy)
+}
+
+// UpdateFirewallPolicy implements unifi.NetworkAPIClient.
+func (r *Rotating) UpdateFirewallPolicy(ctx context.Context, site unifi.Site, policyID unifi.PolicyId, policy *unifi.FirewallPolicyInput) (*unifi.FirewallPolicy, error) {
+	return r.client().UpdateFirewallPolicy(ctx, site, policyID, policy)
+}
+
+// DeleteFirewallPolicy implements unifi.NetworkAPIClient.
+func (r *Rotating) DeleteFirewallPolicy(ctx context.Context, site unifi.Site, policyID unifi.PolicyId) error {
+	return r.client().DeleteFirewallPolicy(ctx, site, policyID)
+}
+
+// ListTrafficRules implements unifi.NetworkAPIClient.
+func (r *Rotating) ListTrafficRules(ctx context.Context, site unifi.Site) ([]unifi.TrafficRule, error) {
+	return r.client().ListTrafficRules(ctx, site)
+}
+
+// CreateTrafficRule implements unifi.NetworkAPIClient.
+func (r *Rotating) CreateTrafficRule(ctx context.Context, site unifi.Site, rule *unifi.TrafficRuleInput) (*unifi.TrafficRule, error) {
+	return r.client().CreateTrafficRule(ctx, site, rule)
+}
+
+// UpdateTrafficRule implements unifi.NetworkAPIClient.
+func (r *Rotating) UpdateTrafficRule(ctx context.Context, site unifi.Site, ruleID unifi.RuleId, rule *unifi.TrafficRuleInput) (*unifi.TrafficRule, error) {
+	return r.client().UpdateTrafficRule(ctx, site, ruleID, rule)
+}
+
+// DeleteTrafficRule implements unifi.NetworkAPIClient.
+func (r *Rotating) DeleteTrafficRule(ctx context.Context, site unifi.Site, ruleID unifi.RuleId) error {
+	return r.client().DeleteTrafficRule(ctx, site, ruleID)
+}
+
+// GetAggregatedDashboard implements unifi.NetworkAPIClient.
+func (r *Rotating) GetAggregatedDashboard(ctx context.Context, site unifi.Site, params *unifi.GetAggregatedDashboardParams) (*unifi.AggregatedDashboard, error) {
+	return r.client().GetAggregatedDashboard(ctx, site, params)
+}
@@ -0,0 +1,68 @@
+package unificlient_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/secret"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/unificlient"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRotating_SwapsClientOnRotation(t *testing.T) {
+	t.Parallel()
+
+	srv := newController(t)
+	keyFile := filepath.Join(t.TempDir(), "api-key")
+	require.NoError(t, os.WriteFile(keyFile, []byte("stale-key\n"), 0o600))
+
+	source, err := secret.NewFile(keyFile)
+	require.NoError(t, err)
+
+	var built []string
+
+	rotating, err := unificlient.NewRotating(source, func(apiKey string) (unifi.NetworkAPIClient, error) {
+		built = append(built, apiKey)
+
+		tlsConfig, err := unificlient.NewTLSConfig(unificlient.TrustConfig{SkipVerify: true})
+		if err != nil {
+			return nil, err
+		}
+
+		return unificlient.New(&unificlient.Config{ControllerURL: srv.URL, APIKey: apiKey, TLSConfig: tlsConfig})
+	})
+	require.NoError(t, err)
+
+	_, err = rotating.ListDNSRecords(context.Background(), "default")
+	require.Error(t, err, "stale key must be rejected by the controller")
+
+	// Unchanged file keeps the current client
+	replaced, err := rotating.Reload()
+	require.NoError(t, err)
+	assert.False(t, replaced)
+
+	require.NoError(t, os.WriteFile(keyFile, []byte("test-key\n"), 0o600))
+
+	replaced, err = rotating.Reload()
+	require.NoError(t, err)
+	assert.True(t, replaced)
+
+	records, err := rotating.ListDNSRecords(context.Background(), "default")
+	require.NoError(t, err)
+	assert.Empty(t, records)
+	assert.Equal(t, []string{"stale-key", "test-key"}, built)
+
+	// An emptied file is an error and the working client stays in place
+	require.NoError(t, os.WriteFile(keyFile, nil, 0o600))
+
+	replaced, err = rotating.Reload()
+	require.Error(t, err)
+	assert.False(t, replaced)
+
+	_, err = rotating.ListDNSRecords(context.Background(), "default")
+	require.NoError(t, err)
+}
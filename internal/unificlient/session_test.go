@@ -0,0 +1,142 @@
+package unificlient_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/unificlient"
+	unifi "github.com/lexfrei/go-unifi/api/network"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sessionController emulates a UniFi OS console that authenticates with a session cookie and CSRF token.
+type sessionController struct {
+	mu     sync.Mutex
+	logins int
+	token  string
+}
+
+func (c *sessionController) expire() {
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+}
+
+func (c *sessionController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if r.URL.Path == "/api/auth/login" {
+		var creds struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+		}
+
+		if json.NewDecoder(r.Body).Decode(&creds) != nil || creds.Username != "admin" || creds.Password != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		c.logins++
+		c.token = "session-" + strconv.Itoa(c.logins)
+
+		http.SetCookie(w, &http.Cookie{Name: "TOKEN", Value: c.token})
+		w.Header().Set("X-CSRF-Token", "csrf-"+c.token)
+		w.WriteHeader(http.StatusOK)
+
+		return
+	}
+
+	cookie, err := r.Cookie("TOKEN")
+	if err != nil || c.token == "" || cookie.Value != c.token || r.Header.Get("X-CSRF-Token") != "csrf-"+c.token {
+		w.WriteHeader(http.StatusUnauthorized)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	if r.Method == http.MethodPost {
+		_, _ = w.Write([]byte(`{"_id":"rec-1","key":"app.example.com","value":"10.0.0.1","record_type":"A","enabled":true}`))
+
+		return
+	}
+
+	_, _ = w.Write([]byte("[]"))
+}
+
+func newSessionClient(t *testing.T, password string) (*unificlient.Client, *sessionController) {
+	t.Helper()
+
+	controller := &sessionController{}
+	srv := httptest.NewTLSServer(controller)
+	t.Cleanup(srv.Close)
+
+	tlsConfig, err := unificlient.NewTLSConfig(unificlient.TrustConfig{Fingerprint: serverFingerprint(srv)})
+	require.NoError(t, err)
+
+	client, err := unificlient.New(&unificlient.Config{
+		ControllerURL: srv.URL,
+		Username:      "admin",
+		Password:      password,
+		TLSConfig:     tlsConfig,
+	})
+	require.NoError(t, err)
+
+	return client, controller
+}
+
+func TestSession_ReloginOnUnauthorized(t *testing.T) {
+	t.Parallel()
+
+	client, controller := newSessionClient(t, "secret")
+
+	_, err := client.ListDNSRecords(context.Background(), "default")
+	require.NoError(t, err)
+
+	_, err = client.ListDNSRecords(context.Background(), "default")
+	require.NoError(t, err)
+	assert.Equal(t, 1, controller.logins, "session must be reused")
+
+	// Expired session: the request is retried after a new login, including its body
+	controller.expire()
+
+	record, err := client.CreateDNSRecord(context.Background(), "default", &unifi.DNSRecordInput{
+		Key:        "app.example.com",
+		Value:      "10.0.0.1",
+		RecordType: unifi.DNSRecordInputRecordTypeA,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "rec-1", record.UnderscoreId)
+	assert.Equal(t, 2, controller.logins)
+}
+
+func TestSession_InvalidCredentials(t *testing.T) {
+	t.Parallel()
+
+	client, _ := newSessionClient(t, "wrong")
+
+	_, err := client.ListDNSRecords(context.Background(), "default")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "failed to log in")
+}
+
+func TestNew_AuthenticationMethods(t *testing.T) {
+	t.Parallel()
+
+	_, err := unificlient.New(&unificlient.Config{ControllerURL: "https://unifi", APIKey: "key", Username: "admin", Password: "secret"})
+	require.Error(t, err)
+
+	_, err = unificlient.New(&unificlient.Config{ControllerURL: "https://unifi", Username: "admin"})
+	require.Error(t, err)
+
+	_, err = unificlient.New(&unificlient.Config{ControllerURL: "https://unifi"})
+	require.Error(t, err)
+}
@@ -0,0 +1,159 @@
+package unificlient
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"net"
+	"net/url"
+	"strings"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/tlsconfig"
+)
+
+// sha256HexLength is the length of a hex-encoded SHA-256 digest.
+const sha256HexLength = sha256.Size * 2
+
+// TrustConfig describes how the controller certificate is trusted.
+type TrustConfig struct {
+	// CAFile is a PEM bundle of CAs that may sign the controller certificate.
+	CAFile string
+	// Fingerprint is the SHA-256 fingerprint of the controller certificate to pin.
+	Fingerprint string
+	// SkipVerify disables verification; ignored when CAFile or Fingerprint is set.
+	SkipVerify bool
+}
+
+// Insecure reports whether the configuration disables certificate verification entirely.
+func (t TrustConfig) Insecure() bool {
+	return t.SkipVerify && t.CAFile == "" && t.Fingerprint == ""
+}
+
+// NewTLSConfig builds a client TLS configuration from trust settings.
+//
+// A CA bundle enables regular chain and hostname verification against those CAs.
+// A fingerprint pins the leaf certificate; on its own it replaces chain verification,
+// which is what self-signed gateway certificates need.
+func NewTLSConfig(trust TrustConfig) (*tls.Config, error) {
+	config := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if trust.CAFile != "" {
+		pool, err := tlsconfig.LoadCertPool(trust.CAFile)
+		if err != nil {
+			return nil, err
+		}
+
+		config.RootCAs = pool
+	}
+
+	if trust.Fingerprint != "" {
+		pinned, err := ParseFingerprint(trust.Fingerprint)
+		if err != nil {
+			return nil, err
+		}
+
+		if trust.CAFile == "" {
+			// The pin replaces chain verification; VerifyPeerCertificate checks it instead
+			config.InsecureSkipVerify = true //nolint:gosec // Certificate is verified by fingerprint
+		}
+
+		config.VerifyPeerCertificate = func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+			if len(rawCerts) == 0 {
+				return errors.New("controller presented no certificate")
+			}
+
+			actual := sha256.Sum256(rawCerts[0])
+			if actual != pinned {
+				//nolint:wrapcheck // Creating new error, not wrapping
+				return errors.Newf("controller certificate fingerprint %s does not match pinned fingerprint",
+					FormatFingerprint(actual))
+			}
+
+			return nil
+		}
+
+		return config, nil
+	}
+
+	if trust.Insecure() {
+		config.InsecureSkipVerify = true //nolint:gosec // User-configurable for self-signed certificates
+	}
+
+	return config, nil
+}
+
+// ParseFingerprint parses a SHA-256 fingerprint in hex, with or without colon separators.
+func ParseFingerprint(text string) ([sha256.Size]byte, error) {
+	var fingerprint [sha256.Size]byte
+
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(text), ":", ""))
+	normalized = strings.TrimPrefix(normalized, "sha256/")
+
+	if len(normalized) != sha256HexLength {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return fingerprint, errors.Newf("invalid SHA-256 fingerprint %q: expected %d hex digits", text, sha256HexLength)
+	}
+
+	_, err := hex.Decode(fingerprint[:], []byte(normalized))
+	if err != nil {
+		return fingerprint, errors.Wrapf(err, "invalid SHA-256 fingerprint %q", text)
+	}
+
+	return fingerprint, nil
+}
+
+// FormatFingerprint formats a SHA-256 fingerprint as colon-separated uppercase hex.
+func FormatFingerprint(fingerprint [sha256.Size]byte) string {
+	parts := make([]string, len(fingerprint))
+	for idx, value := range fingerprint {
+		parts[idx] = strings.ToUpper(hex.EncodeToString([]byte{value}))
+	}
+
+	return strings.Join(parts, ":")
+}
+
+// FetchFingerprint connects to the controller without verification and returns
+// the SHA-256 fingerprint of the certificate it presents, for trust-on-first-use.
+func FetchFingerprint(ctx context.Context, controllerURL string) (string, error) {
+	parsed, err := url.Parse(controllerURL)
+	if err != nil {
+		return "", errors.Wrap(err, "invalid controller URL")
+	}
+
+	if parsed.Hostname() == "" {
+		//nolint:wrapcheck // Creating new error, not wrapping
+		return "", errors.Newf("controller URL %q has no host", controllerURL)
+	}
+
+	address := parsed.Host
+	if parsed.Port() == "" {
+		address = net.JoinHostPort(parsed.Hostname(), "443")
+	}
+
+	dialer := &tls.Dialer{
+		//nolint:gosec // Fetching the certificate to display it, not to trust it
+		Config: &tls.Config{InsecureSkipVerify: true, MinVersion: tls.VersionTLS12},
+	}
+
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to connect to %s", address)
+	}
+
+	defer conn.Close()
+
+	tlsConn, ok := conn.(*tls.Conn)
+	if !ok {
+		return "", errors.New("unexpected connection type")
+	}
+
+	certs := tlsConn.ConnectionState().PeerCertificates
+	if len(certs) == 0 {
+		return "", errors.New("controller presented no certificate")
+	}
+
+	return FormatFingerprint(sha256.Sum256(certs[0].Raw)), nil
+}
@@ -0,0 +1,40 @@
+package unificlient
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/lexfrei/go-unifi/observability"
+)
+
+// observingTransport records request metrics like go-unifi's observability middleware.
+type observingTransport struct {
+	next    http.RoundTripper
+	metrics observability.MetricsRecorder
+}
+
+// observe wraps next with metrics recording. A nil recorder disables recording.
+func observe(next http.RoundTripper, metrics observability.MetricsRecorder) http.RoundTripper {
+	if metrics == nil {
+		return next
+	}
+
+	return &observingTransport{next: next, metrics: metrics}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *observingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		t.metrics.RecordError("http_request", "NetworkError")
+
+		//nolint:wrapcheck // Transport passes errors through unchanged
+		return nil, err
+	}
+
+	t.metrics.RecordHTTPRequest(req.Method, req.URL.Path, resp.StatusCode, time.Since(start))
+
+	return resp, nil
+}
@@ -0,0 +1,358 @@
+// Package verify checks that changed DNS records are served by a resolver.
+//
+// UniFi accepting a record does not mean its DNS server answers with it yet.
+// A Verifier queries a configured server, usually the gateway, for each changed
+// name and type until the expected answers show up or the attempts run out.
+package verify
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"net"
+	"net/netip"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"golang.org/x/net/dns/dnsmessage"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// Defaults for optional Verifier settings.
+const (
+	DefaultTimeout  = 2 * time.Second
+	DefaultRetries  = 3
+	DefaultInterval = time.Second
+)
+
+// maxParallel bounds the lookups in flight at once.
+const maxParallel = 10
+
+// maxMessageSize is the largest DNS response read over UDP.
+const maxMessageSize = 1232
+
+// Record is a record as it should be served.
+type Record struct {
+	Name   string
+	Type   string
+	Values []string
+}
+
+// Failure is a record the server did not answer with as expected.
+type Failure struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Expected []string `json:"expected"`
+	Answers  []string `json:"answers"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Error reports the records that failed verification.
+type Error struct {
+	Server   string
+	Failures []Failure
+}
+
+func (e *Error) Error() string {
+	names := make([]string, 0, len(e.Failures))
+	for _, failure := range e.Failures {
+		names = append(names, failure.Type+" "+failure.Name)
+	}
+
+	return fmt.Sprintf("%d records not served by %s after applying: %s",
+		len(e.Failures), e.Server, strings.Join(names, ", "))
+}
+
+// Verifier queries a DNS server for changed records.
+type Verifier struct {
+	server   string
+	resolver *net.Resolver
+	timeout  time.Duration
+	retries  int
+	interval time.Duration
+}
+
+// Option configures optional Verifier behavior.
+type Option func(*Verifier)
+
+// WithTimeout sets how long a single lookup may take.
+func WithTimeout(timeout time.Duration) Option {
+	return func(v *Verifier) {
+		v.timeout = timeout
+	}
+}
+
+// WithRetries sets how many times a record that is not served yet is looked up again.
+func WithRetries(retries int) Option {
+	return func(v *Verifier) {
+		v.retries = retries
+	}
+}
+
+// WithInterval sets the wait between lookups of a record.
+func WithInterval(interval time.Duration) Option {
+	return func(v *Verifier) {
+		v.interval = interval
+	}
+}
+
+// New creates a verifier querying server, an address with an optional port (53 by default).
+func New(server string, opts ...Option) *Verifier {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+	}
+
+	verifier := &Verifier{
+		server: server,
+		resolver: &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+
+				return dialer.DialContext(ctx, network, server)
+			},
+		},
+		timeout:  DefaultTimeout,
+		retries:  DefaultRetries,
+		interval: DefaultInterval,
+	}
+
+	for _, opt := range opts {
+		opt(verifier)
+	}
+
+	return verifier
+}
+
+// Supported reports whether records of recordType can be verified.
+func Supported(recordType string) bool {
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA, endpoint.RecordTypeCNAME, endpoint.RecordTypeTXT:
+		return true
+	default:
+		return false
+	}
+}
+
+// Verify looks up each record until the server answers with all its values, and
+// returns an *Error listing the records it never did. Records of unsupported types are skipped.
+func (v *Verifier) Verify(ctx context.Context, records []Record) error {
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		failures []Failure
+	)
+
+	sem := make(chan struct{}, maxParallel)
+
+	for _, record := range records {
+		if !Supported(record.Type) {
+			continue
+		}
+
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			failure, ok := v.verify(ctx, record)
+			if ok {
+				dnsmetrics.VerifiedRecords.WithLabelValues("success").Inc()
+
+				return
+			}
+
+			dnsmetrics.VerifiedRecords.WithLabelValues("failure").Inc()
+			slog.WarnContext(ctx, "DNS record not served after applying",
+				"server", v.server, "name", record.Name, "type", record.Type,
+				"expected", record.Values, "answers", failure.Answers, "error", failure.Error)
+
+			mu.Lock()
+			failures = append(failures, failure)
+			mu.Unlock()
+		})
+	}
+
+	wg.Wait()
+
+	if len(failures) == 0 {
+		return nil
+	}
+
+	slices.SortFunc(failures, func(left, right Failure) int {
+		return strings.Compare(left.Name+" "+left.Type, right.Name+" "+right.Type)
+	})
+
+	return &Error{Server: v.server, Failures: failures}
+}
+
+// verify looks up one record, retrying until it is served.
+func (v *Verifier) verify(ctx context.Context, record Record) (Failure, bool) {
+	failure := Failure{Name: record.Name, Type: record.Type, Expected: record.Values}
+
+	for attempt := 0; ; attempt++ {
+		answers, err := v.lookup(ctx, record.Name, record.Type)
+		if err == nil && served(record, answers) {
+			return Failure{}, true
+		}
+
+		failure.Answers = answers
+		failure.Error = ""
+
+		if err != nil {
+			failure.Error = err.Error()
+		}
+
+		if attempt >= v.retries {
+			return failure, false
+		}
+
+		select {
+		case <-ctx.Done():
+			return failure, false
+		case <-time.After(v.interval):
+		}
+	}
+}
+
+// lookup queries the server for the values of name of recordType.
+func (v *Verifier) lookup(ctx context.Context, name, recordType string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	// Fully qualified, so search domains are not appended
+	fqdn := strings.TrimSuffix(name, ".") + "."
+
+	switch recordType {
+	case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+		network := "ip4"
+		if recordType == endpoint.RecordTypeAAAA {
+			network = "ip6"
+		}
+
+		addresses, err := v.resolver.LookupNetIP(ctx, network, fqdn)
+
+		answers := make([]string, 0, len(addresses))
+		for _, address := range addresses {
+			answers = append(answers, address.Unmap().String())
+		}
+
+		return answers, lookupError(err)
+	case endpoint.RecordTypeCNAME:
+		return v.lookupCNAME(ctx, fqdn)
+	default:
+		answers, err := v.resolver.LookupTXT(ctx, fqdn)
+
+		return answers, lookupError(err)
+	}
+}
+
+// lookupCNAME queries the server for the CNAME record of fqdn itself. The resolver's
+// LookupCNAME follows the whole chain, so a CNAME pointing at another CNAME would be
+// compared with the end of the chain instead of its own target.
+func (v *Verifier) lookupCNAME(ctx context.Context, fqdn string) ([]string, error) {
+	name, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, errors.Wrapf(err, "invalid name %s", fqdn)
+	}
+
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: uint16(rand.Uint32()), RecursionDesired: true}, //nolint:gosec // Query IDs need not be unpredictable here
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeCNAME, Class: dnsmessage.ClassINET}},
+	}
+
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to build DNS query")
+	}
+
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "udp", v.server)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to connect to DNS server")
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	_, err = conn.Write(packed)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to send DNS query")
+	}
+
+	buffer := make([]byte, maxMessageSize)
+
+	for {
+		size, err := conn.Read(buffer)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to read DNS response")
+		}
+
+		var response dnsmessage.Message
+
+		// Responses to other queries are skipped like the resolver does
+		if response.Unpack(buffer[:size]) != nil || !response.Response || response.ID != query.ID {
+			continue
+		}
+
+		switch response.RCode {
+		case dnsmessage.RCodeSuccess, dnsmessage.RCodeNameError:
+		default:
+			//nolint:wrapcheck // Creating new error, not wrapping
+			return nil, errors.Newf("DNS server answered %s", response.RCode)
+		}
+
+		answers := []string{}
+
+		for _, answer := range response.Answers {
+			cname, ok := answer.Body.(*dnsmessage.CNAMEResource)
+			if ok && strings.EqualFold(answer.Header.Name.String(), fqdn) {
+				answers = append(answers, strings.TrimSuffix(cname.CNAME.String(), "."))
+			}
+		}
+
+		return answers, nil
+	}
+}
+
+// lookupError drops not found errors, which show up as missing answers instead.
+func lookupError(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && dnsErr.IsNotFound {
+		return nil
+	}
+
+	//nolint:wrapcheck // Passing through the resolver error unchanged
+	return err
+}
+
+// served reports whether answers contain every value of record.
+func served(record Record, answers []string) bool {
+	for _, value := range record.Values {
+		matches := func(answer string) bool {
+			switch record.Type {
+			case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+				expected, err := netip.ParseAddr(value)
+
+				return err == nil && expected.Unmap().String() == answer
+			case endpoint.RecordTypeCNAME:
+				return strings.EqualFold(strings.TrimSuffix(value, "."), answer)
+			default:
+				return strings.Trim(value, `"`) == answer
+			}
+		}
+
+		if !slices.ContainsFunc(answers, matches) {
+			return false
+		}
+	}
+
+	return true
+}
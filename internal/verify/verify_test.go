@@ -0,0 +1,239 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package verify
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cockroachdb/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// fakeResolver answers A queries over UDP from a table, like a gateway that
+// picks up records some time after they were created.
+type fakeResolver struct {
+	mu      sync.Mutex
+	records map[string][]netip.Addr
+	// cnames are answered to CNAME queries with the rest of their chain, like a recursive resolver
+	cnames  map[string]string
+	queries map[string]int
+	// delay is the number of queries for a name answered with NXDOMAIN first
+	delay int
+}
+
+// serve answers queries on conn until it is closed.
+func (f *fakeResolver) serve(conn net.PacketConn) {
+	buffer := make([]byte, 1500)
+
+	for {
+		size, addr, err := conn.ReadFrom(buffer)
+		if err != nil {
+			return
+		}
+
+		response := f.answer(buffer[:size])
+		if response != nil {
+			_, _ = conn.WriteTo(response, addr)
+		}
+	}
+}
+
+// answer builds the response to a single-question query.
+func (f *fakeResolver) answer(query []byte) []byte {
+	const headerSize = 12
+
+	// The question name is a sequence of length-prefixed labels ending with a zero length
+	end := headerSize
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+
+	if end+5 > len(query) {
+		return nil
+	}
+
+	var labels []string
+
+	for offset := headerSize; offset < end; offset += int(query[offset]) + 1 {
+		labels = append(labels, string(query[offset+1:offset+1+int(query[offset])]))
+	}
+
+	name := strings.ToLower(strings.Join(labels, "."))
+	question := query[headerSize : end+5]
+	questionType := binary.BigEndian.Uint16(query[end+1:])
+
+	f.mu.Lock()
+	f.queries[name]++
+	addresses := f.records[name]
+
+	if f.queries[name] <= f.delay {
+		addresses = nil
+	}
+	f.mu.Unlock()
+
+	response := make([]byte, headerSize, 512)
+	copy(response, query[:2])
+
+	const (
+		typeA     = 1
+		typeCNAME = 5
+	)
+
+	if questionType == typeCNAME {
+		return f.answerCNAME(response, question, name)
+	}
+
+	if len(addresses) == 0 || questionType != typeA {
+		binary.BigEndian.PutUint16(response[2:], 0x8183) // Response, NXDOMAIN
+		binary.BigEndian.PutUint16(response[4:], 1)
+
+		return append(response, question...)
+	}
+
+	binary.BigEndian.PutUint16(response[2:], 0x8580) // Authoritative response, no error
+	binary.BigEndian.PutUint16(response[4:], 1)
+	binary.BigEndian.PutUint16(response[6:], uint16(len(addresses)))
+	response = append(response, question...)
+
+	for _, address := range addresses {
+		ipv4 := address.As4()
+
+		// Name pointer to the question, type A, class IN, TTL, data length, address
+		response = append(response, 0xc0, headerSize, 0, typeA, 0, 1, 0, 0, 0, 60, 0, 4)
+		response = append(response, ipv4[:]...)
+	}
+
+	return response
+}
+
+// answerCNAME appends the CNAME chain starting at name to response.
+func (f *fakeResolver) answerCNAME(response, question []byte, name string) []byte {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var answers []byte
+
+	count := 0
+
+	for target, ok := f.cnames[name]; ok; target, ok = f.cnames[name] {
+		answers = append(answers, encodeName(name)...)
+		// Type CNAME, class IN, TTL, data length
+		answers = append(answers, 0, 5, 0, 1, 0, 0, 0, 60)
+		answers = binary.BigEndian.AppendUint16(answers, uint16(len(encodeName(target))))
+		answers = append(answers, encodeName(target)...)
+		name = target
+		count++
+	}
+
+	binary.BigEndian.PutUint16(response[2:], 0x8180) // Response, no error
+	binary.BigEndian.PutUint16(response[4:], 1)
+	binary.BigEndian.PutUint16(response[6:], uint16(count))
+
+	return append(append(response, question...), answers...)
+}
+
+// encodeName encodes name as length-prefixed labels.
+func encodeName(name string) []byte {
+	var encoded []byte
+
+	for label := range strings.SplitSeq(name, ".") {
+		encoded = append(encoded, byte(len(label)))
+		encoded = append(encoded, label...)
+	}
+
+	return append(encoded, 0)
+}
+
+// startResolver serves resolver on a local UDP port and returns its address.
+func startResolver(t *testing.T, resolver *fakeResolver) string {
+	t.Helper()
+
+	resolver.queries = make(map[string]int)
+
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	go resolver.serve(conn)
+
+	return conn.LocalAddr().String()
+}
+
+func TestVerify(t *testing.T) {
+	t.Parallel()
+
+	resolver := &fakeResolver{
+		records: map[string][]netip.Addr{
+			"app.home.lan": {netip.MustParseAddr("192.168.1.50"), netip.MustParseAddr("192.168.1.51")},
+			"nas.home.lan": {netip.MustParseAddr("192.168.1.10")},
+		},
+		delay: 2,
+	}
+
+	verifier := New(startResolver(t, resolver), WithInterval(10*time.Millisecond), WithTimeout(time.Second))
+
+	// Records served after a few lookups pass; missing or different ones fail
+	err := verifier.Verify(context.Background(), []Record{
+		{Name: "app.home.lan", Type: endpoint.RecordTypeA, Values: []string{"192.168.1.50", "192.168.1.51"}},
+		{Name: "nas.home.lan", Type: endpoint.RecordTypeA, Values: []string{"192.168.1.11"}},
+		{Name: "missing.home.lan", Type: endpoint.RecordTypeA, Values: []string{"192.168.1.12"}},
+		{Name: "mail.home.lan", Type: endpoint.RecordTypeMX, Values: []string{"10 mx.home.lan"}},
+	})
+
+	var verifyErr *Error
+
+	require.True(t, errors.As(err, &verifyErr))
+	assert.Equal(t, []Failure{
+		{Name: "missing.home.lan", Type: endpoint.RecordTypeA, Expected: []string{"192.168.1.12"}, Answers: []string{}},
+		{Name: "nas.home.lan", Type: endpoint.RecordTypeA, Expected: []string{"192.168.1.11"}, Answers: []string{"192.168.1.10"}},
+	}, verifyErr.Failures)
+	assert.Contains(t, err.Error(), "2 records not served")
+
+	resolver.mu.Lock()
+	defer resolver.mu.Unlock()
+
+	assert.Equal(t, 3, resolver.queries["app.home.lan"], "looked up until served")
+	assert.Equal(t, DefaultRetries+1, resolver.queries["missing.home.lan"], "looked up once plus retries")
+	assert.Zero(t, resolver.queries["mail.home.lan"], "unsupported types are skipped")
+}
+
+func TestVerify_CNAME(t *testing.T) {
+	t.Parallel()
+
+	resolver := &fakeResolver{
+		cnames: map[string]string{
+			"app.home.lan": "web.home.lan",
+			"web.home.lan": "host.home.lan",
+		},
+	}
+
+	verifier := New(startResolver(t, resolver), WithInterval(10*time.Millisecond), WithTimeout(time.Second), WithRetries(0))
+
+	// A CNAME is compared with its own target, not with the end of its chain
+	err := verifier.Verify(context.Background(), []Record{
+		{Name: "app.home.lan", Type: endpoint.RecordTypeCNAME, Values: []string{"web.home.lan"}},
+		{Name: "web.home.lan", Type: endpoint.RecordTypeCNAME, Values: []string{"host.home.lan"}},
+	})
+	require.NoError(t, err)
+
+	err = verifier.Verify(context.Background(), []Record{
+		{Name: "app.home.lan", Type: endpoint.RecordTypeCNAME, Values: []string{"host.home.lan"}},
+		{Name: "missing.home.lan", Type: endpoint.RecordTypeCNAME, Values: []string{"host.home.lan"}},
+	})
+
+	var verifyErr *Error
+
+	require.True(t, errors.As(err, &verifyErr))
+	assert.Equal(t, []Failure{
+		{Name: "app.home.lan", Type: endpoint.RecordTypeCNAME, Expected: []string{"host.home.lan"}, Answers: []string{"web.home.lan"}},
+		{Name: "missing.home.lan", Type: endpoint.RecordTypeCNAME, Expected: []string{"host.home.lan"}, Answers: []string{}},
+	}, verifyErr.Failures)
+}
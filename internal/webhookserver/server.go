@@ -4,10 +4,14 @@ import (
 	"encoding/json"
 	"log/slog"
 	"net/http"
+	"sync"
 
 	"github.com/cockroachdb/errors"
 	"github.com/lexfrei/external-dns-unifios-webhook/api/webhook"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/dnsmetrics"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/freeze"
 	"github.com/lexfrei/external-dns-unifios-webhook/internal/provider"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/verify"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 )
@@ -27,15 +31,44 @@ const (
 // Server implements the webhook.ServerInterface for external-dns webhook protocol.
 type Server struct {
 	provider provider.DNSProvider
+	freeze   *freeze.Controller
+
+	// filterMu guards filter, which can change when the configuration is reloaded
+	filterMu sync.RWMutex
 	filter   endpoint.DomainFilter
 }
 
+// Option configures optional Server behavior.
+type Option func(*Server)
+
+// WithFreeze makes SetRecords honor maintenance windows from the given controller.
+func WithFreeze(controller *freeze.Controller) Option {
+	return func(s *Server) {
+		s.freeze = controller
+	}
+}
+
 // New creates a new webhook server instance.
-func New(prov provider.DNSProvider, filter endpoint.DomainFilter) *Server {
-	return &Server{
+func New(prov provider.DNSProvider, filter endpoint.DomainFilter, opts ...Option) *Server {
+	srv := &Server{
 		provider: prov,
 		filter:   filter,
 	}
+
+	for _, opt := range opts {
+		opt(srv)
+	}
+
+	return srv
+}
+
+// SetDomainFilter replaces the domain filter returned by Negotiate.
+// external-dns negotiates once at startup, so it sees the new filter after its next restart.
+func (s *Server) SetDomainFilter(filter endpoint.DomainFilter) {
+	s.filterMu.Lock()
+	defer s.filterMu.Unlock()
+
+	s.filter = filter
 }
 
 // Negotiate returns the domain filter configuration.
@@ -44,7 +77,9 @@ func (s *Server) Negotiate(w http.ResponseWriter, r *http.Request, _ webhook.Neg
 	slog.InfoContext(r.Context(), "negotiate called")
 
 	// Return configured domain filters
+	s.filterMu.RLock()
 	filters := s.filter.Filters
+	s.filterMu.RUnlock()
 
 	response := webhook.Filters{
 		Filters: &filters,
@@ -115,13 +150,29 @@ func (s *Server) SetRecords(w http.ResponseWriter, r *http.Request, _ webhook.Se
 	// Convert webhook changes to external-dns plan
 	planChanges := convertToPlan(changes)
 
+	// Hold changes back during maintenance windows
+	if s.freeze != nil && s.freeze.Frozen() {
+		s.writeFrozen(w, r, planChanges)
+
+		return
+	}
+
 	// Apply changes using provider
 	err = s.provider.ApplyChanges(r.Context(), planChanges)
 	if err != nil {
 		slog.ErrorContext(r.Context(), "failed to apply changes", errorKey, err)
+
+		response := map[string]any{errorKey: err.Error()}
+
+		// Records that were applied but are not served yet are listed individually
+		var verifyErr *verify.Error
+		if errors.As(err, &verifyErr) {
+			response["verification"] = verifyErr.Failures
+		}
+
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusInternalServerError)
-		_ = json.NewEncoder(w).Encode(map[string]string{errorKey: err.Error()})
+		_ = json.NewEncoder(w).Encode(response)
 
 		return
 	}
@@ -129,6 +180,28 @@ func (s *Server) SetRecords(w http.ResponseWriter, r *http.Request, _ webhook.Se
 	w.WriteHeader(http.StatusNoContent)
 }
 
+// writeFrozen queues or rejects changes received while DNS changes are frozen.
+// external-dns treats any status but 204 No Content as a failure, so queued changes
+// are answered with 204 once deferred; rejected ones get 423 Locked and are retried
+// on the next sync.
+func (s *Server) writeFrozen(w http.ResponseWriter, r *http.Request, planChanges *plan.Changes) {
+	if s.freeze.Mode() == freeze.ModeQueue {
+		s.freeze.Defer(planChanges)
+		dnsmetrics.FreezeDeferredRequests.WithLabelValues("queued").Inc()
+
+		w.WriteHeader(http.StatusNoContent)
+
+		return
+	}
+
+	slog.WarnContext(r.Context(), "rejecting DNS changes during freeze")
+	dnsmetrics.FreezeDeferredRequests.WithLabelValues("rejected").Inc()
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusLocked)
+	_ = json.NewEncoder(w).Encode(map[string]string{errorKey: "DNS changes are frozen"})
+}
+
 // AdjustRecords allows the provider to modify endpoints before they are applied.
 // POST /adjustendpoints.
 func (s *Server) AdjustRecords(w http.ResponseWriter, r *http.Request, _ webhook.AdjustRecordsParams) {
@@ -0,0 +1,81 @@
+//nolint:testpackage // Testing private functions and types requires same-package tests
+package webhookserver
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/lexfrei/external-dns-unifios-webhook/api/webhook"
+	"github.com/lexfrei/external-dns-unifios-webhook/internal/freeze"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// setRecordsBody creates one A record.
+const setRecordsBody = `{"create": [{"dnsName": "app.home.lan", "recordType": "A", "targets": ["192.168.1.50"]}]}`
+
+// fakeProvider records applied change sets.
+type fakeProvider struct {
+	applied []*plan.Changes
+}
+
+func (f *fakeProvider) Records(context.Context) ([]*endpoint.Endpoint, error) {
+	return nil, nil
+}
+
+func (f *fakeProvider) ApplyChanges(_ context.Context, changes *plan.Changes) error {
+	f.applied = append(f.applied, changes)
+
+	return nil
+}
+
+func (f *fakeProvider) AdjustEndpoints(endpoints []*endpoint.Endpoint) ([]*endpoint.Endpoint, error) {
+	return endpoints, nil
+}
+
+// setRecords posts setRecordsBody to a server frozen in mode.
+func setRecords(t *testing.T, mode freeze.Mode) (*httptest.ResponseRecorder, *fakeProvider, *freeze.Controller) {
+	t.Helper()
+
+	prov := &fakeProvider{}
+
+	controller, err := freeze.New(prov, mode, true, nil)
+	require.NoError(t, err)
+
+	srv := New(prov, endpoint.DomainFilter{}, WithFreeze(controller))
+
+	req := httptest.NewRequestWithContext(context.Background(), http.MethodPost, "/records", strings.NewReader(setRecordsBody))
+	rec := httptest.NewRecorder()
+
+	srv.SetRecords(rec, req, webhook.SetRecordsParams{})
+
+	return rec, prov, controller
+}
+
+func TestSetRecords_FrozenQueue(t *testing.T) {
+	t.Parallel()
+
+	rec, prov, controller := setRecords(t, freeze.ModeQueue)
+
+	// external-dns counts anything but 204 as a failed sync
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+	assert.True(t, controller.HasPending())
+	assert.Empty(t, prov.applied)
+}
+
+func TestSetRecords_FrozenReject(t *testing.T) {
+	t.Parallel()
+
+	rec, prov, controller := setRecords(t, freeze.ModeReject)
+
+	assert.Equal(t, http.StatusLocked, rec.Code)
+	assert.JSONEq(t, `{"error": "DNS changes are frozen"}`, rec.Body.String())
+	assert.False(t, controller.HasPending())
+	assert.Empty(t, prov.applied)
+}